@@ -2,6 +2,7 @@ package configs
 
 import (
 	"os"
+	"sync/atomic"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,6 +22,8 @@ type Config struct {
 			Enabled        bool          `yaml:"enabled"`
 			AllowedDevices []string      `yaml:"allowed_devices"`
 			Tokens         []TokenConfig `yaml:"tokens"`
+			TokenExpiry    string        `yaml:"token_expiry"` // OTA下发token的有效期，如720h
+			AdminToken     string        `yaml:"admin_token"`  // 管理接口的访问令牌，未配置时管理接口不可用
 		} `yaml:"auth"`
 	} `yaml:"server"`
 
@@ -57,8 +60,58 @@ type Config struct {
 
 	CMDExit []string `yaml:"CMD_exit"`
 
+	// 设备绑定配置
+	DeviceBinding DeviceBinding `yaml:"device_binding"`
+
 	// 连通性检查配置
 	ConnectivityCheck ConnectivityCheckConfig `yaml:"connectivity_check"`
+
+	// 工具调用配置
+	ToolCall ToolCallConfig `yaml:"tool_call"`
+
+	// 硬件控制配置
+	Hardware HardwareConfig `yaml:"hardware"`
+
+	// IoT设备配置
+	IoT IoTConfig `yaml:"iot"`
+
+	// MCP服务端配置
+	MCPServer MCPServerConfig `yaml:"mcp_server"`
+
+	// 主动推送配置
+	Push PushConfig `yaml:"push"`
+}
+
+// PushConfig 主动推送配置
+type PushConfig struct {
+	AudioDir string `yaml:"audio_dir"` // 推送音频文件所在目录，audio_path 为相对该目录的路径，默认 ./audio
+}
+
+// MCPServerConfig MCP服务端配置，向外部智能体开放设备控制工具
+type MCPServerConfig struct {
+	Enabled bool   `yaml:"enabled"` // 是否在 /api/mcp 开放 SSE 端点
+	Stdio   bool   `yaml:"stdio"`   // 是否同时通过标准输入输出提供服务，启用后控制台日志输出到标准错误
+	Token   string `yaml:"token"`   // HTTP 端点的访问令牌，启用 HTTP 端点时必须配置
+}
+
+// IoTConfig IoT设备配置，设备上报的描述符始终注册为工具
+type IoTConfig struct {
+	StatePrompt bool `yaml:"state_prompt"` // 是否将设备上报的状态加入提示词
+}
+
+// HardwareConfig 硬件控制函数配置，启用后向LLM提供电机、LED和传感器工具
+type HardwareConfig struct {
+	Enabled bool   `yaml:"enabled"` // 是否启用硬件控制函数
+	Mode    string `yaml:"mode"`    // device: 通过WebSocket下发到设备执行（默认）；simulated: 使用模拟控制器
+	Timeout string `yaml:"timeout"` // 等待设备响应的超时时间，默认10s
+}
+
+// ToolCallConfig 工具调用配置，未配置的字段使用默认值
+type ToolCallConfig struct {
+	MaxIterations int      `yaml:"max_iterations"` // 单轮对话中LLM连续调用工具的最大轮数，默认5
+	Timeout       string   `yaml:"timeout"`        // 单个工具的执行超时时间，默认30s
+	FillerDelay   string   `yaml:"filler_delay"`   // 工具执行超过该时间时播放等待提示语，默认2s
+	FillerPhrases []string `yaml:"filler_phrases"` // 等待提示语，随机选取一条
 }
 
 // VADConfig VAD配置结构
@@ -83,6 +136,7 @@ type TTSConfig struct {
 	Token           string   `yaml:"token"`
 	Cluster         string   `yaml:"cluster"`
 	SurportedVoices []string `yaml:"surported_voices"` // 支持的语音列表
+	Concurrency     int      `yaml:"concurrency"`      // 同时合成的句子数，默认2
 }
 
 // LLMConfig LLM配置结构
@@ -114,7 +168,11 @@ type ConnectivityCheckConfig struct {
 	Timeout       string `yaml:"timeout"`        // 检查超时时间
 	RetryAttempts int    `yaml:"retry_attempts"` // 重试次数
 	RetryDelay    string `yaml:"retry_delay"`    // 重试延迟
-	TestModes     struct {
+	// 启动后周期性检查的间隔，为空时使用默认值，设为0关闭周期检查
+	Interval           string `yaml:"interval"`            // 基础连通性检查间隔
+	FunctionalInterval string `yaml:"functional_interval"` // 功能性检查间隔
+	FallbackText       string `yaml:"fallback_text"`       // 必需的提供者不可用时播放的提示语
+	TestModes          struct {
 		ASRTestAudio  string `yaml:"asr_test_audio"`  // ASR测试音频文件
 		LLMTestPrompt string `yaml:"llm_test_prompt"` // LLM测试提示词
 		TTSTestText   string `yaml:"tts_test_text"`   // TTS测试文本
@@ -154,12 +212,18 @@ type DeviceInfo struct {
 	LastSeen   string `yaml:"last_seen"`   // 最后在线时间
 }
 
-// LoadConfig 从文件加载配置
-func LoadConfig() (*Config, string, error) {
+// ConfigPath 返回配置文件路径，优先使用 .config.yaml
+func ConfigPath() string {
 	path := ".config.yaml"
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		path = "config.yaml"
 	}
+	return path
+}
+
+// LoadConfig 从文件加载配置
+func LoadConfig() (*Config, string, error) {
+	path := ConfigPath()
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, path, err
@@ -172,3 +236,17 @@ func LoadConfig() (*Config, string, error) {
 
 	return config, path, nil
 }
+
+// current 当前生效的配置快照
+var current atomic.Pointer[Config]
+
+// Current 返回当前生效的配置快照，启动时由 SetCurrent 设置
+// 快照只读，运行时修改配置需复制后通过 SetCurrent 整体替换，读取方不会看到修改到一半的配置
+func Current() *Config {
+	return current.Load()
+}
+
+// SetCurrent 替换当前生效的配置快照
+func SetCurrent(config *Config) {
+	current.Store(config)
+}
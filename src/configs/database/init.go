@@ -140,12 +140,31 @@ func InitDB(logger *xiaozhi_utils.Logger) (*gorm.DB, string, error) {
 
 // migrateTables 自动迁移模型表结构
 func migrateTables(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&models.SystemConfig{},
 		&models.User{},
 		&models.UserSetting{},
 		&models.ModuleConfig{},
-	)
+		&models.DeviceMemory{},
+		&models.DeviceBinding{},
+		&models.Device{},
+		&models.DeviceSession{},
+		&models.PushMessage{},
+		&models.Reminder{},
+		&models.TaskRecord{},
+		&models.TaskQuota{},
+	); err != nil {
+		return err
+	}
+
+	// 模块配置的唯一索引由名称改为(类型, 名称)，删除旧索引以允许不同模块使用同名提供者
+	migrator := db.Migrator()
+	if migrator.HasIndex(&models.ModuleConfig{}, "idx_module_configs_name") {
+		if err := migrator.DropIndex(&models.ModuleConfig{}, "idx_module_configs_name"); err != nil {
+			return fmt.Errorf("删除模块配置旧索引失败: %v", err)
+		}
+	}
+	return nil
 }
 
 // InsertDefaultConfigIfNeeded 首次启动插入默认配置
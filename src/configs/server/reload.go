@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/providers/llm/chain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const configWatchInterval = 3 * time.Second // 配置文件变更检查间隔
+
+// copyReloadable 复制可热加载的配置字段
+func copyReloadable(dst, src *configs.Config) {
+	copyRuntime(dst, src)
+	dst.QuickReply = src.QuickReply
+	dst.Roles = src.Roles
+	dst.CMDExit = src.CMDExit
+}
+
+// needsRestart 判断除可热加载字段外是否还有其他配置变化
+func needsRestart(running, loaded *configs.Config) bool {
+	a, b := *running, *loaded
+	copyReloadable(&a, &configs.Config{})
+	copyReloadable(&b, &configs.Config{})
+	return !reflect.DeepEqual(a, b)
+}
+
+// providerValue 获取指定模块下提供者的配置值
+func providerValue(config *configs.Config, module, name string) (interface{}, bool) {
+	switch module {
+	case "ASR":
+		v, ok := config.ASR[name]
+		return v, ok
+	case "LLM":
+		v, ok := config.LLM[name]
+		return v, ok
+	case "TTS":
+		v, ok := config.TTS[name]
+		return v, ok
+	case "VAD":
+		v, ok := config.VAD[name]
+		return v, ok
+	case "VLLLM":
+		v, ok := config.VLLLM[name]
+		return v, ok
+	}
+	return nil, false
+}
+
+// providerNames 返回指定模块下所有提供者名称
+func providerNames(config *configs.Config, module string) []string {
+	var names []string
+	switch module {
+	case "ASR":
+		for name := range config.ASR {
+			names = append(names, name)
+		}
+	case "LLM":
+		for name := range config.LLM {
+			names = append(names, name)
+		}
+	case "TTS":
+		for name := range config.TTS {
+			names = append(names, name)
+		}
+	case "VAD":
+		for name := range config.VAD {
+			names = append(names, name)
+		}
+	case "VLLLM":
+		for name := range config.VLLLM {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// diffProviders 对比新旧配置，返回需要重建资源池的模块和配置有变化的提供者
+func diffProviders(running, next *configs.Config) ([]string, map[string][]string) {
+	var affected []string
+	changed := make(map[string][]string)
+	for _, module := range runtimeModules {
+		names := make(map[string]bool)
+		for _, name := range providerNames(running, module) {
+			names[name] = true
+		}
+		for _, name := range providerNames(next, module) {
+			names[name] = true
+		}
+		for name := range names {
+			oldValue, _ := providerValue(running, module, name)
+			newValue, _ := providerValue(next, module, name)
+			if !reflect.DeepEqual(oldValue, newValue) {
+				changed[module] = append(changed[module], name)
+			}
+		}
+
+		selected := next.SelectedModule[module]
+		if selected != running.SelectedModule[module] {
+			affected = append(affected, module)
+			continue
+		}
+		for _, name := range changed[module] {
+			if name == selected || isChainMember(next, module, selected, name) {
+				affected = append(affected, module)
+				break
+			}
+		}
+	}
+	return affected, changed
+}
+
+// isChainMember 判断提供者是否为所选组合LLM的成员，成员配置变化时组合LLM的资源池也需要重建
+func isChainMember(config *configs.Config, module, selected, name string) bool {
+	if module != "LLM" {
+		return false
+	}
+	llmCfg, ok := config.LLM[selected]
+	if !ok || llmCfg.Type != chain.TypeName {
+		return false
+	}
+	chainCfg, err := chain.ParseConfig(llmCfg.Extra)
+	if err != nil {
+		return false
+	}
+	for _, member := range chainCfg.Providers {
+		if member == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload 重新加载配置文件，仅重建提供者配置有变化的资源池
+// 与启动时相同，通过 /api/cfg 保存的配置优先于配置文件，被覆盖的项会记录警告日志
+// 新配置整体替换当前配置快照，使用中的旧提供者在连接结束归还时随旧资源池销毁
+func (s *DefaultCfgService) Reload() ([]string, error) {
+	loaded, path, err := configs.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("加载配置文件 %s 失败: %v", path, err)
+	}
+
+	// 在锁内读取数据库，避免与并发的 /api/cfg 修改交错
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if database.DB != nil {
+		if err := ApplyPersistedConfig(database.DB, loaded, s.logger); err != nil {
+			s.logger.Warn("加载运行时配置失败: %v", err)
+		}
+	}
+
+	running := configs.Current()
+	next := *running
+	copyReloadable(&next, loaded)
+	if err := validateSelectedModules(&next); err != nil {
+		return nil, fmt.Errorf("配置文件 %s 校验失败: %v", path, err)
+	}
+	if needsRestart(running, loaded) {
+		s.logger.Warn("配置文件 %s 中服务、日志、认证等配置的修改需重启后生效", path)
+	}
+
+	affected, changed := diffProviders(running, &next)
+	if _, err := s.rebuildPools(&next, affected); err != nil {
+		return nil, err
+	}
+
+	configs.SetCurrent(&next)
+	for module, names := range changed {
+		for _, name := range names {
+			s.resetProviderPool(module, name)
+		}
+	}
+
+	s.logger.Info("配置文件 %s 已重新加载，重建资源池: %v", path, affected)
+	return affected, nil
+}
+
+// handleReload 手动触发配置文件重新加载
+func (s *DefaultCfgService) handleReload(c *gin.Context) {
+	rebuilt, err := s.Reload()
+	if err != nil {
+		s.logger.Error("重新加载配置失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"rebuilt": rebuilt,
+	})
+}
+
+// watchConfigFile 定期检查配置文件修改时间，变化后自动重新加载
+func (s *DefaultCfgService) watchConfigFile(ctx context.Context) {
+	var lastModTime time.Time
+	if info, err := os.Stat(configs.ConfigPath()); err == nil {
+		lastModTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(configs.ConfigPath())
+			if err != nil || !info.ModTime().After(lastModTime) {
+				continue
+			}
+			lastModTime = info.ModTime()
+			s.logger.Info("检测到配置文件变更，开始重新加载")
+			if _, err := s.Reload(); err != nil {
+				s.logger.Error("重新加载配置失败: %v", err)
+			}
+		}
+	}
+}
+
+// watchReloadSignal 收到 SIGHUP 信号时重新加载配置文件
+func (s *DefaultCfgService) watchReloadSignal(ctx context.Context) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigChan:
+			s.logger.Info("收到 SIGHUP 信号，开始重新加载配置")
+			if _, err := s.Reload(); err != nil {
+				s.logger.Error("重新加载配置失败: %v", err)
+			}
+		}
+	}
+}
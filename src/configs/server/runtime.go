@@ -0,0 +1,579 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// redactedValue 敏感配置项脱敏后的占位值，提交时遇到该值表示保持原值
+const redactedValue = "******"
+
+// runtimeModules 支持运行时切换的模块
+var runtimeModules = []string{"ASR", "LLM", "TTS", "VAD", "VLLLM"}
+
+// requiredModules 必须选择提供者的模块
+var requiredModules = map[string]bool{"ASR": true, "LLM": true, "TTS": true}
+
+// sensitiveKeys 需要脱敏的配置项
+var sensitiveKeys = map[string]bool{
+	"token":         true,
+	"api_key":       true,
+	"apikey":        true,
+	"secret":        true,
+	"password":      true,
+	"access_key":    true,
+	"access_token":  true,
+	"appkey":        true,
+	"app_key":       true,
+	"secret_key":    true,
+	"access_secret": true,
+}
+
+// PoolRebuilder 按新配置重建资源池
+type PoolRebuilder interface {
+	RebuildPool(module string, config *configs.Config) error
+}
+
+// providerPoolResetter 可选接口，关闭按需创建的提供者资源池
+type providerPoolResetter interface {
+	ResetProviderPool(module, name string)
+}
+
+// CfgUpdateRequest 配置更新请求，未提供的字段保持不变
+type CfgUpdateRequest struct {
+	SelectedModule  map[string]string                            `json:"selected_module"`
+	Prompt          *string                                      `json:"prompt"`
+	QuickReplyWords []string                                     `json:"quick_reply_words"`
+	Providers       map[string]map[string]map[string]interface{} `json:"providers"` // 模块 -> 提供者名称 -> 配置项
+}
+
+// isSensitiveKey 判断配置项是否为敏感信息
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, suffix := range []string{"_token", "_secret", "_key", "_password"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact 递归脱敏配置中的敏感字段
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if str, ok := item.(string); ok && str != "" && isSensitiveKey(key) {
+				out[key] = redactedValue
+				continue
+			}
+			out[key] = redact(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = redact(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// RedactedConfig 返回脱敏后的完整配置
+func RedactedConfig(config *configs.Config) (map[string]interface{}, error) {
+	data, err := toMap(config)
+	if err != nil {
+		return nil, err
+	}
+	return redact(data).(map[string]interface{}), nil
+}
+
+// toMap 按yaml标签将配置结构转为map
+func toMap(v interface{}) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %v", err)
+	}
+	out := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("解析配置失败: %v", err)
+	}
+	return out, nil
+}
+
+// fromMap 按yaml标签将map转为配置结构
+func fromMap(m map[string]interface{}, out interface{}) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("序列化配置失败: %v", err)
+	}
+	if err := yaml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析配置失败: %v", err)
+	}
+	return nil
+}
+
+// providerSettings 获取指定模块下提供者的配置项
+func providerSettings(config *configs.Config, module, name string) (map[string]interface{}, bool, error) {
+	var value interface{}
+	var ok bool
+	switch module {
+	case "ASR":
+		value, ok = config.ASR[name]
+	case "LLM":
+		value, ok = config.LLM[name]
+	case "TTS":
+		value, ok = config.TTS[name]
+	case "VAD":
+		value, ok = config.VAD[name]
+	case "VLLLM":
+		value, ok = config.VLLLM[name]
+	default:
+		return nil, false, fmt.Errorf("未知的模块: %s", module)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	settings, err := toMap(value)
+	return settings, true, err
+}
+
+// setProviderSettings 写入提供者配置，复制模块映射后再修改，避免影响正在读取旧配置的连接
+func setProviderSettings(config *configs.Config, module, name string, settings map[string]interface{}) error {
+	switch module {
+	case "ASR":
+		var value configs.ASRConfig
+		if err := fromMap(settings, &value); err != nil {
+			return err
+		}
+		config.ASR = copyWith(config.ASR, name, value)
+	case "LLM":
+		var value configs.LLMConfig
+		if err := fromMap(settings, &value); err != nil {
+			return err
+		}
+		config.LLM = copyWith(config.LLM, name, value)
+	case "TTS":
+		var value configs.TTSConfig
+		if err := fromMap(settings, &value); err != nil {
+			return err
+		}
+		config.TTS = copyWith(config.TTS, name, value)
+	case "VAD":
+		var value configs.VADConfig
+		if err := fromMap(settings, &value); err != nil {
+			return err
+		}
+		config.VAD = copyWith(config.VAD, name, value)
+	case "VLLLM":
+		var value configs.VLLMConfig
+		if err := fromMap(settings, &value); err != nil {
+			return err
+		}
+		config.VLLLM = copyWith(config.VLLLM, name, value)
+	default:
+		return fmt.Errorf("未知的模块: %s", module)
+	}
+	return nil
+}
+
+// copyWith 复制映射并设置指定键
+func copyWith[V any](src map[string]V, key string, value V) map[string]V {
+	dst := make(map[string]V, len(src)+1)
+	for k, v := range src {
+		dst[k] = v
+	}
+	dst[key] = value
+	return dst
+}
+
+// mergeSettings 将提交的配置项合并到原配置，脱敏占位值保持原值
+func mergeSettings(base, patch map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(patch))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range patch {
+		if str, ok := v.(string); ok && str == redactedValue {
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// isRuntimeModule 判断模块是否支持运行时切换
+func isRuntimeModule(module string) bool {
+	for _, m := range runtimeModules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSelectedModules 校验各模块选择的提供者存在，必选模块不能为空
+func validateSelectedModules(config *configs.Config) error {
+	for _, module := range runtimeModules {
+		name := config.SelectedModule[module]
+		if name == "" {
+			if requiredModules[module] {
+				return fmt.Errorf("%s模块必须选择提供者", module)
+			}
+			continue
+		}
+		if _, ok, err := providerSettings(config, module, name); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("%s提供者 %s 不存在", module, name)
+		}
+	}
+	return nil
+}
+
+// applyUpdate 校验更新请求并生成新配置，返回需要重建资源池的模块
+func applyUpdate(current *configs.Config, req *CfgUpdateRequest) (*configs.Config, []string, error) {
+	next := *current
+
+	// 提供者配置
+	updatedProviders := make(map[string]map[string]bool)
+	for module, providers := range req.Providers {
+		if !isRuntimeModule(module) {
+			return nil, nil, fmt.Errorf("不支持的模块: %s", module)
+		}
+		for name, patch := range providers {
+			if name == "" {
+				return nil, nil, fmt.Errorf("%s提供者名称不能为空", module)
+			}
+			base, _, err := providerSettings(&next, module, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			merged := mergeSettings(base, patch)
+			if providerType, _ := merged["type"].(string); providerType == "" {
+				return nil, nil, fmt.Errorf("%s提供者 %s 缺少type配置", module, name)
+			}
+			if err := setProviderSettings(&next, module, name, merged); err != nil {
+				return nil, nil, fmt.Errorf("%s提供者 %s 配置无效: %v", module, name, err)
+			}
+			if updatedProviders[module] == nil {
+				updatedProviders[module] = make(map[string]bool)
+			}
+			updatedProviders[module][name] = true
+		}
+	}
+
+	// 模块选择
+	if len(req.SelectedModule) > 0 {
+		selected := make(map[string]string, len(current.SelectedModule))
+		for k, v := range current.SelectedModule {
+			selected[k] = v
+		}
+		for module, name := range req.SelectedModule {
+			if !isRuntimeModule(module) {
+				return nil, nil, fmt.Errorf("不支持的模块: %s", module)
+			}
+			selected[module] = strings.TrimSpace(name)
+		}
+		next.SelectedModule = selected
+	}
+	if err := validateSelectedModules(&next); err != nil {
+		return nil, nil, err
+	}
+
+	if req.Prompt != nil {
+		next.DefaultPrompt = strings.TrimSpace(*req.Prompt)
+	}
+	if req.QuickReplyWords != nil {
+		words := make([]string, 0, len(req.QuickReplyWords))
+		for _, word := range req.QuickReplyWords {
+			if word = strings.TrimSpace(word); word != "" {
+				words = append(words, word)
+			}
+		}
+		next.QuickReplyWords = words
+	}
+
+	var affected []string
+	for _, module := range runtimeModules {
+		name := next.SelectedModule[module]
+		if name != current.SelectedModule[module] || updatedProviders[module][name] {
+			affected = append(affected, module)
+		}
+	}
+	return &next, affected, nil
+}
+
+// copyRuntime 复制可通过 /api/cfg 修改的字段
+func copyRuntime(dst, src *configs.Config) {
+	dst.SelectedModule = src.SelectedModule
+	dst.DefaultPrompt = src.DefaultPrompt
+	dst.QuickReplyWords = src.QuickReplyWords
+	dst.ASR = src.ASR
+	dst.LLM = src.LLM
+	dst.TTS = src.TTS
+	dst.VAD = src.VAD
+	dst.VLLLM = src.VLLLM
+}
+
+// 系统配置中可覆盖的字段，模块选择的字段名为 selected_module.<模块>
+const (
+	overridePrompt          = "prompt"
+	overrideQuickReplyWords = "quick_reply_words"
+	overrideSelectedPrefix  = "selected_module."
+)
+
+// selectedFields 返回系统配置中各模块选择对应的字段
+func selectedFields(system *models.SystemConfig) map[string]*string {
+	return map[string]*string{
+		"ASR":   &system.SelectedASR,
+		"LLM":   &system.SelectedLLM,
+		"TTS":   &system.SelectedTTS,
+		"VAD":   &system.SelectedVAD,
+		"VLLLM": &system.SelectedVLLLM,
+	}
+}
+
+// loadOverrides 解析系统配置中已覆盖的字段
+func loadOverrides(system *models.SystemConfig) (map[string]bool, error) {
+	overrides := make(map[string]bool)
+	if len(system.Overrides) == 0 {
+		return overrides, nil
+	}
+	var keys []string
+	if err := json.Unmarshal(system.Overrides, &keys); err != nil {
+		return nil, fmt.Errorf("解析已覆盖的配置项失败: %v", err)
+	}
+	for _, key := range keys {
+		overrides[key] = true
+	}
+	return overrides, nil
+}
+
+// submittedSettings 返回请求中实际提交的配置项，脱敏占位值表示未修改
+func submittedSettings(patch map[string]interface{}) map[string]interface{} {
+	return mergeSettings(nil, patch)
+}
+
+// persistConfig 将请求中修改的配置项保存到数据库，未修改的项仍以配置文件为准
+func persistConfig(db *gorm.DB, config *configs.Config, req *CfgUpdateRequest) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var system models.SystemConfig
+		if err := tx.First(&system).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("查询系统配置失败: %v", err)
+		}
+		overrides, err := loadOverrides(&system)
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		fields := selectedFields(&system)
+		for module := range req.SelectedModule {
+			*fields[module] = config.SelectedModule[module]
+			overrides[overrideSelectedPrefix+module] = true
+			changed = true
+		}
+		if req.Prompt != nil {
+			system.Prompt = config.DefaultPrompt
+			overrides[overridePrompt] = true
+			changed = true
+		}
+		if req.QuickReplyWords != nil {
+			quickReplyWords, err := json.Marshal(config.QuickReplyWords)
+			if err != nil {
+				return fmt.Errorf("序列化快速回复词失败: %v", err)
+			}
+			system.QuickReplyWords = quickReplyWords
+			overrides[overrideQuickReplyWords] = true
+			changed = true
+		}
+		if changed {
+			keys := make([]string, 0, len(overrides))
+			for key := range overrides {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			data, err := json.Marshal(keys)
+			if err != nil {
+				return fmt.Errorf("序列化已覆盖的配置项失败: %v", err)
+			}
+			system.Overrides = data
+			if err := tx.Save(&system).Error; err != nil {
+				return fmt.Errorf("保存系统配置失败: %v", err)
+			}
+		}
+
+		// 提供者只保存提交的配置项，与之前保存的配置项合并
+		for module, providers := range req.Providers {
+			for name, patch := range providers {
+				submitted := submittedSettings(patch)
+				if len(submitted) == 0 {
+					continue
+				}
+				var record models.ModuleConfig
+				err := tx.Where("type = ? AND name = ?", module, name).First(&record).Error
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("查询%s提供者 %s 配置失败: %v", module, name, err)
+				}
+				stored := make(map[string]interface{})
+				if len(record.ConfigJSON) > 0 {
+					if err := json.Unmarshal(record.ConfigJSON, &stored); err != nil {
+						return fmt.Errorf("解析%s提供者 %s 配置失败: %v", module, name, err)
+					}
+				}
+				data, err := json.Marshal(mergeSettings(stored, submitted))
+				if err != nil {
+					return fmt.Errorf("序列化%s提供者 %s 配置失败: %v", module, name, err)
+				}
+				record.Type = module
+				record.Name = name
+				record.ConfigJSON = data
+				record.Enabled = true
+				if err := tx.Save(&record).Error; err != nil {
+					return fmt.Errorf("保存%s提供者 %s 配置失败: %v", module, name, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// clearOverrides 清除通过 /api/cfg 保存的配置，之后全部以配置文件为准
+func clearOverrides(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("type IN ?", runtimeModules).Delete(&models.ModuleConfig{}).Error; err != nil {
+			return fmt.Errorf("删除提供者配置失败: %v", err)
+		}
+		if err := tx.Model(&models.SystemConfig{}).Where("1 = 1").Update("overrides", nil).Error; err != nil {
+			return fmt.Errorf("清除系统配置失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// ApplyPersistedConfig 将数据库中通过 /api/cfg 保存的配置项覆盖到配置文件内容上
+// 优先级规则：通过 /api/cfg 修改过的配置项始终优先于配置文件，启动和重新加载配置文件时一致，
+// 未修改过的配置项以配置文件为准；被覆盖的项会记录警告日志，
+// 需要全部以配置文件为准时可通过 DELETE /api/cfg/overrides 清除数据库中的记录
+func ApplyPersistedConfig(db *gorm.DB, config *configs.Config, logger *utils.Logger) error {
+	var modules []models.ModuleConfig
+	if err := db.Where("enabled = ?", true).Find(&modules).Error; err != nil {
+		return fmt.Errorf("查询模块配置失败: %v", err)
+	}
+	for _, module := range modules {
+		if !isRuntimeModule(module.Type) {
+			continue
+		}
+		patch := make(map[string]interface{})
+		if err := json.Unmarshal(module.ConfigJSON, &patch); err != nil {
+			logger.Warn("解析%s提供者 %s 配置失败: %v", module.Type, module.Name, err)
+			continue
+		}
+		base, inFile, err := providerSettings(config, module.Type, module.Name)
+		if err != nil {
+			logger.Warn("读取%s提供者 %s 配置失败: %v", module.Type, module.Name, err)
+			continue
+		}
+		settings := mergeSettings(base, patch)
+		if providerType, _ := settings["type"].(string); providerType == "" {
+			logger.Warn("数据库中的%s提供者 %s 缺少type配置，已忽略", module.Type, module.Name)
+			continue
+		}
+		fileValue, _ := providerValue(config, module.Type, module.Name)
+		if err := setProviderSettings(config, module.Type, module.Name, settings); err != nil {
+			logger.Warn("加载%s提供者 %s 配置失败: %v", module.Type, module.Name, err)
+			continue
+		}
+		if dbValue, _ := providerValue(config, module.Type, module.Name); inFile && !reflect.DeepEqual(fileValue, dbValue) {
+			logOverride(logger, fmt.Sprintf("%s提供者 %s 的配置", module.Type, module.Name))
+		}
+		logger.Info("已加载数据库中的%s提供者配置: %s", module.Type, module.Name)
+	}
+
+	var system models.SystemConfig
+	err := db.First(&system).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询系统配置失败: %v", err)
+	}
+	overrides, err := loadOverrides(&system)
+	if err != nil {
+		return err
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	selected := make(map[string]string, len(config.SelectedModule))
+	for k, v := range config.SelectedModule {
+		selected[k] = v
+	}
+	for module, field := range selectedFields(&system) {
+		if !overrides[overrideSelectedPrefix+module] {
+			continue
+		}
+		name := *field
+		if name == "" {
+			if !requiredModules[module] {
+				selected[module] = ""
+			}
+			continue
+		}
+		if _, ok, _ := providerSettings(config, module, name); !ok {
+			logger.Warn("数据库中选择的%s提供者 %s 不存在，保留配置文件中的选择", module, name)
+			continue
+		}
+		if file := config.SelectedModule[module]; file != "" && file != name {
+			logOverride(logger, fmt.Sprintf("%s模块的选择 %s（数据库中为 %s）", module, file, name))
+		}
+		selected[module] = name
+	}
+	config.SelectedModule = selected
+
+	if overrides[overridePrompt] {
+		if config.DefaultPrompt != "" && config.DefaultPrompt != system.Prompt {
+			logOverride(logger, "默认提示词")
+		}
+		config.DefaultPrompt = system.Prompt
+	}
+
+	if overrides[overrideQuickReplyWords] {
+		var words []string
+		if err := json.Unmarshal(system.QuickReplyWords, &words); err != nil {
+			logger.Warn("解析快速回复词失败: %v", err)
+		} else {
+			if len(config.QuickReplyWords) > 0 && !reflect.DeepEqual(config.QuickReplyWords, words) {
+				logOverride(logger, "快速回复词")
+			}
+			config.QuickReplyWords = words
+		}
+	}
+
+	logger.Info("已加载数据库中的运行时配置")
+	return nil
+}
+
+// logOverride 记录配置文件中被数据库配置覆盖的项
+func logOverride(logger *utils.Logger, item string) {
+	logger.Warn("配置文件中的%s已被通过 /api/cfg 保存的配置覆盖", item)
+}
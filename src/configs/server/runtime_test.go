@@ -0,0 +1,240 @@
+package server
+
+import (
+	"testing"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestLogger(t *testing.T) *utils.Logger {
+	t.Helper()
+	cfg := &configs.Config{}
+	cfg.Log.LogDir = t.TempDir()
+	cfg.Log.LogFile = "test.log"
+	cfg.Log.LogLevel = "ERROR"
+	logger, err := utils.NewLogger(cfg)
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	return logger
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.SystemConfig{}, &models.ModuleConfig{}); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return db
+}
+
+// TestPersistConfigSameName 不同模块的同名提供者应分别保存，互不覆盖
+func TestPersistConfigSameName(t *testing.T) {
+	db := newTestDB(t)
+	config := &configs.Config{
+		SelectedModule: map[string]string{"LLM": "shared", "TTS": "shared"},
+		LLM:            map[string]configs.LLMConfig{"shared": {Type: "openai", ModelName: "gpt-4o"}},
+		TTS:            map[string]configs.TTSConfig{"shared": {Type: "edge", Voice: "zh-CN-XiaoxiaoNeural"}},
+	}
+	req := &CfgUpdateRequest{Providers: map[string]map[string]map[string]interface{}{
+		"LLM": {"shared": {"type": "openai", "model_name": "gpt-4o"}},
+		"TTS": {"shared": {"type": "edge", "voice": "zh-CN-XiaoxiaoNeural"}},
+	}}
+
+	// 保存两次，第二次应更新已有记录
+	for i := 0; i < 2; i++ {
+		if err := persistConfig(db, config, req); err != nil {
+			t.Fatalf("persistConfig() 失败: %v", err)
+		}
+	}
+
+	var records []models.ModuleConfig
+	if err := db.Order("type").Find(&records).Error; err != nil {
+		t.Fatalf("查询模块配置失败: %v", err)
+	}
+	if len(records) != 2 || records[0].Type != "LLM" || records[1].Type != "TTS" {
+		t.Fatalf("保存了 %d 条模块配置 %+v，期望LLM和TTS各一条", len(records), records)
+	}
+
+	loaded := &configs.Config{SelectedModule: map[string]string{}}
+	if err := ApplyPersistedConfig(db, loaded, newTestLogger(t)); err != nil {
+		t.Fatalf("ApplyPersistedConfig() 失败: %v", err)
+	}
+	if got := loaded.LLM["shared"].ModelName; got != "gpt-4o" {
+		t.Errorf("LLM提供者 model_name = %q，期望 %q", got, "gpt-4o")
+	}
+	if got := loaded.TTS["shared"].Voice; got != "zh-CN-XiaoxiaoNeural" {
+		t.Errorf("TTS提供者 voice = %q，期望 %q", got, "zh-CN-XiaoxiaoNeural")
+	}
+}
+
+// TestApplyPersistedConfigPrecedence 通过 /api/cfg 保存的配置优先于配置文件
+func TestApplyPersistedConfigPrecedence(t *testing.T) {
+	db := newTestDB(t)
+	saved := &configs.Config{
+		SelectedModule:  map[string]string{"ASR": "asr", "LLM": "remote", "TTS": "tts"},
+		DefaultPrompt:   "数据库提示词",
+		QuickReplyWords: []string{"好的"},
+		LLM: map[string]configs.LLMConfig{
+			"local":  {Type: "ollama", ModelName: "qwen"},
+			"remote": {Type: "openai", ModelName: "db-model"},
+		},
+	}
+	prompt := saved.DefaultPrompt
+	req := &CfgUpdateRequest{
+		SelectedModule:  map[string]string{"LLM": "remote"},
+		Prompt:          &prompt,
+		QuickReplyWords: saved.QuickReplyWords,
+		Providers:       map[string]map[string]map[string]interface{}{"LLM": {"remote": {"model_name": "db-model"}}},
+	}
+	if err := persistConfig(db, saved, req); err != nil {
+		t.Fatalf("persistConfig() 失败: %v", err)
+	}
+
+	file := &configs.Config{
+		SelectedModule:  map[string]string{"ASR": "asr", "LLM": "local", "TTS": "tts"},
+		DefaultPrompt:   "文件提示词",
+		QuickReplyWords: []string{"收到"},
+		LLM: map[string]configs.LLMConfig{
+			"local":  {Type: "ollama", ModelName: "qwen"},
+			"remote": {Type: "openai", ModelName: "file-model"},
+		},
+	}
+	if err := ApplyPersistedConfig(db, file, newTestLogger(t)); err != nil {
+		t.Fatalf("ApplyPersistedConfig() 失败: %v", err)
+	}
+
+	if got := file.SelectedModule["LLM"]; got != "remote" {
+		t.Errorf("LLM选择 = %q，期望数据库中的 %q", got, "remote")
+	}
+	if got := file.LLM["remote"].ModelName; got != "db-model" {
+		t.Errorf("remote model_name = %q，期望数据库中的 %q", got, "db-model")
+	}
+	if got := file.LLM["local"].ModelName; got != "qwen" {
+		t.Errorf("未保存到数据库的提供者 model_name = %q，期望保留配置文件中的 %q", got, "qwen")
+	}
+	if file.DefaultPrompt != "数据库提示词" || len(file.QuickReplyWords) != 1 || file.QuickReplyWords[0] != "好的" {
+		t.Errorf("提示词 = %q，快速回复词 = %v，期望使用数据库中的配置", file.DefaultPrompt, file.QuickReplyWords)
+	}
+}
+
+// TestPersistConfigOnlyChangedKeys 只保存请求中修改的配置项，其余配置项仍以配置文件为准
+func TestPersistConfigOnlyChangedKeys(t *testing.T) {
+	db := newTestDB(t)
+	running := &configs.Config{
+		SelectedModule: map[string]string{"ASR": "asr", "LLM": "remote", "TTS": "tts"},
+		DefaultPrompt:  "运行中提示词",
+		LLM: map[string]configs.LLMConfig{
+			"remote": {Type: "openai", ModelName: "new-model", APIKey: "sk-file", BaseURL: "https://file"},
+		},
+	}
+	req := &CfgUpdateRequest{Providers: map[string]map[string]map[string]interface{}{
+		"LLM": {"remote": {"model_name": "new-model", "api_key": redactedValue}},
+	}}
+	if err := persistConfig(db, running, req); err != nil {
+		t.Fatalf("persistConfig() 失败: %v", err)
+	}
+
+	var record models.ModuleConfig
+	if err := db.Where("type = ? AND name = ?", "LLM", "remote").First(&record).Error; err != nil {
+		t.Fatalf("查询模块配置失败: %v", err)
+	}
+	if got := string(record.ConfigJSON); got != `{"model_name":"new-model"}` {
+		t.Errorf("保存的提供者配置 = %s，期望只包含修改的 model_name", got)
+	}
+	var count int64
+	db.Model(&models.SystemConfig{}).Count(&count)
+	if count != 0 {
+		t.Errorf("未修改系统配置时保存了 %d 条系统配置", count)
+	}
+
+	// 配置文件中未通过 /api/cfg 修改的项应生效
+	file := &configs.Config{
+		SelectedModule: map[string]string{"ASR": "asr", "LLM": "local", "TTS": "tts"},
+		DefaultPrompt:  "文件提示词",
+		LLM: map[string]configs.LLMConfig{
+			"local":  {Type: "ollama", ModelName: "qwen"},
+			"remote": {Type: "openai", ModelName: "file-model", APIKey: "sk-rotated", BaseURL: "https://edited"},
+		},
+	}
+	if err := ApplyPersistedConfig(db, file, newTestLogger(t)); err != nil {
+		t.Fatalf("ApplyPersistedConfig() 失败: %v", err)
+	}
+	remote := file.LLM["remote"]
+	if remote.ModelName != "new-model" {
+		t.Errorf("model_name = %q，期望数据库中的 %q", remote.ModelName, "new-model")
+	}
+	if remote.APIKey != "sk-rotated" || remote.BaseURL != "https://edited" {
+		t.Errorf("api_key = %q，base_url = %q，期望使用配置文件中的值", remote.APIKey, remote.BaseURL)
+	}
+	if file.SelectedModule["LLM"] != "local" || file.DefaultPrompt != "文件提示词" {
+		t.Errorf("LLM选择 = %q，提示词 = %q，期望使用配置文件中的值", file.SelectedModule["LLM"], file.DefaultPrompt)
+	}
+
+	// 修改提示词只覆盖提示词
+	prompt := "数据库提示词"
+	running.DefaultPrompt = prompt
+	if err := persistConfig(db, running, &CfgUpdateRequest{Prompt: &prompt}); err != nil {
+		t.Fatalf("persistConfig() 失败: %v", err)
+	}
+	file.SelectedModule["LLM"] = "local"
+	if err := ApplyPersistedConfig(db, file, newTestLogger(t)); err != nil {
+		t.Fatalf("ApplyPersistedConfig() 失败: %v", err)
+	}
+	if file.DefaultPrompt != prompt || file.SelectedModule["LLM"] != "local" {
+		t.Errorf("提示词 = %q，LLM选择 = %q，期望只覆盖提示词", file.DefaultPrompt, file.SelectedModule["LLM"])
+	}
+}
+
+// TestClearOverrides 清除后全部以配置文件为准
+func TestClearOverrides(t *testing.T) {
+	db := newTestDB(t)
+	running := &configs.Config{
+		SelectedModule: map[string]string{"ASR": "asr", "LLM": "remote", "TTS": "tts"},
+		DefaultPrompt:  "数据库提示词",
+		LLM:            map[string]configs.LLMConfig{"remote": {Type: "openai", ModelName: "db-model"}},
+	}
+	prompt := running.DefaultPrompt
+	req := &CfgUpdateRequest{
+		SelectedModule: map[string]string{"LLM": "remote"},
+		Prompt:         &prompt,
+		Providers:      map[string]map[string]map[string]interface{}{"LLM": {"remote": {"model_name": "db-model"}}},
+	}
+	if err := persistConfig(db, running, req); err != nil {
+		t.Fatalf("persistConfig() 失败: %v", err)
+	}
+	if err := clearOverrides(db); err != nil {
+		t.Fatalf("clearOverrides() 失败: %v", err)
+	}
+
+	file := &configs.Config{
+		SelectedModule: map[string]string{"ASR": "asr", "LLM": "local", "TTS": "tts"},
+		DefaultPrompt:  "文件提示词",
+		LLM: map[string]configs.LLMConfig{
+			"local":  {Type: "ollama", ModelName: "qwen"},
+			"remote": {Type: "openai", ModelName: "file-model"},
+		},
+	}
+	if err := ApplyPersistedConfig(db, file, newTestLogger(t)); err != nil {
+		t.Fatalf("ApplyPersistedConfig() 失败: %v", err)
+	}
+	if file.SelectedModule["LLM"] != "local" || file.DefaultPrompt != "文件提示词" || file.LLM["remote"].ModelName != "file-model" {
+		t.Errorf("清除后仍在使用数据库中的配置: LLM选择 = %q，提示词 = %q，model_name = %q",
+			file.SelectedModule["LLM"], file.DefaultPrompt, file.LLM["remote"].ModelName)
+	}
+}
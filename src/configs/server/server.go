@@ -2,55 +2,193 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"sync"
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/auth"
 	"xiaozhi-server-go/src/core/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// DefaultCfgService 运行时配置服务，修改后的配置通过 configs.SetCurrent 整体替换
 type DefaultCfgService struct {
-	logger *utils.Logger
-	config *configs.Config
+	logger    *utils.Logger
+	rebuilder PoolRebuilder   // 资源池重建，切换提供者后生效
+	adminAuth gin.HandlerFunc // 配置接口需要管理员令牌
+	mu        sync.Mutex      // 串行化配置更新
 }
 
-// NewDefaultCfgService 构造函数
+// NewDefaultCfgService 构造函数，config 为启动时加载的配置
 func NewDefaultCfgService(config *configs.Config, logger *utils.Logger) (*DefaultCfgService, error) {
+	if configs.Current() == nil {
+		configs.SetCurrent(config)
+	}
 	service := &DefaultCfgService{
 		logger: logger,
-		config: config,
+		adminAuth: auth.AdminMiddleware(func() string {
+			return configs.Current().Server.Auth.AdminToken
+		}),
 	}
 
 	return service, nil
 }
 
+// SetPoolRebuilder 设置资源池重建器
+func (s *DefaultCfgService) SetPoolRebuilder(rebuilder PoolRebuilder) {
+	s.rebuilder = rebuilder
+}
+
 // Start 实现 CfgService 接口，注册所有 Cfg 相关路由
 func (s *DefaultCfgService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
 
-	apiGroup.GET("/cfg", s.handleGet)
-	apiGroup.POST("/cfg", s.handlePost)
+	apiGroup.GET("/cfg", s.adminAuth, s.handleGet)
+	apiGroup.POST("/cfg", s.adminAuth, s.handlePost)
 	apiGroup.OPTIONS("/cfg", s.handleOptions)
+	apiGroup.POST("/cfg/reload", s.adminAuth, s.handleReload)
+	apiGroup.DELETE("/cfg/overrides", s.adminAuth, s.handleClearOverrides)
+
+	go s.watchConfigFile(ctx)
+	go s.watchReloadSignal(ctx)
 
 	s.logger.Info("Cfg HTTP服务路由注册完成")
 	return nil
 }
 
+// handleGet 返回当前生效的配置，敏感信息已脱敏
 func (s *DefaultCfgService) handleGet(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"status":  "ok",
-		"message": "Cfg service is running",
+	config, err := RedactedConfig(configs.Current())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"config": config,
 	})
 }
 
+// handlePost 校验并应用配置修改，重建受影响的资源池并持久化到数据库
 func (s *DefaultCfgService) handlePost(c *gin.Context) {
-	c.JSON(200, gin.H{
+	var req CfgUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "请求格式错误: " + err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, affected, err := applyUpdate(configs.Current(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	rebuilt, err := s.rebuildPools(next, affected)
+	if err != nil {
+		s.logger.Error("应用配置失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	if database.DB != nil {
+		if err := persistConfig(database.DB, next, &req); err != nil {
+			s.logger.Error("保存配置失败: %v", err)
+			s.rollbackPools(rebuilt)
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+	}
+
+	configs.SetCurrent(next)
+	for module, providers := range req.Providers {
+		for name := range providers {
+			s.resetProviderPool(module, name)
+		}
+	}
+	s.logger.Info("配置已更新，重建资源池: %v", affected)
+
+	config, err := RedactedConfig(next)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
-		"message": "Cfg service is running",
+		"rebuilt": affected,
+		"config":  config,
 	})
 }
 
+// handleClearOverrides 清除通过 /api/cfg 保存的配置，并重新加载配置文件使其生效
+func (s *DefaultCfgService) handleClearOverrides(c *gin.Context) {
+	if database.DB != nil {
+		s.mu.Lock()
+		err := clearOverrides(database.DB)
+		s.mu.Unlock()
+		if err != nil {
+			s.logger.Error("清除运行时配置失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		s.logger.Info("已清除通过 /api/cfg 保存的配置")
+	}
+
+	rebuilt, err := s.Reload()
+	if err != nil {
+		s.logger.Error("重新加载配置失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"rebuilt": rebuilt,
+	})
+}
+
+// rebuildPools 按新配置重建资源池，任一失败时恢复已重建的资源池
+func (s *DefaultCfgService) rebuildPools(next *configs.Config, modules []string) ([]string, error) {
+	if len(modules) == 0 {
+		return nil, nil
+	}
+	if s.rebuilder == nil {
+		s.logger.Warn("未设置资源池重建器，提供者切换将在重启后生效")
+		return nil, nil
+	}
+
+	var rebuilt []string
+	for _, module := range modules {
+		if err := s.rebuilder.RebuildPool(module, next); err != nil {
+			s.rollbackPools(rebuilt)
+			return nil, fmt.Errorf("重建%s资源池失败: %v", module, err)
+		}
+		rebuilt = append(rebuilt, module)
+	}
+	return rebuilt, nil
+}
+
+// resetProviderPool 提供者配置变更后，关闭其按需创建的资源池
+func (s *DefaultCfgService) resetProviderPool(module, name string) {
+	if resetter, ok := s.rebuilder.(providerPoolResetter); ok {
+		resetter.ResetProviderPool(module, name)
+	}
+}
+
+// rollbackPools 使用当前配置恢复资源池
+func (s *DefaultCfgService) rollbackPools(modules []string) {
+	for _, module := range modules {
+		if err := s.rebuilder.RebuildPool(module, configs.Current()); err != nil {
+			s.logger.Error("恢复%s资源池失败: %v", module, err)
+		}
+	}
+}
+
 func (s *DefaultCfgService) handleOptions(c *gin.Context) {
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	c.Header("Access-Control-Allow-Headers", "Content-Type")
+	c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
 	c.Status(204) // No Content
 }
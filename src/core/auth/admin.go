@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware 校验管理接口的访问令牌，未配置令牌时拒绝所有请求
+// token 在每次请求时获取，配置热更新后立即生效
+func AdminMiddleware(token func() string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := token()
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "未配置管理员令牌，管理接口已禁用"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(RequestToken(c.Request)), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的管理员令牌"})
+			return
+		}
+		c.Next()
+	}
+}
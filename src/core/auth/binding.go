@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	activationCodeTTL      = 10 * time.Minute // 激活码有效期
+	activationCodeAttempts = 10               // 生成不重复激活码的最大尝试次数
+)
+
+var (
+	// ErrInvalidActivationCode 激活码不存在或已过期
+	ErrInvalidActivationCode = errors.New("激活码无效或已过期")
+	// ErrBindingNotFound 设备绑定记录不存在
+	ErrBindingNotFound = errors.New("设备绑定记录不存在")
+)
+
+// BindingStore 设备绑定存储，负责激活码下发、管理员确认和解绑
+type BindingStore struct {
+	db *gorm.DB
+}
+
+// NewBindingStore 创建设备绑定存储
+func NewBindingStore(db *gorm.DB) *BindingStore {
+	return &BindingStore{db: db}
+}
+
+// Get 查询设备绑定记录，不存在时返回nil
+func (s *BindingStore) Get(deviceID string) (*models.DeviceBinding, error) {
+	var binding models.DeviceBinding
+	err := s.db.Where("device_id = ?", deviceID).First(&binding).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询设备绑定失败: %v", err)
+	}
+	return &binding, nil
+}
+
+// IsBound 判断设备是否已绑定
+func (s *BindingStore) IsBound(deviceID string) (bool, error) {
+	binding, err := s.Get(deviceID)
+	if err != nil || binding == nil {
+		return false, err
+	}
+	return binding.Status == models.DeviceBindingBound, nil
+}
+
+// List 列出所有设备绑定记录
+func (s *BindingStore) List() ([]models.DeviceBinding, error) {
+	var bindings []models.DeviceBinding
+	if err := s.db.Order("id").Find(&bindings).Error; err != nil {
+		return nil, fmt.Errorf("查询设备绑定列表失败: %v", err)
+	}
+	return bindings, nil
+}
+
+// RequestActivationCode 为未绑定设备下发激活码，未过期的激活码会被复用
+func (s *BindingStore) RequestActivationCode(deviceID, clientID string) (string, error) {
+	binding, err := s.Get(deviceID)
+	if err != nil {
+		return "", err
+	}
+	if binding != nil && binding.Status == models.DeviceBindingBound {
+		return "", fmt.Errorf("设备 %s 已绑定", deviceID)
+	}
+	if binding != nil && binding.ActivationCode != "" && time.Now().Before(binding.CodeExpiresAt) {
+		return binding.ActivationCode, nil
+	}
+
+	code, err := s.generateCode()
+	if err != nil {
+		return "", err
+	}
+
+	if binding == nil {
+		binding = &models.DeviceBinding{DeviceID: deviceID}
+	}
+	binding.ClientID = clientID
+	binding.Status = models.DeviceBindingPending
+	binding.ActivationCode = code
+	binding.CodeExpiresAt = time.Now().Add(activationCodeTTL)
+	if err := s.db.Save(binding).Error; err != nil {
+		return "", fmt.Errorf("保存激活码失败: %v", err)
+	}
+	return code, nil
+}
+
+// Confirm 管理员使用激活码确认绑定设备
+func (s *BindingStore) Confirm(code, owner, deviceName string) (*models.DeviceBinding, error) {
+	var binding models.DeviceBinding
+	err := s.db.Where("activation_code = ? AND status = ?", code, models.DeviceBindingPending).First(&binding).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidActivationCode
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询激活码失败: %v", err)
+	}
+	if time.Now().After(binding.CodeExpiresAt) {
+		return nil, ErrInvalidActivationCode
+	}
+
+	now := time.Now()
+	binding.Status = models.DeviceBindingBound
+	binding.Owner = owner
+	if deviceName != "" {
+		binding.DeviceName = deviceName
+	}
+	binding.ActivationCode = ""
+	binding.BoundAt = &now
+	if err := s.db.Save(&binding).Error; err != nil {
+		return nil, fmt.Errorf("保存设备绑定失败: %v", err)
+	}
+	return &binding, nil
+}
+
+// Bind 直接绑定设备，用于自动绑定和配置文件中的预置设备
+func (s *BindingStore) Bind(deviceID, clientID, deviceName, owner string) (*models.DeviceBinding, error) {
+	binding, err := s.Get(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if binding == nil {
+		binding = &models.DeviceBinding{DeviceID: deviceID}
+	}
+
+	now := time.Now()
+	if clientID != "" {
+		binding.ClientID = clientID
+	}
+	if deviceName != "" {
+		binding.DeviceName = deviceName
+	}
+	if owner != "" {
+		binding.Owner = owner
+	}
+	binding.Status = models.DeviceBindingBound
+	binding.ActivationCode = ""
+	binding.BoundAt = &now
+	if err := s.db.Save(binding).Error; err != nil {
+		return nil, fmt.Errorf("保存设备绑定失败: %v", err)
+	}
+	return binding, nil
+}
+
+// Unbind 解除设备绑定并删除记录
+func (s *BindingStore) Unbind(deviceID string) error {
+	result := s.db.Where("device_id = ?", deviceID).Delete(&models.DeviceBinding{})
+	if result.Error != nil {
+		return fmt.Errorf("解绑设备失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrBindingNotFound
+	}
+	return nil
+}
+
+// SyncStaticDevices 将配置文件中预置的设备写入绑定表
+func (s *BindingStore) SyncStaticDevices(devices []configs.DeviceInfo) error {
+	for _, device := range devices {
+		if device.DeviceID == "" {
+			continue
+		}
+		if _, err := s.Bind(device.DeviceID, device.ClientID, device.DeviceName, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateCode 生成6位数字激活码，避免与其他未过期激活码重复
+func (s *BindingStore) generateCode() (string, error) {
+	for i := 0; i < activationCodeAttempts; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err != nil {
+			return "", fmt.Errorf("生成激活码失败: %v", err)
+		}
+		code := fmt.Sprintf("%06d", n.Int64())
+
+		var count int64
+		if err := s.db.Model(&models.DeviceBinding{}).
+			Where("activation_code = ? AND code_expires_at > ?", code, time.Now()).
+			Count(&count).Error; err != nil {
+			return "", fmt.Errorf("检查激活码失败: %v", err)
+		}
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", errors.New("生成激活码失败: 重试次数过多")
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter 限制每个key在时间窗口内的失败次数，用于防止激活码被暴力猜测
+type AttemptLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	failures map[string]*attemptRecord
+}
+
+type attemptRecord struct {
+	count   int
+	resetAt time.Time // 窗口结束时间，之后失败次数清零
+}
+
+// NewAttemptLimiter 创建失败次数限制器，window 内最多允许 limit 次失败
+func NewAttemptLimiter(limit int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{
+		limit:    limit,
+		window:   window,
+		failures: make(map[string]*attemptRecord),
+	}
+}
+
+// Allow 判断key是否还可以尝试，超过限制时返回需要等待的时长
+func (l *AttemptLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, ok := l.failures[key]
+	if !ok {
+		return true, 0
+	}
+	now := time.Now()
+	if !now.Before(record.resetAt) {
+		delete(l.failures, key)
+		return true, 0
+	}
+	if record.count >= l.limit {
+		return false, record.resetAt.Sub(now)
+	}
+	return true, 0
+}
+
+// Fail 记录一次失败，窗口从第一次失败开始计算
+func (l *AttemptLimiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	// 顺带清理过期记录，避免大量不同来源的请求占用内存
+	for k, record := range l.failures {
+		if !now.Before(record.resetAt) {
+			delete(l.failures, k)
+		}
+	}
+	record, ok := l.failures[key]
+	if !ok {
+		record = &attemptRecord{resetAt: now.Add(l.window)}
+		l.failures[key] = record
+	}
+	record.count++
+}
+
+// Reset 清除key的失败记录，成功后调用
+func (l *AttemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
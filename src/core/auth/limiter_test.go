@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		reset     bool
+		wait      time.Duration
+		wantAllow bool
+	}{
+		{name: "没有失败记录", failures: 0, wantAllow: true},
+		{name: "失败次数未达到上限", failures: 2, wantAllow: true},
+		{name: "失败次数达到上限", failures: 3, wantAllow: false},
+		{name: "成功后清除失败记录", failures: 3, reset: true, wantAllow: true},
+		{name: "窗口过期后恢复", failures: 3, wait: 60 * time.Millisecond, wantAllow: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewAttemptLimiter(3, 50*time.Millisecond)
+			for i := 0; i < tt.failures; i++ {
+				l.Fail("127.0.0.1")
+			}
+			if tt.reset {
+				l.Reset("127.0.0.1")
+			}
+			time.Sleep(tt.wait)
+
+			allowed, retryAfter := l.Allow("127.0.0.1")
+			if allowed != tt.wantAllow {
+				t.Fatalf("Allow() = %v，期望 %v", allowed, tt.wantAllow)
+			}
+			if !allowed && (retryAfter <= 0 || retryAfter > 50*time.Millisecond) {
+				t.Errorf("RetryAfter = %v，期望在窗口时长内", retryAfter)
+			}
+			if ok, _ := l.Allow("192.168.1.2"); !ok {
+				t.Error("其他来源不应受影响")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+const defaultTokenExpiry = 30 * 24 * time.Hour // 下发给设备的token默认有效期
+
+var (
+	// ErrMissingToken 请求未携带token
+	ErrMissingToken = errors.New("缺少认证token")
+	// ErrInvalidToken token无效或已过期
+	ErrInvalidToken = errors.New("无效的认证token或token已过期")
+	// ErrDeviceMismatch 请求的设备ID与token不匹配
+	ErrDeviceMismatch = errors.New("设备ID与token不匹配")
+	// ErrDeviceNotAllowed 设备不在允许列表中
+	ErrDeviceNotAllowed = errors.New("设备未被允许接入")
+)
+
+// RequestVerifier 校验设备连接请求携带的token和设备ID
+type RequestVerifier struct {
+	staticTokens   map[string]struct{}
+	allowedDevices map[string]struct{}
+	authToken      *AuthToken
+	tokenExpiry    time.Duration
+}
+
+// NewRequestVerifier 根据服务端认证配置创建请求校验器
+func NewRequestVerifier(config *configs.Config) *RequestVerifier {
+	v := &RequestVerifier{
+		staticTokens:   make(map[string]struct{}),
+		allowedDevices: make(map[string]struct{}),
+		authToken:      NewAuthToken(config.Server.Token),
+		tokenExpiry:    defaultTokenExpiry,
+	}
+	for _, t := range config.Server.Auth.Tokens {
+		if t.Token != "" {
+			v.staticTokens[t.Token] = struct{}{}
+		}
+	}
+	for _, deviceID := range config.Server.Auth.AllowedDevices {
+		v.allowedDevices[deviceID] = struct{}{}
+	}
+	if expiry, err := time.ParseDuration(config.Server.Auth.TokenExpiry); err == nil && expiry > 0 {
+		v.tokenExpiry = expiry
+	}
+	return v
+}
+
+// Verify 校验请求，返回通过认证的设备ID
+// token 从 Authorization 头或 token 查询参数获取，设备ID从 Device-Id 头或 device-id 查询参数获取
+func (v *RequestVerifier) Verify(r *http.Request) (string, error) {
+	token := RequestToken(r)
+	if token == "" {
+		return "", ErrMissingToken
+	}
+
+	deviceID := r.Header.Get("Device-Id")
+	if deviceID == "" {
+		deviceID = r.URL.Query().Get("device-id")
+	}
+
+	if _, ok := v.staticTokens[token]; !ok {
+		valid, tokenDeviceID, err := v.authToken.VerifyToken(token)
+		if err != nil || !valid {
+			return "", ErrInvalidToken
+		}
+		if deviceID == "" {
+			deviceID = tokenDeviceID
+		} else if deviceID != tokenDeviceID {
+			return "", ErrDeviceMismatch
+		}
+	}
+
+	if !v.IsDeviceAllowed(deviceID) {
+		return "", ErrDeviceNotAllowed
+	}
+	return deviceID, nil
+}
+
+// IsDeviceAllowed 判断设备是否在允许列表中，未配置列表时允许所有设备
+func (v *RequestVerifier) IsDeviceAllowed(deviceID string) bool {
+	if len(v.allowedDevices) == 0 {
+		return true
+	}
+	_, ok := v.allowedDevices[deviceID]
+	return ok
+}
+
+// IsDeviceListed 判断设备是否显式配置在允许列表中，未配置列表时返回false
+func (v *RequestVerifier) IsDeviceListed(deviceID string) bool {
+	_, ok := v.allowedDevices[deviceID]
+	return ok
+}
+
+// IssueToken 为设备签发连接token
+func (v *RequestVerifier) IssueToken(deviceID string) (string, error) {
+	if deviceID == "" {
+		return "", fmt.Errorf("设备ID不能为空")
+	}
+	if !v.IsDeviceAllowed(deviceID) {
+		return "", ErrDeviceNotAllowed
+	}
+	return v.authToken.GenerateTokenWithExpiry(deviceID, v.tokenExpiry)
+}
+
+// StatusCode 返回认证错误对应的HTTP状态码
+func StatusCode(err error) int {
+	if errors.Is(err, ErrDeviceNotAllowed) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
+
+// RequestToken 从 Authorization 头或 token 查询参数中提取token
+func RequestToken(r *http.Request) string {
+	if header := strings.TrimSpace(r.Header.Get("Authorization")); header != "" {
+		if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+			return strings.TrimSpace(header[7:])
+		}
+		return header
+	}
+	return r.URL.Query().Get("token")
+}
@@ -24,7 +24,12 @@ func NewAuthToken(secretKey string) *AuthToken {
 
 func (at *AuthToken) GenerateToken(deviceID string) (string, error) {
 	// 设置过期时间为1小时后
-	expireTime := time.Now().Add(time.Hour)
+	return at.GenerateTokenWithExpiry(deviceID, time.Hour)
+}
+
+// GenerateTokenWithExpiry 生成指定有效期的设备token
+func (at *AuthToken) GenerateTokenWithExpiry(deviceID string, expiry time.Duration) (string, error) {
+	expireTime := time.Now().Add(expiry)
 
 	// 创建claims
 	claims := jwt.MapClaims{
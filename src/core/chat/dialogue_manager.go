@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"context"
 	"encoding/json"
 
 	"xiaozhi-server-go/src/core/types"
@@ -69,6 +70,45 @@ func (dm *DialogueManager) GetLLMDialogue() []Message {
 	return dm.dialogue
 }
 
+// SetMemory 设置对话记忆
+func (dm *DialogueManager) SetMemory(memory MemoryInterface) {
+	dm.memory = memory
+}
+
+// HasMemory 是否已设置对话记忆
+func (dm *DialogueManager) HasMemory() bool {
+	return dm.memory != nil
+}
+
+// QueryMemory 查询与query相关的历史记忆，未设置记忆时返回空
+func (dm *DialogueManager) QueryMemory(query string) (string, error) {
+	if dm.memory == nil {
+		return "", nil
+	}
+	return dm.memory.QueryMemory(query)
+}
+
+// SaveMemory 将当前对话保存到记忆
+func (dm *DialogueManager) SaveMemory(ctx context.Context) error {
+	if save := dm.MemorySaver(); save != nil {
+		return save(ctx)
+	}
+	return nil
+}
+
+// MemorySaver 复制当前对话，返回稍后将其保存到记忆的函数，未设置记忆时返回nil
+func (dm *DialogueManager) MemorySaver() func(ctx context.Context) error {
+	if dm.memory == nil {
+		return nil
+	}
+	memory := dm.memory
+	dialogue := make([]Message, len(dm.dialogue))
+	copy(dialogue, dm.dialogue)
+	return func(ctx context.Context) error {
+		return memory.SaveMemory(ctx, dialogue)
+	}
+}
+
 // GetLLMDialogueWithMemory 获取带记忆的对话
 func (dm *DialogueManager) GetLLMDialogueWithMemory(memoryStr string) []Message {
 	if memoryStr == "" {
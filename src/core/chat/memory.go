@@ -1,12 +1,14 @@
 package chat
 
+import "context"
+
 // MemoryInterface 定义对话记忆管理接口
 type MemoryInterface interface {
 	// QueryMemory 查询相关记忆
 	QueryMemory(query string) (string, error)
 
-	// SaveMemory 保存对话记忆
-	SaveMemory(dialogue []Message) error
+	// SaveMemory 保存对话记忆，ctx 取消时放弃保存
+	SaveMemory(ctx context.Context, dialogue []Message) error
 
 	// ClearMemory 清空记忆
 	ClearMemory() error
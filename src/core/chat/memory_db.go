@@ -0,0 +1,163 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	summarizeTimeout = 60 * time.Second // 会话摘要生成超时
+	summaryMaxRunes  = 1000             // 摘要最大字数，超出后截断
+)
+
+const summarizePrompt = `你是一个对话记忆整理助手。请根据【已有记忆】和【本次对话】，整理出一份更新后的用户记忆摘要。
+要求：
+1. 重点保留用户的个人信息、偏好、习惯、重要事件和未完成的约定；
+2. 合并重复内容，新信息与旧信息冲突时以新信息为准；
+3. 使用简洁的中文分条列出，总字数不超过300字；
+4. 只输出摘要内容，不要输出其他说明。`
+
+// DBMemory 基于数据库的对话记忆，按设备ID保存历史会话摘要
+type DBMemory struct {
+	db       *gorm.DB
+	deviceID string
+	llm      types.LLMProvider
+	logger   *utils.Logger
+}
+
+// NewDBMemory 创建数据库对话记忆，llm用于在保存时生成会话摘要
+func NewDBMemory(db *gorm.DB, deviceID string, llm types.LLMProvider, logger *utils.Logger) *DBMemory {
+	return &DBMemory{
+		db:       db,
+		deviceID: deviceID,
+		llm:      llm,
+		logger:   logger,
+	}
+}
+
+// QueryMemory 查询设备的历史会话摘要
+func (m *DBMemory) QueryMemory(query string) (string, error) {
+	summary, err := m.loadSummary()
+	if err != nil || summary == "" {
+		return "", err
+	}
+	return "以下是你与该用户以往对话的记忆摘要，回答时可参考：\n" + summary, nil
+}
+
+// SaveMemory 使用LLM将本次对话与已有记忆合并为新的摘要并保存
+func (m *DBMemory) SaveMemory(ctx context.Context, dialogue []Message) error {
+	conversation := formatConversation(dialogue)
+	if conversation == "" {
+		return nil
+	}
+
+	previous, err := m.loadSummary()
+	if err != nil {
+		return err
+	}
+
+	summary, err := m.summarize(ctx, previous, conversation)
+	if err != nil {
+		return err
+	}
+	if summary == "" {
+		return nil
+	}
+
+	record := models.DeviceMemory{DeviceID: m.deviceID}
+	if err := m.db.Where("device_id = ?", m.deviceID).
+		Assign(models.DeviceMemory{Summary: summary}).
+		FirstOrCreate(&record).Error; err != nil {
+		return fmt.Errorf("保存对话记忆失败: %v", err)
+	}
+
+	m.logger.Info("设备 %s 的对话记忆已更新", m.deviceID)
+	return nil
+}
+
+// ClearMemory 清空设备的对话记忆
+func (m *DBMemory) ClearMemory() error {
+	if err := m.db.Where("device_id = ?", m.deviceID).Delete(&models.DeviceMemory{}).Error; err != nil {
+		return fmt.Errorf("清空对话记忆失败: %v", err)
+	}
+	return nil
+}
+
+func (m *DBMemory) loadSummary() (string, error) {
+	var record models.DeviceMemory
+	err := m.db.Where("device_id = ?", m.deviceID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询对话记忆失败: %v", err)
+	}
+	return record.Summary, nil
+}
+
+// summarize 调用LLM生成会话摘要
+func (m *DBMemory) summarize(ctx context.Context, previous string, conversation string) (string, error) {
+	if previous == "" {
+		previous = "无"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, summarizeTimeout)
+	defer cancel()
+
+	messages := []Message{
+		{Role: "system", Content: summarizePrompt},
+		{Role: "user", Content: fmt.Sprintf("【已有记忆】\n%s\n\n【本次对话】\n%s", previous, conversation)},
+	}
+	responses, err := m.llm.Response(ctx, "memory-"+m.deviceID, messages)
+	if err != nil {
+		return "", fmt.Errorf("生成会话摘要失败: %v", err)
+	}
+
+	var builder strings.Builder
+	for content := range responses {
+		builder.WriteString(content)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("生成会话摘要失败: %v", err)
+	}
+	summary := strings.TrimSpace(builder.String())
+	if strings.Contains(summary, "服务响应异常") {
+		return "", fmt.Errorf("生成会话摘要失败: %s", summary)
+	}
+
+	if runes := []rune(summary); len(runes) > summaryMaxRunes {
+		summary = string(runes[:summaryMaxRunes])
+	}
+	return summary, nil
+}
+
+// formatConversation 将对话中的用户与助手消息整理为文本，忽略系统和工具消息
+func formatConversation(dialogue []Message) string {
+	var builder strings.Builder
+	hasUser := false
+	for _, msg := range dialogue {
+		if msg.Content == "" {
+			continue
+		}
+		switch msg.Role {
+		case "user":
+			hasUser = true
+			builder.WriteString("用户：" + msg.Content + "\n")
+		case "assistant":
+			builder.WriteString("助手：" + msg.Content + "\n")
+		}
+	}
+	if !hasUser {
+		return ""
+	}
+	return builder.String()
+}
@@ -2,7 +2,6 @@ package core
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,19 +12,25 @@ import (
 	"time"
 
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/auth"
 	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/device"
 	"xiaozhi-server-go/src/core/function"
 	"xiaozhi-server-go/src/core/image"
 	"xiaozhi-server-go/src/core/mcp"
+	"xiaozhi-server-go/src/core/metrics"
 	"xiaozhi-server-go/src/core/pool"
 	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/tts"
 	"xiaozhi-server-go/src/core/providers/vlllm"
+	"xiaozhi-server-go/src/core/push"
 	"xiaozhi-server-go/src/core/types"
 	"xiaozhi-server-go/src/core/utils"
 	"xiaozhi-server-go/src/task"
 
 	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
 )
 
 var WsConnMap = make(map[string]*ConnectionHandler) // 全局连接池
@@ -68,21 +73,25 @@ type ConnectionHandler struct {
 	conn             Connection
 	closeOnce        sync.Once
 	taskMgr          *task.TaskManager
+	poolManager      *pool.PoolManager // 按用户设置切换提供者时使用
+	providerSet      *pool.ProviderSet
 	safeCallbackFunc func(func(*ConnectionHandler)) func()
 	providers        struct {
 		asr   providers.ASRProvider
 		llm   providers.LLMProvider
 		tts   providers.TTSProvider
-		vlllm *vlllm.Provider // VLLLM提供者，可选
+		vad   providers.VADProvider // VAD提供者，可选
+		vlllm *vlllm.Provider       // VLLLM提供者，可选
 	}
 
 	initailVoice string // 初始语音名称
 
 	// 会话相关
-	sessionID string
-	deviceID  string            // 设备ID
-	clientId  string            // 客户端ID
-	headers   map[string]string // HTTP头部信息
+	sessionID    string
+	deviceID     string            // 设备ID
+	authDeviceID string            // 握手认证通过的设备ID，非空时以其为准
+	clientId     string            // 客户端ID
+	headers      map[string]string // HTTP头部信息
 
 	// 客户端音频相关
 	clientAudioFormat        string
@@ -95,46 +104,63 @@ type ConnectionHandler struct {
 	serverAudioChannels      int
 	serverAudioFrameDuration int
 
-	clientListenMode string
-	isDeviceVerified bool
-	closeAfterChat   bool
+	lastActiveTime   int64 // 最近一次收到客户端消息的时间（UnixNano）
+	connectedAt      time.Time
+	isDeviceVerified int32 // 1表示设备已绑定认证
 
 	// 语音处理相关
-	clientVoiceStop bool  // true客户端语音停止, 不再上传语音数据
 	serverVoiceStop int32 // 1表示true服务端语音停止, 不再下发语音数据
+	speechEndTime   int64 // 语句结束时间（UnixNano），用于统计ASR耗时
 
 	opusDecoder *utils.OpusDecoder // Opus解码器
 
 	// 对话相关
-	dialogueManager     *chat.DialogueManager
-	tts_last_text_index int
-	client_asr_text     string // 客户端ASR文本
-	quickReplyCache     *utils.QuickReplyCache
+	dialogueManager *chat.DialogueManager
+	memoryPrompt    string    // 设备历史会话记忆摘要
+	memorySaveOnce  sync.Once // 确保会话记忆只保存一次
+	quickReplyWords []string  // 快速回复词，可被用户设置覆盖
+	quickReplyCache *utils.QuickReplyCache
+
+	// 最近一轮对话的文本，供 MCP 服务端读取
+	transcriptMu sync.Mutex
+	transcript   device.Transcript
 
 	// 并发控制
+	workersOnce      sync.Once
+	workersStarted   int32 // 1表示音频、TTS协程已启动，此后不再切换提供者
 	stopChan         chan struct{}
 	clientAudioQueue chan []byte
 	clientTextQueue  chan string
 
 	// TTS任务队列
-	ttsQueue chan struct {
-		text      string
-		round     int // 轮次
-		textIndex int
-	}
-
-	audioMessagesQueue chan struct {
-		filepath  string
-		text      string
-		round     int // 轮次
-		textIndex int
-	}
-
-	talkRound      int       // 轮次计数
-	roundStartTime time.Time // 轮次开始时间
+	ttsQueue           chan ttsTask
+	audioMessagesQueue chan audioTask
+
+	// 会话状态，以下字段由 stateMu 保护，状态变化统一通过 fire 串行处理
+	stateMu             sync.Mutex
+	hookMu              sync.Mutex // 保证状态变化回调按顺序执行
+	state               SessionState
+	stateSince          time.Time // 进入当前状态的时间
+	stateHooks          []func(from, to SessionState)
+	clientListenMode    string
+	clientListening     bool   // 客户端处于拾音状态
+	clientVoiceStop     bool   // true客户端语音停止, 不再上传语音数据
+	client_asr_text     string // 客户端ASR文本
+	closeAfterChat      bool
+	talkRound           int       // 轮次计数
+	roundStartTime      time.Time // 轮次开始时间
+	roundCtx            context.Context
+	roundCancel         context.CancelFunc // 打断、新轮次或关闭连接时取消当前轮次
+	tts_last_text_index int                // 当前轮次最后一句的索引，-1表示尚未确定
+
+	// 主动推送
+	pushMu      sync.Mutex
+	pushPlaying *pushPlayback // 正在播放的推送
 	// functions
 	functionRegister *function.FunctionRegistry
 	mcpManager       *mcp.Manager
+	hardware         *function.DeviceHardwareController // 通过设备执行硬件控制函数，未启用时为nil
+	iot              *function.IoTDevice                // 设备上报的IoT描述符和状态
 
 	mcpResultHandlers map[string]func(interface{}) // MCP处理器映射
 	ctx               context.Context
@@ -146,7 +172,7 @@ func (h *ConnectionHandler) SessionID() string {
 
 // GetTalkRound returns the current talk round
 func (h *ConnectionHandler) GetTalkRound() int {
-	return h.talkRound
+	return h.currentRound()
 }
 
 // GetDeviceID returns the device ID
@@ -159,6 +185,84 @@ func (h *ConnectionHandler) GetClientID() string {
 	return h.clientId
 }
 
+// ListenState 返回客户端拾音模式及是否正在拾音
+func (h *ConnectionHandler) ListenState() (string, bool) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	return h.clientListenMode, h.clientListening
+}
+
+// LastActiveTime 返回最近一次收到客户端消息的时间
+func (h *ConnectionHandler) LastActiveTime() time.Time {
+	if t := atomic.LoadInt64(&h.lastActiveTime); t != 0 {
+		return time.Unix(0, t)
+	}
+	return h.connectedAt
+}
+
+// Abort 服务端主动中止当前播报，并通知客户端停止播放
+func (h *ConnectionHandler) Abort() error {
+	h.LogInfo("收到服务端中止指令，停止播报")
+	h.stopServerSpeak()
+	err := h.sendTTSMessage("stop", "", 0)
+	h.clearSpeakStatus()
+	return err
+}
+
+// Disconnect 强制断开连接
+func (h *ConnectionHandler) Disconnect() {
+	h.LogInfo("连接被强制断开")
+	h.Close()
+	if h.conn != nil {
+		h.conn.Close()
+	}
+}
+
+// LastTranscript 返回最近一轮对话的文本
+func (h *ConnectionHandler) LastTranscript() device.Transcript {
+	h.transcriptMu.Lock()
+	defer h.transcriptMu.Unlock()
+	return h.transcript
+}
+
+// recordTranscript 记录对话文本，question 非空时开始新的一轮
+func (h *ConnectionHandler) recordTranscript(question, answer string) {
+	h.transcriptMu.Lock()
+	defer h.transcriptMu.Unlock()
+	if question != "" {
+		h.transcript = device.Transcript{Question: question}
+	}
+	if answer != "" {
+		h.transcript.Answer = answer
+	}
+	h.transcript.UpdatedAt = time.Now()
+}
+
+// DeviceTools 返回设备端通过 MCP 上报的工具
+func (h *ConnectionHandler) DeviceTools() []openai.Tool {
+	if h.mcpManager == nil || h.mcpManager.XiaoZhiMCPClient == nil {
+		return nil
+	}
+	return h.mcpManager.XiaoZhiMCPClient.GetAvailableTools()
+}
+
+// CallDeviceTool 调用设备端 MCP 工具
+func (h *ConnectionHandler) CallDeviceTool(ctx context.Context, name string, args map[string]interface{}) (interface{}, error) {
+	if h.mcpManager == nil || h.mcpManager.XiaoZhiMCPClient == nil {
+		return nil, fmt.Errorf("设备未启用MCP")
+	}
+	return h.mcpManager.XiaoZhiMCPClient.CallTool(ctx, name, args)
+}
+
+// registerDevice 将设备连接登记到设备注册表
+func (h *ConnectionHandler) registerDevice() {
+	device.Default().Connect(h.deviceID, h, device.ConnectInfo{
+		ClientID:    h.clientId,
+		DeviceName:  h.headers["Device-Name"],
+		ConnectedAt: h.connectedAt,
+	})
+}
+
 // GetHeaders returns the headers map
 func (h *ConnectionHandler) GetHeaders() map[string]string {
 	return h.headers
@@ -171,29 +275,24 @@ func NewConnectionHandler(
 	logger *utils.Logger,
 	req *http.Request,
 	ctx context.Context,
+	authDeviceID string,
 ) *ConnectionHandler {
 	handler := &ConnectionHandler{
-		config:           config,
-		logger:           logger,
-		clientListenMode: "auto",
-		stopChan:         make(chan struct{}),
-		clientAudioQueue: make(chan []byte, 100),
-		clientTextQueue:  make(chan string, 100),
-		ttsQueue: make(chan struct {
-			text      string
-			round     int // 轮次
-			textIndex int
-		}, 100),
-		audioMessagesQueue: make(chan struct {
-			filepath  string
-			text      string
-			round     int // 轮次
-			textIndex int
-		}, 100),
+		config:             config,
+		logger:             logger,
+		clientListenMode:   "auto",
+		connectedAt:        time.Now(),
+		stopChan:           make(chan struct{}),
+		clientAudioQueue:   make(chan []byte, 100),
+		clientTextQueue:    make(chan string, 100),
+		ttsQueue:           make(chan ttsTask, 100),
+		audioMessagesQueue: make(chan audioTask, 100),
 
 		tts_last_text_index: -1,
 
-		talkRound: 0,
+		talkRound:  0,
+		state:      StateIdle,
+		stateSince: time.Now(),
 
 		serverAudioFormat:        "opus", // 默认使用Opus格式
 		serverAudioSampleRate:    24000,
@@ -223,6 +322,12 @@ func NewConnectionHandler(
 		handler.clientId = clientID
 	}
 
+	// 认证通过的设备ID优先于请求头中的设备ID
+	if authDeviceID != "" {
+		handler.authDeviceID = authDeviceID
+		handler.deviceID = authDeviceID
+	}
+
 	if handler.sessionID == "" {
 		if handler.deviceID == "" {
 			handler.sessionID = uuid.New().String() // 如果没有设备ID，则生成新的会话ID
@@ -233,33 +338,32 @@ func NewConnectionHandler(
 
 	// 正确设置providers
 	if providerSet != nil {
+		handler.providerSet = providerSet
 		handler.providers.asr = providerSet.ASR
 		handler.providers.llm = providerSet.LLM
 		handler.providers.tts = providerSet.TTS
+		handler.providers.vad = providerSet.VAD
 		handler.providers.vlllm = providerSet.VLLLM
 		handler.mcpManager = providerSet.MCP
 	}
 
-	ttsProvider := "default" // 默认TTS提供者名称
-	voiceName := "default"
-	if getter, ok := handler.providers.tts.(configGetter); ok {
-		ttsProvider = getter.Config().Type
-		voiceName = getter.Config().Voice
-		handler.initailVoice = voiceName // 保存初始语音名称
-	}
-	logger.Info("使用TTS提供者: %s, 语音名称: %s", ttsProvider, voiceName)
-	handler.quickReplyCache = utils.NewQuickReplyCache(ttsProvider, voiceName)
+	handler.initTTSVoice()
 
 	// 初始化对话管理器
 	handler.dialogueManager = chat.NewDialogueManager(handler.logger, nil)
 	handler.dialogueManager.SetSystemMessage(config.DefaultPrompt)
+	handler.quickReplyWords = config.QuickReplyWords
+	handler.initDeviceMemory()
+	handler.loadDeviceBinding()
 	handler.functionRegister = function.NewFunctionRegistry()
 	handler.initMCPResultHandlers()
+	handler.OnStateChange(handler.notifyPushWhenIdle)
 	WsConnMapLock.Lock()
 	if _, exists := WsConnMap[handler.sessionID]; !exists {
 		WsConnMap[handler.sessionID] = handler
 	}
 	WsConnMapLock.Unlock()
+	handler.registerDevice()
 
 	if handler.clientId != "" {
 		MacSessionMapLock.Lock()
@@ -323,11 +427,8 @@ func (h *ConnectionHandler) Handle(conn Connection) {
 
 	h.conn = conn
 
-	// 启动消息处理协程
-	go h.processClientAudioMessagesCoroutine() // 添加客户端音频消息处理协程
-	go h.processClientTextMessagesCoroutine()  // 添加客户端文本消息处理协程
-	go h.processTTSQueueCoroutine()            // 添加TTS队列处理协程
-	go h.sendAudioMessageCoroutine()           // 添加音频消息发送协程
+	// 启动文本消息处理协程，音频和TTS协程在应用用户设置后由 startWorkers 启动
+	go h.processClientTextMessagesCoroutine()
 
 	// 优化后的MCP管理器处理
 	if h.mcpManager == nil {
@@ -351,6 +452,8 @@ func (h *ConnectionHandler) Handle(conn Connection) {
 		// 不需要重新初始化服务器，只需要确保连接相关的服务正常
 		h.LogInfo("MCP管理器连接绑定完成，跳过重复初始化")
 	}
+	h.initHardwareFunctions(conn)
+	h.iot = function.NewIoTDevice(conn, h.sessionID, h.functionRegister)
 
 	// 主消息循环
 	for {
@@ -371,6 +474,18 @@ func (h *ConnectionHandler) Handle(conn Connection) {
 	}
 }
 
+// startWorkers 启动音频消息处理、TTS队列和音频发送协程。
+// 用户设置可能切换ASR/LLM/TTS提供者，需在这些协程读取提供者之前于文本协程中完成，
+// 因此在处理hello时应用用户设置后启动；客户端未发送hello就发送其他文本消息时使用默认提供者启动
+func (h *ConnectionHandler) startWorkers() {
+	h.workersOnce.Do(func() {
+		atomic.StoreInt32(&h.workersStarted, 1)
+		go h.processClientAudioMessagesCoroutine() // 添加客户端音频消息处理协程
+		go h.processTTSQueueCoroutine()            // 添加TTS队列处理协程
+		go h.sendAudioMessageCoroutine()           // 添加音频消息发送协程
+	})
+}
+
 // processClientTextMessagesCoroutine 处理文本消息队列
 func (h *ConnectionHandler) processClientTextMessagesCoroutine() {
 	for {
@@ -378,75 +493,234 @@ func (h *ConnectionHandler) processClientTextMessagesCoroutine() {
 		case <-h.stopChan:
 			return
 		case text := <-h.clientTextQueue:
-			if err := h.processClientTextMessage(context.Background(), text); err != nil {
+			if err := h.processClientTextMessage(h.ctx, text); err != nil {
 				h.LogError(fmt.Sprintf("处理文本数据失败: %v", err))
 			}
 		}
 	}
 }
 
+// initTTSVoice 记录TTS初始语音并创建对应的快速回复缓存
+func (h *ConnectionHandler) initTTSVoice() {
+	ttsProvider := "default" // 默认TTS提供者名称
+	voiceName := "default"
+	if getter, ok := h.providers.tts.(configGetter); ok {
+		ttsProvider = getter.Config().Type
+		voiceName = getter.Config().Voice
+		h.initailVoice = voiceName // 保存初始语音名称
+	}
+	h.logger.Info("使用TTS提供者: %s, 语音名称: %s", ttsProvider, voiceName)
+	h.quickReplyCache = utils.NewQuickReplyCache(ttsProvider, voiceName)
+}
+
+// initDeviceMemory 为已知设备挂载数据库对话记忆，并加载历史会话摘要
+func (h *ConnectionHandler) initDeviceMemory() {
+	if database.DB == nil || h.deviceID == "" || h.providers.llm == nil || h.dialogueManager.HasMemory() {
+		return
+	}
+
+	h.dialogueManager.SetMemory(chat.NewDBMemory(database.DB, h.deviceID, h.providers.llm, h.logger))
+	memory, err := h.dialogueManager.QueryMemory("")
+	if err != nil {
+		h.LogError(fmt.Sprintf("加载设备对话记忆失败: %v", err))
+		return
+	}
+	if memory != "" {
+		h.memoryPrompt = memory
+		h.LogInfo(fmt.Sprintf("已加载设备 %s 的历史对话记忆", h.deviceID))
+	}
+}
+
+// llmDialogue 获取注入历史记忆后的对话
+func (h *ConnectionHandler) llmDialogue() []providers.Message {
+	prompt := h.memoryPrompt
+	if h.config.IoT.StatePrompt {
+		if states := h.iot.StatesPrompt(); states != "" {
+			if prompt != "" {
+				prompt += "\n\n"
+			}
+			prompt += states
+		}
+	}
+	return h.dialogueManager.GetLLMDialogueWithMemory(prompt)
+}
+
+// saveMemory 连接结束时在后台总结本次会话并保存，对话在调用时复制，不阻塞连接关闭和资源归还；
+// 摘要生成受 ctx 限制，服务关闭时放弃，wg 用于服务关闭时等待保存结束。
+// 摘要使用的LLM可能已归还资源池，LLM提供者按sessionID区分会话，可以与其他连接并发调用
+func (h *ConnectionHandler) saveMemory(ctx context.Context, wg *sync.WaitGroup) {
+	h.memorySaveOnce.Do(func() {
+		save := h.dialogueManager.MemorySaver()
+		if save == nil {
+			return
+		}
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := save(ctx); err != nil {
+				h.LogError(fmt.Sprintf("保存设备对话记忆失败: %v", err))
+			}
+		}()
+	})
+}
+
 // processClientAudioMessagesCoroutine 处理音频消息队列
 func (h *ConnectionHandler) processClientAudioMessagesCoroutine() {
+	// 连续失败或熔断期间只记录一次，避免逐帧刷屏和逐帧计入失败率
+	asrFailed, asrRejected := false, false
 	for {
 		select {
 		case <-h.stopChan:
 			return
 		case audioData := <-h.clientAudioQueue:
-			if h.closeAfterChat {
+			if h.shouldCloseAfterChat() {
+				continue
+			}
+			breaker := h.breaker("ASR")
+			if err := breaker.Allow(); err != nil {
+				if !asrRejected {
+					h.LogError(fmt.Sprintf("丢弃音频数据: %v", err))
+					asrRejected = true
+				}
 				continue
 			}
+			asrRejected = false
 			if err := h.providers.asr.AddAudio(audioData); err != nil {
-				h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
+				if !asrFailed {
+					h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
+					breaker.Record(err)
+					asrFailed = true
+				}
+			} else {
+				asrFailed = false
 			}
+			h.detectSpeechEnd(audioData)
 		}
 	}
 }
 
+// detectSpeechEnd auto模式下使用服务端VAD判断语句结束，并通知ASR尽快给出最终结果；
+// 启动时已拒绝不支持主动结束语句的ASR，用户设置切换到此类ASR时由ASR自身判断语句结束
+func (h *ConnectionHandler) detectSpeechEnd(audioData []byte) {
+	if h.providers.vad == nil || h.listenMode() != "auto" {
+		return
+	}
+	finalizer, ok := h.providers.asr.(providers.ASRFinalizer)
+	if !ok {
+		return
+	}
+	// 仅对PCM数据做检测，opus解码器未就绪时队列中是原始opus数据
+	if h.clientAudioFormat != "pcm" && h.opusDecoder == nil {
+		return
+	}
+
+	if _, err := h.providers.vad.ProcessAudio(audioData); err != nil {
+		h.LogError(fmt.Sprintf("VAD检测失败: %v", err))
+		return
+	}
+	if !h.providers.vad.IsSpeechEnd() {
+		return
+	}
+
+	h.providers.vad.Reset()
+	h.markSpeechEnd()
+	h.LogInfo("VAD检测到语句结束，提交ASR最终识别")
+	if err := finalizer.Finalize(); err != nil {
+		h.LogError(fmt.Sprintf("结束ASR识别失败: %v", err))
+	}
+}
+
 func (h *ConnectionHandler) sendAudioMessageCoroutine() {
 	for {
 		select {
 		case <-h.stopChan:
 			return
 		case task := <-h.audioMessagesQueue:
-			h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round)
+			if task.frames != nil {
+				h.sendAudioStream(task.frames, task.cancel, task.text, task.textIndex, task.round)
+			} else {
+				h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round)
+			}
+		}
+	}
+}
+
+// markSpeechEnd 记录语句结束时间
+func (h *ConnectionHandler) markSpeechEnd() {
+	atomic.StoreInt64(&h.speechEndTime, time.Now().UnixNano())
+}
+
+// observeASRLatency 统计语句结束到获得最终识别结果的耗时
+func (h *ConnectionHandler) observeASRLatency() {
+	endTime := atomic.SwapInt64(&h.speechEndTime, 0)
+	if endTime == 0 {
+		return
+	}
+	spentTime := time.Since(time.Unix(0, endTime))
+	metrics.ASRLatency.Observe(spentTime.Seconds(), h.providerName("ASR"))
+}
+
+// providerName 返回当前连接使用的指定模块提供者名称
+func (h *ConnectionHandler) providerName(module string) string {
+	if h.providerSet != nil {
+		if name := h.providerSet.ProviderName(module); name != "" {
+			return name
 		}
 	}
+	return h.config.SelectedModule[module]
+}
+
+// breaker 返回当前连接使用的指定模块提供者的熔断器，未启用时返回nil（nil熔断器始终放行）
+func (h *ConnectionHandler) breaker(module string) *pool.CircuitBreaker {
+	if h.providerSet == nil {
+		return nil
+	}
+	return h.providerSet.Breaker(module)
 }
 
 // OnAsrResult 实现 AsrEventListener 接口
 // 返回true则停止语音识别，返回false会继续语音识别
 func (h *ConnectionHandler) OnAsrResult(result string) bool {
-	//h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
+	if result != "" {
+		h.breaker("ASR").Record(nil)
+	}
 	if h.providers.asr.GetSilenceCount() >= 2 {
 		h.LogInfo("检测到连续两次静音，结束对话")
-		h.closeAfterChat = true // 如果连续两次静音，则结束对话
+		h.setCloseAfterChat() // 如果连续两次静音，则结束对话
 		result = "长时间未检测到用户说话，请礼貌的结束对话"
 	}
-	if h.clientListenMode == "auto" {
+	mode := h.listenMode()
+	if mode == "auto" {
 		if result == "" {
 			return false
 		}
-		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
-		h.handleChatMessage(context.Background(), result)
+		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", mode, result))
+		h.observeASRLatency()
+		h.handleChatMessage(h.ctx, result)
 		return true
-	} else if h.clientListenMode == "manual" {
-		h.client_asr_text += result
+	} else if mode == "manual" {
+		text, voiceStop := h.appendAsrText(result)
 		if result != "" {
-			h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, h.client_asr_text))
+			h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", mode, text))
 		}
-		if h.clientVoiceStop {
-			h.handleChatMessage(context.Background(), h.client_asr_text)
+		if voiceStop {
+			h.observeASRLatency()
+			h.handleChatMessage(h.ctx, text)
 			return true
 		}
 		return false
-	} else if h.clientListenMode == "realtime" {
+	} else if mode == "realtime" {
 		if result == "" {
 			return false
 		}
 		h.stopServerSpeak()
 		h.providers.asr.Reset() // 重置ASR状态，准备下一次识别
-		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
-		h.handleChatMessage(context.Background(), result)
+		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", mode, result))
+		h.observeASRLatency()
+		h.handleChatMessage(h.ctx, result)
 		return true
 	}
 	return false
@@ -483,17 +757,18 @@ func (h *ConnectionHandler) QuitIntent(text string) bool {
 
 func (h *ConnectionHandler) quickReplyWakeUpWords(text string) bool {
 	// 检查是否包含唤醒词
-	if !h.config.QuickReply || h.talkRound != 1 {
+	round := h.currentRound()
+	if !h.config.QuickReply || round != 1 {
 		return false
 	}
 	if !utils.IsWakeUpWord(text) {
 		return false
 	}
 
-	repalyWords := h.config.QuickReplyWords
+	repalyWords := h.quickReplyWords
 	reply_text := utils.RandomSelectFromArray(repalyWords)
-	h.tts_last_text_index = 1 // 重置文本索引
-	h.SpeakAndPlay(reply_text, 1, h.talkRound)
+	h.setLastTextIndex(1) // 重置文本索引
+	h.SpeakAndPlay(reply_text, 1, round)
 
 	return true
 }
@@ -510,10 +785,8 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 		return fmt.Errorf("用户请求退出对话")
 	}
 
-	// 增加对话轮次
-	h.talkRound++
-	h.roundStartTime = time.Now()
-	currentRound := h.talkRound
+	// 开始新的对话轮次，取消上一轮仍在进行的回复
+	ctx, currentRound := h.startRound(ctx)
 	h.LogInfo(fmt.Sprintf("开始新的对话轮次: %d", currentRound))
 
 	// 判断是否需要验证
@@ -552,21 +825,74 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 		return nil
 	}
 
+	if module := h.unavailableModule("LLM", "TTS"); module != "" {
+		h.LogError(fmt.Sprintf("%s提供者 %s 健康检查未通过，播放兜底提示", module, h.providerName(module)))
+		h.speakFallback(currentRound)
+		return fmt.Errorf("%s提供者不可用", module)
+	}
+
 	// 添加用户消息到对话历史
 	h.dialogueManager.Put(chat.Message{
 		Role:    "user",
 		Content: text,
 	})
+	h.recordTranscript(text, "")
 
-	return h.genResponseByLLM(ctx, h.dialogueManager.GetLLMDialogue(), currentRound)
+	return h.genResponseByLLM(ctx, h.llmDialogue(), currentRound)
+}
+
+// unavailableModule 返回首个未通过健康检查的模块，均可用时返回空
+func (h *ConnectionHandler) unavailableModule(modules ...string) string {
+	if h.providerSet == nil {
+		return ""
+	}
+	for _, module := range modules {
+		if !h.providerSet.Healthy(module) {
+			return module
+		}
+	}
+	return ""
+}
+
+// fallbackText 必需的提供者不可用时播放的提示语
+func (h *ConnectionHandler) fallbackText() string {
+	if text := h.config.ConnectivityCheck.FallbackText; text != "" {
+		return text
+	}
+	return pool.DefaultFallbackText
+}
+
+// speakFallback 播放兜底提示语，提示语合成后会缓存，TTS不可用时使用缓存音频；
+// 没有缓存时只下发文本，由设备显示
+func (h *ConnectionHandler) speakFallback(round int) {
+	text := h.fallbackText()
+	h.setLastTextIndex(1) // 重置文本索引
+	ttsDown := h.unavailableModule("TTS") != "" || h.breaker("TTS").Allow() != nil
+	if ttsDown && h.quickReplyCache.FindCachedAudio(text) == "" {
+		if err := h.sendTTSMessage("sentence_start", text, 1); err != nil {
+			h.LogError(fmt.Sprintf("发送兜底提示文本失败: %v", err))
+		}
+	}
+	h.SpeakAndPlay(text, 1, round)
+}
+
+// isCachedReply 判断文本是否使用缓存音频：快速回复词和兜底提示语
+func (h *ConnectionHandler) isCachedReply(text string) bool {
+	return utils.IsQuickReplyHit(text, h.quickReplyWords) || text == h.fallbackText()
 }
 
 func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []providers.Message, round int) error {
+	return h.genResponseWithTools(ctx, messages, round, 0, 0)
+}
+
+// genResponseWithTools 生成LLM回复，depth 为本轮对话中已执行工具调用的轮数，
+// 达到上限后不再向LLM提供工具，要求其直接回复；textIndex 为本轮已播放的文本段数，工具调用后的回复接续编号
+func (h *ConnectionHandler) genResponseWithTools(ctx context.Context, messages []providers.Message, round int, depth int, textIndex int) error {
 	defer func() {
 		if r := recover(); r != nil {
 			h.LogError(fmt.Sprintf("genResponseByLLM发生panic: %v", r))
 			errorMsg := "抱歉，处理您的请求时发生了错误"
-			h.tts_last_text_index = 1 // 重置文本索引
+			h.setLastTextIndex(1) // 重置文本索引
 			h.SpeakAndPlay(errorMsg, 1, round)
 		}
 	}()
@@ -579,34 +905,48 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 	}
 	// 使用LLM生成回复
 	tools := h.functionRegister.GetAllFunctions()
+	if maxIterations := h.toolCallSettings().maxIterations; depth >= maxIterations {
+		h.LogInfo(fmt.Sprintf("工具调用已达到 %d 轮上限，要求LLM直接回复", maxIterations))
+		tools = nil
+	}
+	breaker := h.breaker("LLM")
+	if err := breaker.Allow(); err != nil {
+		h.LogError(fmt.Sprintf("LLM不可用: %v", err))
+		h.speakFallback(round)
+		return fmt.Errorf("LLM生成回复失败: %v", err)
+	}
 	responses, err := h.providers.llm.ResponseWithFunctions(ctx, h.sessionID, messages, tools)
 	if err != nil {
+		breaker.Record(err)
 		return fmt.Errorf("LLM生成回复失败: %v", err)
 	}
 
 	// 处理回复
 	var responseMessage []string
 	processedChars := 0
-	textIndex := 0
 
 	atomic.StoreInt32(&h.serverVoiceStop, 0)
 
 	// 处理流式响应
 	toolCallFlag := false
-	functionName := ""
-	functionID := ""
-	functionArguments := ""
+	var toolCalls []types.ToolCall
 	contentArguments := ""
 
 	for response := range responses {
+		if ctx.Err() != nil {
+			h.LogInfo(fmt.Sprintf("对话轮次 %d 已取消，停止处理LLM回复", round))
+			drainResponses(responses)
+			return nil
+		}
 		content := response.Content
 		toolCall := response.ToolCalls
 
 		if response.Error != "" {
 			h.LogError(fmt.Sprintf("LLM响应错误: %s", response.Error))
-			errorMsg := "抱歉，服务暂时不可用，请稍后再试"
-			h.tts_last_text_index = 1 // 重置文本索引
-			h.SpeakAndPlay(errorMsg, 1, round)
+			if ctx.Err() == nil {
+				breaker.RecordText(response.Error)
+			}
+			h.speakFallback(round)
 			return fmt.Errorf("LLM响应错误: %s", response.Error)
 		}
 
@@ -621,23 +961,18 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 
 		if len(toolCall) > 0 {
 			toolCallFlag = true
-			if toolCall[0].ID != "" {
-				functionID = toolCall[0].ID
-			}
-			if toolCall[0].Function.Name != "" {
-				functionName = toolCall[0].Function.Name
-			}
-			if toolCall[0].Function.Arguments != "" {
-				functionArguments += toolCall[0].Function.Arguments
+			for _, delta := range toolCall {
+				toolCalls = mergeToolCallDelta(toolCalls, delta)
 			}
 		}
 
 		if content != "" {
 			if strings.Contains(content, "服务响应异常") {
 				h.LogError(fmt.Sprintf("检测到LLM服务异常: %s", content))
-				errorMsg := "抱歉，服务暂时不可用，请稍后再试"
-				h.tts_last_text_index = 1 // 重置文本索引
-				h.SpeakAndPlay(errorMsg, 1, round)
+				if ctx.Err() == nil {
+					breaker.RecordText(content)
+				}
+				h.speakFallback(round)
 				return fmt.Errorf("LLM服务异常")
 			}
 
@@ -660,11 +995,12 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 				if textIndex == 1 {
 					now := time.Now()
 					llmSpentTime := now.Sub(llmStartTime)
+					metrics.LLMFirstSentenceLatency.Observe(llmSpentTime.Seconds(), h.providerName("LLM"))
 					h.LogInfo(fmt.Sprintf("LLM回复耗时 %s 生成第一句话【%s】, round: %d", llmSpentTime, segment, round))
 				} else {
 					h.LogInfo(fmt.Sprintf("LLM回复分段: %s, index: %d, round:%d", segment, textIndex, round))
 				}
-				h.tts_last_text_index = textIndex
+				h.setLastTextIndex(textIndex)
 				err := h.SpeakAndPlay(segment, textIndex, round)
 				if err != nil {
 					h.LogError(fmt.Sprintf("播放LLM回复分段失败: %v", err))
@@ -674,65 +1010,12 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 		}
 	}
 
-	if toolCallFlag {
-		bHasError := false
-		if functionID == "" {
-			a := utils.Extract_json_from_string(contentArguments)
-			if a != nil {
-				functionName = a["name"].(string)
-				argumentsJson, err := json.Marshal(a["arguments"])
-				if err != nil {
-					h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
-				}
-				functionArguments = string(argumentsJson)
-				functionID = uuid.New().String()
-			} else {
-				bHasError = true
-			}
-			if bHasError {
-				h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
-			}
-		}
-		if !bHasError {
-			// 清空responseMessage
-			responseMessage = []string{}
-			arguments := make(map[string]interface{})
-			if err := json.Unmarshal([]byte(functionArguments), &arguments); err != nil {
-				h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
-			}
-			functionCallData := map[string]interface{}{
-				"id":        functionID,
-				"name":      functionName,
-				"arguments": functionArguments,
-			}
-			h.LogInfo(fmt.Sprintf("函数调用: %v", arguments))
-			if h.mcpManager.IsMCPTool(functionName) {
-				// 处理MCP函数调用
-				result, err := h.mcpManager.ExecuteTool(ctx, functionName, arguments)
-				if err != nil {
-					h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
-					if result == nil {
-						result = "MCP工具调用失败"
-					}
-				}
-				// 判断result 是否是types.ActionResponse类型
-				if actionResult, ok := result.(types.ActionResponse); ok {
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
-				} else {
-					h.LogInfo(fmt.Sprintf("MCP函数调用结果: %v", result))
-					actionResult := types.ActionResponse{
-						Action: types.ActionTypeReqLLM, // 动作类型
-						Result: result,                 // 动作产生的结果
-					}
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
-				}
-
-			} else {
-				// 处理普通函数调用
-				//h.functionRegister.CallFunction(functionName, functionCallData)
-			}
-		}
+	if ctx.Err() != nil {
+		h.LogInfo(fmt.Sprintf("对话轮次 %d 已取消，丢弃LLM回复", round))
+		return nil
 	}
+	metrics.LLMResponseLatency.Observe(time.Since(llmStartTime).Seconds(), h.providerName("LLM"))
+	breaker.Record(nil)
 
 	// 处理剩余文本
 	fullResponse := utils.JoinStrings(responseMessage)
@@ -741,7 +1024,7 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 		if remainingText != "" {
 			textIndex++
 			h.LogInfo(fmt.Sprintf("LLM回复分段[剩余文本]: %s, index: %d, round:%d", remainingText, textIndex, round))
-			h.tts_last_text_index = textIndex
+			h.setLastTextIndex(textIndex)
 			h.SpeakAndPlay(remainingText, textIndex, round)
 		}
 	} else {
@@ -751,18 +1034,31 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 	// 分析回复并发送相应的情绪
 	content := utils.JoinStrings(responseMessage)
 
-	// 添加助手回复到对话历史
-	if !toolCallFlag {
-		h.dialogueManager.Put(chat.Message{
-			Role:    "assistant",
-			Content: content,
-		})
+	if toolCallFlag {
+		if len(toolCalls) == 0 {
+			// 不支持原生工具调用的模型以 <tool_call>{json} 文本形式返回
+			call, ok := parseTextToolCall(contentArguments)
+			if !ok {
+				h.LogError(fmt.Sprintf("函数调用参数解析失败: %s", contentArguments))
+				return nil
+			}
+			toolCalls = []types.ToolCall{call}
+		}
+		return h.handleToolCalls(ctx, toolCalls, round, depth, textIndex)
 	}
 
+	// 添加助手回复到对话历史
+	h.dialogueManager.Put(chat.Message{
+		Role:    "assistant",
+		Content: content,
+	})
+	h.recordTranscript("", content)
+
 	return nil
 }
 
-func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, functionCallData map[string]interface{}, textIndex int) {
+// handleFunctionResult 处理不需要再次请求LLM的工具调用结果，直接回复沿用本轮的文本索引播放
+func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, round int, textIndex *int) {
 	switch result.Action {
 	case types.ActionTypeError:
 		h.LogError(fmt.Sprintf("函数调用错误: %v", result.Result))
@@ -772,53 +1068,13 @@ func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, fu
 		h.LogInfo(fmt.Sprintf("函数调用无操作: %v", result.Result))
 	case types.ActionTypeResponse:
 		h.LogInfo(fmt.Sprintf("函数调用直接回复: %v", result.Response))
-		h.SystemSpeak(result.Response.(string))
+		text, _ := result.Response.(string)
+		for _, item := range utils.SplitByPunctuation(text) {
+			*textIndex++
+			h.SpeakAndPlay(item, *textIndex, round)
+		}
 	case types.ActionTypeCallHandler:
 		h.handleMCPResultCall(result)
-	case types.ActionTypeReqLLM:
-		h.LogInfo(fmt.Sprintf("函数调用后请求LLM: %v", result.Result))
-		text, ok := result.Result.(string)
-		if ok && len(text) > 0 {
-			functionID := functionCallData["id"].(string)
-			functionName := functionCallData["name"].(string)
-			functionArguments := functionCallData["arguments"].(string)
-			h.LogInfo(fmt.Sprintf("函数调用结果: %s", text))
-			h.LogInfo(fmt.Sprintf("函数调用参数: %s", functionArguments))
-			h.LogInfo(fmt.Sprintf("函数调用名称: %s", functionName))
-			h.LogInfo(fmt.Sprintf("函数调用ID: %s", functionID))
-
-			// 添加 assistant 消息，包含 tool_calls
-			h.dialogueManager.Put(chat.Message{
-				Role: "assistant",
-				ToolCalls: []types.ToolCall{{
-					ID: functionID,
-					Function: types.FunctionCall{
-						Arguments: functionArguments,
-						Name:      functionName,
-					},
-					Type:  "function",
-					Index: 0,
-				}},
-			})
-
-			// 添加 tool 消息
-			toolCallID := functionID
-			if toolCallID == "" {
-				toolCallID = uuid.New().String()
-			}
-			h.dialogueManager.Put(chat.Message{
-				Role:       "tool",
-				ToolCallID: toolCallID,
-				Content:    text,
-			})
-			h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.talkRound)
-
-		} else {
-			h.LogError(fmt.Sprintf("函数调用结果解析失败: %v", result.Result))
-			// 发送错误消息
-			errorMessage := fmt.Sprintf("函数调用结果解析失败 %v", result.Result)
-			h.SystemSpeak(errorMessage)
-		}
 	}
 }
 
@@ -828,47 +1084,94 @@ func (h *ConnectionHandler) SystemSpeak(text string) error {
 		return errors.New("收到空文本，无法合成语音")
 	}
 	texts := utils.SplitByPunctuation(text)
+	round := h.currentRound()
 	index := 0
 	for _, item := range texts {
 		index++
-		h.tts_last_text_index = index // 重置文本索引
-		h.SpeakAndPlay(item, index, h.talkRound)
+		h.setLastTextIndex(index) // 重置文本索引
+		h.SpeakAndPlay(item, index, round)
 	}
 	return nil
 }
 
-// isNeedAuth 判断是否需要验证
+// isNeedAuth 判断设备是否需要先完成绑定
+// 连接token认证(server.auth)在握手时完成，这里只受设备绑定(device_binding)开关控制
 func (h *ConnectionHandler) isNeedAuth() bool {
-	if !h.config.Server.Auth.Enabled {
+	if !h.config.DeviceBinding.Enabled {
 		return false
 	}
-	return !h.isDeviceVerified
+	return atomic.LoadInt32(&h.isDeviceVerified) == 0
 }
 
-// checkAndBroadcastAuthCode 检查并广播认证码
-func (h *ConnectionHandler) checkAndBroadcastAuthCode() error {
-	// 这里简化了认证逻辑，实际需要根据具体需求实现
-	text := "请联系管理员进行设备认证"
-	return h.SpeakAndPlay(text, 0, h.talkRound)
+// SetDeviceVerified 更新设备认证状态，管理员绑定或解绑设备后调用
+func (h *ConnectionHandler) SetDeviceVerified(verified bool) {
+	var value int32
+	if verified {
+		value = 1
+	}
+	atomic.StoreInt32(&h.isDeviceVerified, value)
 }
 
-// processTTSQueueCoroutine 处理TTS队列
-func (h *ConnectionHandler) processTTSQueueCoroutine() {
-	for {
-		select {
-		case <-h.stopChan:
+// loadDeviceBinding 根据白名单和数据库中的绑定记录设置设备认证状态
+func (h *ConnectionHandler) loadDeviceBinding() {
+	if h.deviceID == "" {
+		return
+	}
+	for _, allowed := range h.config.Server.Auth.AllowedDevices {
+		if allowed == h.deviceID {
+			h.SetDeviceVerified(true)
+			return
+		}
+	}
+	if database.DB == nil {
+		return
+	}
+
+	store := auth.NewBindingStore(database.DB)
+	bound, err := store.IsBound(h.deviceID)
+	if err != nil {
+		h.LogError(fmt.Sprintf("加载设备绑定失败: %v", err))
+		return
+	}
+	if !bound && h.config.DeviceBinding.AutoBind {
+		if _, err := store.Bind(h.deviceID, h.clientId, h.headers["Device-Name"], ""); err != nil {
+			h.LogError(fmt.Sprintf("自动绑定设备失败: %v", err))
 			return
-		case task := <-h.ttsQueue:
-			h.processTTSTask(task.text, task.textIndex, task.round)
 		}
+		h.LogInfo(fmt.Sprintf("设备 %s 已自动绑定", h.deviceID))
+		bound = true
 	}
+	h.SetDeviceVerified(bound)
+}
+
+// checkAndBroadcastAuthCode 为未绑定设备下发激活码并语音播报
+func (h *ConnectionHandler) checkAndBroadcastAuthCode() error {
+	text := "请联系管理员进行设备认证"
+	if h.deviceID != "" && database.DB != nil {
+		code, err := auth.NewBindingStore(database.DB).RequestActivationCode(h.deviceID, h.clientId)
+		if err != nil {
+			return err
+		}
+		h.LogInfo(fmt.Sprintf("设备 %s 未绑定，下发激活码: %s", h.deviceID, code))
+		// 数字之间加空格，让TTS逐位朗读
+		text = fmt.Sprintf("设备尚未绑定，请在管理后台输入验证码 %s 完成绑定", strings.Join(strings.Split(code, ""), " "))
+	}
+
+	h.setLastTextIndex(1)
+	return h.SpeakAndPlay(text, 1, h.currentRound())
 }
 
 // 服务端打断说话
 func (h *ConnectionHandler) stopServerSpeak() {
 	h.LogInfo("服务端停止说话")
 	atomic.StoreInt32(&h.serverVoiceStop, 1)
+	h.fire(EventAbort, func() bool {
+		h.cancelRoundLocked()
+		h.tts_last_text_index = -1
+		return true
+	})
 	h.cleanTTSAndAudioQueue(false)
+	h.finishPush(push.ErrInterrupted)
 }
 
 func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason string) {
@@ -876,6 +1179,11 @@ func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason stri
 		return
 	}
 
+	// 推送的源音频文件不删除
+	if h.isPushAudioFile(filepath) {
+		return
+	}
+
 	// 检查是否为快速回复缓存文件，如果是则不删除
 	if h.quickReplyCache != nil && h.quickReplyCache.IsCachedFile(filepath) {
 		h.LogInfo(fmt.Sprintf(reason+" 跳过删除缓存音频文件: %s", filepath))
@@ -896,19 +1204,22 @@ func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason stri
 	}
 }
 
-// processTTSTask 处理单个TTS任务
-func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int) {
+// processTTSTask 处理单个TTS任务，返回待发送的音频，所属轮次已取消时不再合成
+func (h *ConnectionHandler) processTTSTask(ctx context.Context, text string, textIndex int, round int) (result audioTask) {
 	filepath := ""
+	var frames <-chan []byte
+	var cancel context.CancelFunc
+	var synthesized <-chan struct{}
 	defer func() {
-		h.audioMessagesQueue <- struct {
-			filepath  string
-			text      string
-			round     int
-			textIndex int
-		}{filepath, text, round, textIndex}
+		result = audioTask{filepath, text, round, textIndex, frames, cancel, synthesized}
 	}()
 
-	if utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
+	if ctx.Err() != nil {
+		h.LogInfo(fmt.Sprintf("processTTSTask 轮次 %d 已取消，跳过TTS合成: %s", round, text))
+		return
+	}
+
+	if h.isCachedReply(text) {
 		// 尝试从缓存查找音频文件
 		if cachedFile := h.quickReplyCache.FindCachedAudio(text); cachedFile != "" {
 			h.LogInfo(fmt.Sprintf("使用缓存的快速回复音频: %s", cachedFile))
@@ -925,15 +1236,35 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 		return
 	}
 
+	breaker := h.breaker("TTS")
+	if err := breaker.Allow(); err != nil {
+		h.LogError(fmt.Sprintf("TTS转换失败:text(%s) %v", text, err))
+		return
+	}
+
+	// 支持流式合成时边合成边发送；快速回复词和兜底提示语需要落盘缓存，仍走文件合成
+	if streamer, ok := h.providers.tts.(providers.StreamingTTSProvider); ok && !h.isCachedReply(text) {
+		frames, cancel, synthesized = h.startTTSStream(ctx, streamer, breaker, text, textIndex)
+		return
+	}
+
 	// 生成语音文件
-	filepath, err := h.providers.tts.ToTTS(text)
+	filepath, err := h.providers.tts.ToTTS(ctx, text)
+	if ctx.Err() != nil {
+		h.LogInfo(fmt.Sprintf("processTTSTask 轮次 %d 已取消，丢弃TTS结果: %s", round, text))
+		h.deleteAudioFileIfNeeded(filepath, "轮次已取消")
+		filepath = ""
+		return
+	}
+	breaker.Record(err)
 	if err != nil {
 		h.LogError(fmt.Sprintf("TTS转换失败:text(%s) %v", text, err))
 		return
 	} else {
+		metrics.TTSLatency.Observe(time.Since(ttsStartTime).Seconds(), h.providerName("TTS"))
 		h.logger.Debug(fmt.Sprintf("TTS转换成功: text(%s), index(%d) %s", text, textIndex, filepath))
-		// 如果是快速回复词，保存到缓存
-		if utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
+		// 如果是快速回复词或兜底提示语，保存到缓存
+		if h.isCachedReply(text) {
 			if err := h.quickReplyCache.SaveCachedAudio(text, filepath); err != nil {
 				h.LogError(fmt.Sprintf("保存快速回复音频失败: %v", err))
 			} else {
@@ -953,18 +1284,42 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 		ttsSpentTime := now.Sub(ttsStartTime)
 		h.logger.Debug(fmt.Sprintf("TTS转换耗时: %s, 文本: %s, 索引: %d", ttsSpentTime, text, textIndex))
 	}
+	return
+}
 
+// startTTSStream 启动流式TTS合成，返回音频帧通道，合成结束或失败时通道关闭；
+// synthesized 在合成协程退出时关闭，不依赖音频帧是否已被发送
+func (h *ConnectionHandler) startTTSStream(parent context.Context, streamer providers.StreamingTTSProvider, breaker *pool.CircuitBreaker, text string, textIndex int) (<-chan []byte, context.CancelFunc, <-chan struct{}) {
+	ctx, cancel := context.WithCancel(parent)
+	frames := make(chan []byte, ttsStreamBufferFrames)
+	synthesized := make(chan struct{})
+	go func() {
+		defer close(synthesized)
+		defer close(frames)
+		ttsStartTime := time.Now()
+		err := streamer.ToTTSStream(ctx, text, h.serverAudioFormat, h.serverAudioSampleRate, frames)
+		if err != nil {
+			if ctx.Err() == nil {
+				h.LogError(fmt.Sprintf("流式TTS合成失败:text(%s) %v", text, err))
+				breaker.Record(err)
+			}
+			return
+		}
+		breaker.Record(nil)
+		metrics.TTSLatency.Observe(time.Since(ttsStartTime).Seconds(), h.providerName("TTS"))
+		if textIndex == 1 {
+			h.logger.Debug(fmt.Sprintf("流式TTS合成完成耗时: %s, 文本: %s, 索引: %d", time.Since(ttsStartTime), text, textIndex))
+		}
+	}()
+	return frames, cancel, synthesized
 }
 
 // speakAndPlay 合成并播放语音
 func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int) error {
+	ctx := h.roundContext(round)
 	defer func() {
 		// 将任务加入队列，不阻塞当前流程
-		h.ttsQueue <- struct {
-			text      string
-			round     int
-			textIndex int
-		}{text, round, textIndex}
+		h.ttsQueue <- ttsTask{ctx, text, round, textIndex}
 	}()
 
 	originText := text // 保存原始文本用于日志
@@ -989,10 +1344,13 @@ func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int)
 	return nil
 }
 
+// clearSpeakStatus 播报结束或被中止后重置语音识别状态，准备下一次拾音
 func (h *ConnectionHandler) clearSpeakStatus() {
 	h.LogInfo("清除服务端讲话状态 ")
-	h.tts_last_text_index = -1
 	h.providers.asr.Reset() // 重置ASR状态
+	if h.providers.vad != nil {
+		h.providers.vad.Reset() // 重置VAD状态
+	}
 }
 
 func (h *ConnectionHandler) closeOpusDecoder() {
@@ -1027,6 +1385,9 @@ clearAudioQueue:
 		select {
 		case task := <-h.audioMessagesQueue:
 			h.LogInfo(fmt.Sprintf(msgPrefix+"丢弃一个音频任务: %s", task.text))
+			if task.cancel != nil {
+				task.cancel() // 终止仍在进行的流式合成
+			}
 			// 根据配置删除被丢弃的音频文件
 			h.deleteAudioFileIfNeeded(task.filepath, msgPrefix+"丢弃音频任务时")
 		default:
@@ -1051,11 +1412,21 @@ func (h *ConnectionHandler) Close() {
 				h.LogError(fmt.Sprintf("重置ASR状态失败: %v", err))
 			}
 		}
+		h.fire(EventClose, func() bool {
+			h.cancelRoundLocked()
+			return true
+		})
 		h.cleanTTSAndAudioQueue(true)
+		if h.hardware != nil {
+			h.hardware.Close()
+		}
 		// 移除连接
 		WsConnMapLock.Lock()
 		delete(WsConnMap, h.sessionID)
 		WsConnMapLock.Unlock()
+		device.Default().Disconnect(h.deviceID, h, device.DisconnectClosed)
+		push.Default().Detach(h.deviceID, h)
+		h.finishPush(push.ErrDisconnected)
 
 		if h.deviceID != "" {
 			MacSessionMapLock.Lock()
@@ -1077,6 +1448,9 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 
 	// 使用VLLLM处理图片和文本
 	responses, err := h.providers.vlllm.ResponseWithImage(ctx, h.sessionID, messages, imageData, text)
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
 	if err != nil {
 		h.LogError(fmt.Sprintf("VLLLM生成回复失败，尝试降级到普通LLM: %v", err))
 		// 降级策略：只使用文本部分调用普通LLM
@@ -1096,6 +1470,11 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 	atomic.StoreInt32(&h.serverVoiceStop, 0)
 
 	for response := range responses {
+		if ctx.Err() != nil {
+			h.LogInfo(fmt.Sprintf("对话轮次 %d 已取消，停止处理VLLLM回复", round))
+			drainResponses(responses)
+			return nil
+		}
 		if response == "" {
 			continue
 		}
@@ -1108,17 +1487,22 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 		// 按标点符号分割
 		if segment, chars := utils.SplitAtLastPunctuation(currentText); chars > 0 {
 			textIndex++
-			h.tts_last_text_index = textIndex
+			h.setLastTextIndex(textIndex)
 			h.SpeakAndPlay(segment, textIndex, round)
 			processedChars += chars
 		}
 	}
 
+	if ctx.Err() != nil {
+		h.LogInfo(fmt.Sprintf("对话轮次 %d 已取消，丢弃VLLLM回复", round))
+		return nil
+	}
+
 	// 处理剩余文本
 	remainingText := utils.JoinStrings(responseMessage)[processedChars:]
 	if remainingText != "" {
 		textIndex++
-		h.tts_last_text_index = textIndex
+		h.setLastTextIndex(textIndex)
 		h.SpeakAndPlay(remainingText, textIndex, round)
 	}
 
@@ -1130,6 +1514,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 		Role:    "assistant",
 		Content: content,
 	})
+	h.recordTranscript("", content)
 
 	h.LogInfo(fmt.Sprintf("VLLLM回复处理完成 …%v", map[string]interface{}{
 		"content_length": len(content),
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"context"
 	"encoding/json"
 	"xiaozhi-server-go/src/core/types"
 	"xiaozhi-server-go/src/core/utils"
@@ -52,7 +51,7 @@ func (h *ConnectionHandler) mcp_handler_play_music(args interface{}) {
 			h.SystemSpeak("没有找到名为" + songName + "的歌曲")
 		} else {
 			//h.SystemSpeak("这就为您播放音乐: " + songName)
-			h.sendAudioMessage(path, name, h.tts_last_text_index, h.talkRound)
+			h.sendAudioMessage(path, name, h.lastTextIndex(), h.currentRound())
 		}
 	} else {
 		h.logger.Error("mcp_handler_play_music: args is not a string")
@@ -101,7 +100,7 @@ func (h *ConnectionHandler) mcp_handler_change_role(args interface{}) {
 
 func (h *ConnectionHandler) mcp_handler_exit(args interface{}) {
 	if text, ok := args.(string); ok {
-		h.closeAfterChat = true
+		h.setCloseAfterChat()
 		h.SystemSpeak(text)
 	} else {
 		h.logger.Error("mcp_handler_exit: args is not a string")
@@ -118,7 +117,8 @@ func (h *ConnectionHandler) mcp_handler_take_photo(args interface{}) {
 
 	if !visionResponse.Success {
 		h.logger.Error("拍照失败: %s", visionResponse.Message)
-		h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.talkRound)
+		round := h.currentRound()
+		h.genResponseByLLM(h.roundContext(round), h.llmDialogue(), round)
 
 	}
 
@@ -5,19 +5,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync/atomic"
+	"time"
 	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/device"
+	"xiaozhi-server-go/src/core/function"
 	"xiaozhi-server-go/src/core/image"
 	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/push"
 	"xiaozhi-server-go/src/core/utils"
 )
 
 // handleMessage 处理接收到的消息
 func (h *ConnectionHandler) handleMessage(messageType int, message []byte) error {
+	atomic.StoreInt64(&h.lastActiveTime, time.Now().UnixNano())
 	switch messageType {
 	case 1: // 文本消息
 		h.clientTextQueue <- string(message)
 		return nil
 	case 2: // 二进制消息（音频数据）
+		if atomic.LoadInt32(&h.workersStarted) == 0 {
+			// 收到hello之前音频格式未协商，音频处理协程也未启动
+			h.logger.Debug("尚未收到hello消息，丢弃音频数据")
+			return nil
+		}
 		if h.clientAudioFormat == "pcm" {
 			// 直接将PCM数据放入队列
 			h.clientAudioQueue <- message
@@ -74,6 +85,10 @@ func (h *ConnectionHandler) processClientTextMessage(ctx context.Context, text s
 		return fmt.Errorf("消息类型错误")
 	}
 
+	if msgType != "hello" {
+		h.startWorkers()
+	}
+
 	switch msgType {
 	case "hello":
 		return h.handleHelloMessage(msgMap)
@@ -115,13 +130,30 @@ func (h *ConnectionHandler) handleVisionMessage(msgMap map[string]interface{}) e
 func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) error {
 	h.LogInfo("收到客户端欢迎消息: " + fmt.Sprintf("%v", msgMap))
 	// 新增：提取 device_mac 并缓存
-	if mac, ok := msgMap["device_mac"].(string); ok && mac != "" {
+	mac, _ := msgMap["device_mac"].(string)
+	if h.authDeviceID != "" {
+		// 已通过认证的连接只能使用认证的设备ID，不一致时断开连接
+		if mac != "" && mac != h.authDeviceID {
+			h.Disconnect()
+			return fmt.Errorf("hello消息中的设备ID %s 与认证的设备ID %s 不一致，已断开连接", mac, h.authDeviceID)
+		}
+		mac = h.authDeviceID
+	}
+	if mac != "" {
+		if h.deviceID != mac {
+			device.Default().Disconnect(h.deviceID, h, device.DisconnectClosed)
+		}
 		h.deviceID = mac
+		h.registerDevice()
 		MacSessionMapLock.Lock()
 		MacSessionMap[mac] = h.sessionID
 		MacSessionMapLock.Unlock()
 		fmt.Println("缓存mac-session:", mac, h.sessionID)
+		h.loadDeviceBinding()
 	}
+	h.applyUserSetting()
+	h.initDeviceMemory()
+	h.startWorkers()
 	// 新增：使用从 URL 参数提取的 clientId 并缓存
 	if h.clientId != "" {
 		MacSessionMapLock.Lock()
@@ -149,8 +181,16 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 		}
 		h.LogInfo(fmt.Sprintf("客户端音频参数: format=%s, sample_rate=%d, channels=%d, frame_duration=%d",
 			h.clientAudioFormat, h.clientAudioSampleRate, h.clientAudioChannels, h.clientAudioFrameDuration))
+		device.Default().SetAudioParams(h.deviceID, device.AudioParams{
+			Format:        h.clientAudioFormat,
+			SampleRate:    h.clientAudioSampleRate,
+			Channels:      h.clientAudioChannels,
+			FrameDuration: h.clientAudioFrameDuration,
+		})
 	}
 	h.sendHelloMessage()
+	// 投递设备离线期间排队的推送
+	push.Default().Attach(h.deviceID, h)
 	h.closeOpusDecoder()
 	// 初始化opus解码器
 	opusDecoder, err := utils.NewOpusDecoder(&utils.OpusDecoderConfig{
@@ -178,20 +218,32 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 
 	// 处理mode参数
 	if mode, ok := msgMap["mode"].(string); ok {
-		h.clientListenMode = mode
-		h.LogInfo(fmt.Sprintf("客户端拾音模式：%s， %s", h.clientListenMode, state))
+		h.setListenMode(mode)
+		h.LogInfo(fmt.Sprintf("客户端拾音模式：%s， %s", mode, state))
 		h.providers.asr.SetListener(h)
 	}
 
 	switch state {
 	case "start":
-		if h.client_asr_text != "" && h.clientListenMode == "manual" {
+		// manual模式下上一句识别文本尚未处理完时，新的拾音视为打断
+		pending := false
+		h.fire(EventListenStart, func() bool {
+			pending = h.client_asr_text != "" && h.clientListenMode == "manual"
+			h.clientVoiceStop = false
+			h.clientListening = true
+			h.client_asr_text = ""
+			return true
+		})
+		if pending {
 			h.clientAbortChat()
 		}
-		h.clientVoiceStop = false
-		h.client_asr_text = ""
 	case "stop":
-		h.clientVoiceStop = true
+		h.fire(EventListenStop, func() bool {
+			h.clientVoiceStop = true
+			h.clientListening = false
+			return true
+		})
+		h.markSpeechEnd()
 		h.LogInfo("客户端停止语音识别")
 	case "detect":
 		text, hasText := msgMap["text"].(string)
@@ -201,7 +253,7 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 			h.LogInfo(fmt.Sprintf("检测到纯文本消息，使用LLM处理 %v", map[string]interface{}{
 				"text": text,
 			}))
-			return h.handleChatMessage(context.Background(), text)
+			return h.handleChatMessage(h.ctx, text)
 		} else {
 			// 既没有图片也没有文本
 			h.logger.Warn("detect消息既没有text也没有image参数")
@@ -213,24 +265,65 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 
 // handleIotMessage 处理IOT设备消息
 func (h *ConnectionHandler) handleIotMessage(msgMap map[string]interface{}) error {
+	if h.hardware != nil && h.hardware.HandleResponse(msgMap) {
+		return nil
+	}
+	if h.iot == nil {
+		return fmt.Errorf("连接尚未初始化，忽略IOT消息")
+	}
 	if descriptors, ok := msgMap["descriptors"].([]interface{}); ok {
-		// 处理设备描述符
-		// 这里需要实现具体的IOT设备描述符处理逻辑
 		h.LogInfo(fmt.Sprintf("收到IOT设备描述符：%v", descriptors))
+		count, err := h.iot.UpdateDescriptors(descriptors)
+		if err != nil {
+			h.LogError(err.Error())
+		}
+		h.LogInfo(fmt.Sprintf("已将IOT设备描述符注册为 %d 个工具", count))
 	}
 	if states, ok := msgMap["states"].([]interface{}); ok {
-		// 处理设备状态
-		// 这里需要实现具体的IOT设备状态处理逻辑
-		h.LogInfo(fmt.Sprintf("收到IOT设备状态：%v", states))
+		h.logger.Debug("收到IOT设备状态：%v", states)
+		h.iot.UpdateStates(states)
 	}
 	return nil
 }
 
+// initHardwareFunctions 按配置注册硬件控制函数，设备模式下通过当前连接下发指令
+func (h *ConnectionHandler) initHardwareFunctions(conn Connection) {
+	hwConfig := h.config.Hardware
+	if !hwConfig.Enabled {
+		return
+	}
+
+	mode := hwConfig.Mode
+	if mode == "" {
+		mode = "device"
+	}
+	var controller function.HardwareController
+	switch mode {
+	case "simulated":
+		controller = function.NewSimulatedHardwareController()
+	case "device":
+		timeout, err := time.ParseDuration(hwConfig.Timeout)
+		if err != nil {
+			timeout = function.DefaultHardwareTimeout
+		}
+		h.hardware = function.NewDeviceHardwareController(conn, h.sessionID, timeout)
+		controller = h.hardware
+	default:
+		h.LogError(fmt.Sprintf("未知的硬件控制模式: %s", mode))
+		return
+	}
+
+	if err := h.functionRegister.RegisterHardwareFunctions(controller); err != nil {
+		h.LogError(fmt.Sprintf("注册硬件控制函数失败: %v", err))
+		return
+	}
+	h.LogInfo(fmt.Sprintf("已注册硬件控制函数，模式: %s", mode))
+}
+
 // handleImageMessage 处理图片消息
 func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[string]interface{}) error {
-	// 增加对话轮次
-	h.talkRound++
-	currentRound := h.talkRound
+	// 开始新的对话轮次，取消上一轮仍在进行的回复
+	ctx, currentRound := h.startRound(ctx)
 	h.LogInfo(fmt.Sprintf("开始新的图片对话轮次: %d", currentRound))
 
 	// 判断是否需要验证
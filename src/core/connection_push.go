@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"xiaozhi-server-go/src/core/push"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+)
+
+// busyTimeout 对话轮次超过该时长仍未结束时视为空闲，避免推送被永久阻塞
+const busyTimeout = 2 * time.Minute
+
+// pushPlayback 正在播放的推送
+type pushPlayback struct {
+	round     int
+	lastIndex int
+	done      func(error)
+}
+
+// IsBusy 判断设备是否正在对话或播报
+func (h *ConnectionHandler) IsBusy() bool {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	return h.state.busy() && time.Since(h.stateSince) < busyTimeout
+}
+
+// notifyPushWhenIdle 对话播报结束后通知推送管理器投递排队消息
+func (h *ConnectionHandler) notifyPushWhenIdle(from, to SessionState) {
+	if from.busy() && (to == StateIdle || to == StateListening) {
+		push.Default().Notify(h.deviceID)
+	}
+}
+
+// PlayPush 实现 push.Receiver，以新的轮次播放推送的文本、音频文件或音乐
+func (h *ConnectionHandler) PlayPush(msg models.PushMessage, interrupt bool, done func(error)) error {
+	var audioPath, audioName string
+	switch msg.Type {
+	case push.TypeMusic:
+		path, name, err := utils.GetMusicFilePathFuzzy(msg.Content)
+		if err != nil {
+			return fmt.Errorf("未找到歌曲 %s: %v", msg.Content, err)
+		}
+		audioPath, audioName = path, name
+	case push.TypeAudio:
+		path, err := push.ResolveAudioPath(h.config.Push.AudioDir, msg.Content)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("音频文件不可用: %v", err)
+		}
+		audioPath, audioName = path, utils.GetFileNameFromPath(path)
+	}
+
+	var texts []string
+	if audioPath == "" {
+		texts = utils.SplitByPunctuation(msg.Content)
+		if len(texts) == 0 {
+			return fmt.Errorf("推送文本为空")
+		}
+	}
+
+	if interrupt {
+		h.stopServerSpeak()
+	}
+	_, round := h.startRound(h.ctx)
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	h.LogInfo(fmt.Sprintf("播放推送消息 %s (%s), 轮次: %d", msg.MessageID, msg.Type, round))
+
+	lastIndex := len(texts)
+	if audioPath != "" {
+		lastIndex = 1
+	}
+	h.pushMu.Lock()
+	previous := h.pushPlaying
+	h.pushPlaying = &pushPlayback{round: round, lastIndex: lastIndex, done: done}
+	h.pushMu.Unlock()
+	if previous != nil {
+		previous.done(push.ErrInterrupted)
+	}
+
+	h.setLastTextIndex(lastIndex)
+	if audioPath != "" {
+		select {
+		case h.audioMessagesQueue <- audioTask{filepath: audioPath, text: audioName, round: round, textIndex: 1}:
+		case <-h.stopChan:
+			// 连接已关闭，交由推送管理器重新排队，不再回调 done
+			h.pushMu.Lock()
+			if h.pushPlaying != nil && h.pushPlaying.round == round {
+				h.pushPlaying = nil
+			}
+			h.pushMu.Unlock()
+			return push.ErrDisconnected
+		}
+		return nil
+	}
+	for i, text := range texts {
+		h.SpeakAndPlay(text, i+1, round)
+	}
+	return nil
+}
+
+// completePush 推送的最后一句发送结束后回报播放结果
+func (h *ConnectionHandler) completePush(round, textIndex int, success bool) {
+	h.pushMu.Lock()
+	playing := h.pushPlaying
+	if playing == nil || playing.round != round || playing.lastIndex != textIndex {
+		h.pushMu.Unlock()
+		return
+	}
+	h.pushPlaying = nil
+	h.pushMu.Unlock()
+
+	if success {
+		playing.done(nil)
+	} else {
+		playing.done(fmt.Errorf("推送音频发送失败"))
+	}
+}
+
+// finishPush 以指定原因结束正在播放的推送
+func (h *ConnectionHandler) finishPush(err error) {
+	h.pushMu.Lock()
+	playing := h.pushPlaying
+	h.pushPlaying = nil
+	h.pushMu.Unlock()
+	if playing != nil {
+		playing.done(err)
+	}
+}
+
+// isPushAudioFile 判断是否为推送音频目录下的文件，推送的源文件不能删除
+func (h *ConnectionHandler) isPushAudioFile(filepath string) bool {
+	return push.IsAudioFile(h.config.Push.AudioDir, filepath)
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/core/push"
+	"xiaozhi-server-go/src/models"
+)
+
+func newPushTestHandler(t *testing.T) *ConnectionHandler {
+	t.Helper()
+	h, _ := newTestHandler(t)
+	h.config.Push.AudioDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(h.config.Push.AudioDir, "alarm.mp3"), []byte("mp3"), 0644); err != nil {
+		t.Fatalf("创建测试音频失败: %v", err)
+	}
+	return h
+}
+
+func TestPlayPushAudioPath(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret.mp3")
+	if err := os.WriteFile(outside, []byte("mp3"), 0644); err != nil {
+		t.Fatalf("创建测试文件失败: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "音频目录下的文件", content: "alarm.mp3"},
+		{name: "绝对路径", content: outside, wantErr: true},
+		{name: "跳出音频目录", content: "../secret.mp3", wantErr: true},
+		{name: "文件不存在", content: "missing.mp3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newPushTestHandler(t)
+			msg := models.PushMessage{MessageID: "m1", Type: push.TypeAudio, Content: tt.content}
+			err := h.PlayPush(msg, false, func(error) {})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PlayPush() 错误 = %v，期望出错 %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			task := <-h.audioMessagesQueue
+			if want := filepath.Join(h.config.Push.AudioDir, "alarm.mp3"); task.filepath != want {
+				t.Errorf("播放文件 = %s，期望 %s", task.filepath, want)
+			}
+			if !h.isPushAudioFile(task.filepath) {
+				t.Error("推送音频目录下的文件不应被删除")
+			}
+		})
+	}
+}
+
+// TestPlayPushDisconnected 音频队列已满时连接关闭，推送应返回连接断开以便重新排队
+func TestPlayPushDisconnected(t *testing.T) {
+	h := newPushTestHandler(t)
+	h.audioMessagesQueue = make(chan audioTask)
+	close(h.stopChan)
+
+	result := make(chan error, 1)
+	go func() {
+		msg := models.PushMessage{MessageID: "m1", Type: push.TypeAudio, Content: "alarm.mp3"}
+		result <- h.PlayPush(msg, false, func(error) { t.Error("返回错误后不应回调 done") })
+	}()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, push.ErrDisconnected) {
+			t.Fatalf("PlayPush() 错误 = %v，期望 ErrDisconnected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("连接关闭后 PlayPush 仍阻塞在音频队列上")
+	}
+	if h.pushPlaying != nil {
+		t.Error("连接关闭后未清除正在播放的推送")
+	}
+}
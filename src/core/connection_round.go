@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"xiaozhi-server-go/src/core/push"
+)
+
+// startRound 开始新的对话轮次：取消上一轮仍在进行的LLM生成、工具调用和TTS合成，
+// 清空上一轮排队的TTS和音频任务，返回本轮的上下文和轮次
+func (h *ConnectionHandler) startRound(parent context.Context) (context.Context, int) {
+	if parent == nil {
+		parent = h.ctx
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	var ctx context.Context
+	round := 0
+	started := h.fire(EventRoundStart, func() bool {
+		h.cancelRoundLocked()
+		h.talkRound++
+		round = h.talkRound
+		h.roundCtx, h.roundCancel = context.WithCancel(parent)
+		ctx = h.roundCtx
+		h.roundStartTime = time.Now()
+		// 非realtime模式下客户端开始播放回复时停止拾音，播放结束后重新发送 listen start
+		if h.clientListenMode != "realtime" {
+			h.clientListening = false
+		}
+		return true
+	})
+	if !started {
+		// 连接关闭中，返回已取消的上下文
+		return h.roundContext(-1), h.currentRound()
+	}
+
+	h.cleanTTSAndAudioQueue(false)
+	h.finishPush(push.ErrInterrupted)
+	return ctx, round
+}
+
+// cancelRoundLocked 取消当前轮次仍在进行的任务，轮次不变，调用方需持有 stateMu
+func (h *ConnectionHandler) cancelRoundLocked() {
+	if h.roundCancel != nil {
+		h.roundCancel()
+	}
+}
+
+// roundContext 返回指定轮次的上下文，轮次已过期时返回已取消的上下文；
+// 尚未开始任何轮次时返回连接的上下文
+func (h *ConnectionHandler) roundContext(round int) context.Context {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	if round == h.talkRound && h.state != StateClosing {
+		if h.roundCtx != nil {
+			return h.roundCtx
+		}
+		if h.ctx != nil {
+			return h.ctx
+		}
+		return context.Background()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// drainResponses 丢弃已取消轮次的剩余流式回复，避免提供者的发送协程阻塞
+func drainResponses[T any](ch <-chan T) {
+	go func() {
+		for range ch {
+		}
+	}()
+}
@@ -1,13 +1,18 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync/atomic"
 	"time"
+	"xiaozhi-server-go/src/core/metrics"
 	"xiaozhi-server-go/src/core/utils"
 )
 
+// ttsStreamBufferFrames 流式TTS音频帧通道容量(60ms一帧，约30秒)
+const ttsStreamBufferFrames = 500
+
 // sendHelloMessage 发送欢迎消息
 func (h *ConnectionHandler) sendHelloMessage() error {
 	// 添加安全检查
@@ -97,25 +102,18 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		// 音频发送完成后，根据配置决定是否删除文件
 		h.deleteAudioFileIfNeeded(filepath, "音频发送完成")
 
-		h.LogInfo(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.tts_last_text_index))
-		h.providers.asr.ResetStartListenTime()
-		if textIndex == h.tts_last_text_index {
-			h.sendTTSMessage("stop", "", textIndex)
-			if h.closeAfterChat {
-				h.Close()
-			} else {
-				h.clearSpeakStatus()
-			}
-		}
+		h.LogInfo(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.lastTextIndex()))
+		h.finishAudioMessage(round, textIndex)
+		h.completePush(round, textIndex, bFinishSuccess)
 	}()
 
 	if len(filepath) == 0 {
 		return
 	}
 	// 检查轮次
-	if round != h.talkRound {
+	if current := h.currentRound(); round != current {
 		h.LogInfo(fmt.Sprintf("sendAudioMessage: 跳过过期轮次的音频: 任务轮次=%d, 当前轮次=%d, 文本=%s",
-			round, h.talkRound, text))
+			round, current, text))
 		// 即使跳过，也要根据配置删除音频文件
 		h.deleteAudioFileIfNeeded(filepath, "跳过过期轮次")
 		return
@@ -149,17 +147,11 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 	}
 
 	// 发送TTS状态开始通知
-	if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
-		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+	if err := h.sendSentenceStart(text, textIndex, round); err != nil {
+		h.LogError(err.Error())
 		return
 	}
-
-	if textIndex == 1 {
-		now := time.Now()
-		spentTime := now.Sub(h.roundStartTime)
-		h.logger.Debug("回复首句耗时 %s 第一句话【%s】, round: %d", spentTime, text, round)
-	}
-	h.logger.Debug("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index, duration, len(audioData))
+	h.logger.Debug("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.lastTextIndex(), duration, len(audioData))
 
 	// 分时发送音频数据
 	if err := h.sendAudioFrames(audioData, text, round); err != nil {
@@ -176,70 +168,168 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 	bFinishSuccess = true
 }
 
+// sendAudioStream 边接收流式TTS音频帧边发送，首帧到达时才发送句子开始通知
+func (h *ConnectionHandler) sendAudioStream(frames <-chan []byte, cancel context.CancelFunc, text string, textIndex int, round int) {
+	bFinishSuccess := false
+	defer func() {
+		cancel() // 中途退出时终止合成
+		h.LogInfo(fmt.Sprintf("TTS流式音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.lastTextIndex()))
+		h.finishAudioMessage(round, textIndex)
+		h.completePush(round, textIndex, bFinishSuccess)
+	}()
+
+	if current := h.currentRound(); round != current {
+		h.LogInfo(fmt.Sprintf("sendAudioStream: 跳过过期轮次的音频: 任务轮次=%d, 当前轮次=%d, 文本=%s",
+			round, current, text))
+		return
+	}
+
+	if atomic.LoadInt32(&h.serverVoiceStop) == 1 { // 服务端语音停止
+		h.LogInfo(fmt.Sprintf("sendAudioStream 服务端语音停止, 不再发送音频数据：%s", text))
+		return
+	}
+
+	started := false
+	onFirstFrame := func() error {
+		started = true
+		return h.sendSentenceStart(text, textIndex, round)
+	}
+	if err := h.sendAudioFrameStream(frames, text, round, onFirstFrame); err != nil {
+		h.LogError(fmt.Sprintf("流式发送音频数据失败: %v", err))
+		return
+	}
+	if !started {
+		return
+	}
+
+	if err := h.sendTTSMessage("sentence_end", text, textIndex); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS结束状态失败: %v", err))
+		return
+	}
+
+	bFinishSuccess = true
+}
+
+// sendSentenceStart 发送句子开始通知，并记录首句耗时
+func (h *ConnectionHandler) sendSentenceStart(text string, textIndex int, round int) error {
+	var roundStartTime time.Time
+	h.fire(EventSpeechStart, func() bool {
+		roundStartTime = h.roundStartTime
+		return round == h.talkRound
+	})
+	if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
+		return fmt.Errorf("发送TTS开始状态失败: %v", err)
+	}
+
+	if textIndex == 1 && !roundStartTime.IsZero() {
+		now := time.Now()
+		spentTime := now.Sub(roundStartTime)
+		metrics.RoundFirstAudioLatency.Observe(spentTime.Seconds())
+		h.logger.Debug("回复首句耗时 %s 第一句话【%s】, round: %d", spentTime, text, round)
+	}
+	return nil
+}
+
+// finishAudioMessage 单句音频发送结束后的收尾，当前轮次最后一句时通知客户端TTS结束
+func (h *ConnectionHandler) finishAudioMessage(round, textIndex int) {
+	h.providers.asr.ResetStartListenTime()
+	last, closeAfterChat := false, false
+	h.fire(EventSpeechEnd, func() bool {
+		if round != h.talkRound || textIndex != h.tts_last_text_index {
+			return false
+		}
+		h.tts_last_text_index = -1
+		last, closeAfterChat = true, h.closeAfterChat
+		return true
+	})
+	if !last {
+		return
+	}
+	h.sendTTSMessage("stop", "", textIndex)
+	if closeAfterChat {
+		h.Close()
+	} else {
+		h.clearSpeakStatus()
+	}
+}
+
 // sendAudioFrames 分时发送音频帧，避免撑爆客户端缓冲区
 func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, round int) error {
 	if len(audioData) == 0 {
 		return nil
 	}
 
-	startTime := time.Now()
+	frames := make(chan []byte, len(audioData))
+	for _, chunk := range audioData {
+		frames <- chunk
+	}
+	close(frames)
+	return h.sendAudioFrameStream(frames, text, round, nil)
+}
+
+// isAudioSendInterrupted 判断音频发送是否被打断或轮次已变化
+func (h *ConnectionHandler) isAudioSendInterrupted(round int) bool {
+	return atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.currentRound()
+}
+
+// sendAudioFrameStream 按播放节奏分时发送通道中的音频帧，直到通道关闭
+// onFirstFrame 在首帧发送前调用，可为空
+func (h *ConnectionHandler) sendAudioFrameStream(frames <-chan []byte, text string, round int, onFirstFrame func() error) error {
+	var startTime time.Time
 	playPosition := 0 // 播放位置（毫秒）
+	frameCount := 0
 
-	// 预缓冲：发送前几帧，提升播放流畅度
+	// 预缓冲：先连续发送前几帧，提升播放流畅度
 	preBufferFrames := 3
-	if len(audioData) < preBufferFrames {
-		preBufferFrames = len(audioData)
-	}
-	preBufferTime := time.Duration(h.serverAudioFrameDuration*preBufferFrames) * time.Millisecond // 预缓冲时间（毫秒）
-
-	// 发送预缓冲帧
-	for i := 0; i < preBufferFrames; i++ {
-		// 检查是否被打断
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-			h.LogInfo(fmt.Sprintf("音频发送被中断(预缓冲阶段): 帧=%d/%d, 文本=%s", i+1, preBufferFrames, text))
-			return nil
+	preBufferTime := time.Duration(h.serverAudioFrameDuration*preBufferFrames) * time.Millisecond
+
+	ticker := time.NewTicker(10 * time.Millisecond) // 固定10ms检查间隔
+	defer ticker.Stop()
+
+	for {
+		// 等待下一帧，期间响应打断
+		var chunk []byte
+		ok := false
+		waiting := true
+		for waiting {
+			select {
+			case chunk, ok = <-frames:
+				waiting = false
+			case <-ticker.C:
+				if h.isAudioSendInterrupted(round) {
+					h.LogInfo(fmt.Sprintf("音频发送在等待音频帧时被中断: 已发送帧=%d, 文本=%s", frameCount, text))
+					return nil
+				}
+			case <-h.stopChan:
+				return nil
+			}
 		}
-
-		if err := h.conn.WriteMessage(2, audioData[i]); err != nil {
-			return fmt.Errorf("发送预缓冲音频帧失败: %v", err)
+		if !ok {
+			break
 		}
-		playPosition += h.serverAudioFrameDuration
-	}
 
-	// 发送剩余音频帧
-	remainingFrames := audioData[preBufferFrames:]
-	for i, chunk := range remainingFrames {
-		// 检查是否被打断或轮次变化
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-			h.LogInfo(fmt.Sprintf("音频发送被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
+		if h.isAudioSendInterrupted(round) {
+			h.LogInfo(fmt.Sprintf("音频发送被中断: 已发送帧=%d, 文本=%s", frameCount, text))
 			return nil
 		}
 
-		// 检查连接是否关闭
-		select {
-		case <-h.stopChan:
-			return nil
-		default:
+		if frameCount == 0 {
+			if onFirstFrame != nil {
+				if err := onFirstFrame(); err != nil {
+					return err
+				}
+			}
+			startTime = time.Now()
 		}
 
-		// 计算预期发送时间
-		expectedTime := startTime.Add(time.Duration(playPosition)*time.Millisecond - preBufferTime)
-		currentTime := time.Now()
-		delay := expectedTime.Sub(currentTime)
-
-		// 流控延迟处理
-		if delay > 0 {
-			// 使用简单的可中断睡眠
-			ticker := time.NewTicker(10 * time.Millisecond) // 固定10ms检查间隔
-			defer ticker.Stop()
-
-			endTime := time.Now().Add(delay)
-			for time.Now().Before(endTime) {
+		// 预缓冲之后按播放进度流控
+		if frameCount >= preBufferFrames {
+			expectedTime := startTime.Add(time.Duration(playPosition)*time.Millisecond - preBufferTime)
+			for time.Now().Before(expectedTime) {
 				select {
 				case <-ticker.C:
-					// 检查中断条件
-					if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-						h.LogInfo(fmt.Sprintf("音频发送在延迟中被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
+					if h.isAudioSendInterrupted(round) {
+						h.LogInfo(fmt.Sprintf("音频发送在延迟中被中断: 已发送帧=%d, 文本=%s", frameCount, text))
 						return nil
 					}
 				case <-h.stopChan:
@@ -252,11 +342,18 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 		if err := h.conn.WriteMessage(2, chunk); err != nil {
 			return fmt.Errorf("发送音频帧失败: %v", err)
 		}
-
 		playPosition += h.serverAudioFrameDuration
+		frameCount++
+	}
+
+	if frameCount == 0 {
+		return nil
+	}
+	if frameCount < preBufferFrames {
+		preBufferTime = time.Duration(h.serverAudioFrameDuration*frameCount) * time.Millisecond
 	}
 	time.Sleep(preBufferTime) // 确保预缓冲时间已过
 	spentTime := time.Since(startTime).Milliseconds()
-	h.LogInfo(fmt.Sprintf("音频帧发送完成: 总帧数=%d, 总时长=%dms, 总耗时:%dms 文本=%s", len(audioData), playPosition, spentTime, text))
+	h.LogInfo(fmt.Sprintf("音频帧发送完成: 总帧数=%d, 总时长=%dms, 总耗时:%dms 文本=%s", frameCount, playPosition, spentTime, text))
 	return nil
 }
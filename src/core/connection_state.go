@@ -0,0 +1,198 @@
+package core
+
+import (
+	"time"
+)
+
+// SessionState 连接的会话状态
+type SessionState int
+
+const (
+	StateIdle      SessionState = iota // 空闲，等待客户端拾音或主动推送
+	StateListening                     // 客户端拾音中
+	StateThinking                      // 新的对话轮次已开始，等待LLM回复、工具调用或TTS合成
+	StateSpeaking                      // 正在下发TTS音频
+	StateClosing                       // 连接关闭中，不再处理任何事件
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateListening:
+		return "listening"
+	case StateThinking:
+		return "thinking"
+	case StateSpeaking:
+		return "speaking"
+	case StateClosing:
+		return "closing"
+	default:
+		return "unknown"
+	}
+}
+
+// busy 是否正在处理对话轮次
+func (s SessionState) busy() bool {
+	return s == StateThinking || s == StateSpeaking
+}
+
+// SessionEvent 驱动会话状态变化的事件
+type SessionEvent string
+
+const (
+	EventListenStart SessionEvent = "listen_start" // 客户端开始拾音
+	EventListenStop  SessionEvent = "listen_stop"  // 客户端停止拾音
+	EventRoundStart  SessionEvent = "round_start"  // ASR结果、detect文本、图片或推送开始新的对话轮次
+	EventSpeechStart SessionEvent = "speech_start" // 当前轮次的句子开始下发
+	EventSpeechEnd   SessionEvent = "speech_end"   // 当前轮次的最后一句下发结束
+	EventAbort       SessionEvent = "abort"        // 客户端或服务端中止播报
+	EventClose       SessionEvent = "close"        // 关闭连接
+)
+
+// nextState 返回事件发生后的状态，listening 为客户端是否仍在拾音；
+// 事件在当前状态下无效时返回false
+//
+//	idle/listening --listen_start--> listening，thinking/speaking 收到 listen_start 时状态不变（realtime模式边说边听）
+//	listening      --listen_stop---> idle
+//	任意非closing  --round_start---> thinking
+//	thinking       --speech_start--> speaking
+//	thinking/speaking --speech_end/abort--> listening（仍在拾音）或 idle
+//	任意           --close---------> closing
+func nextState(state SessionState, event SessionEvent, listening bool) (SessionState, bool) {
+	resting := StateIdle
+	if listening {
+		resting = StateListening
+	}
+
+	if state == StateClosing {
+		return StateClosing, event == EventClose
+	}
+
+	switch event {
+	case EventClose:
+		return StateClosing, true
+	case EventRoundStart:
+		return StateThinking, true
+	case EventListenStart, EventListenStop:
+		if state.busy() {
+			return state, true
+		}
+		return resting, true
+	case EventSpeechStart:
+		return StateSpeaking, true
+	case EventSpeechEnd, EventAbort:
+		if state.busy() {
+			return resting, true
+		}
+		return state, true
+	}
+	return state, false
+}
+
+// State 返回当前会话状态
+func (h *ConnectionHandler) State() SessionState {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	return h.state
+}
+
+// OnStateChange 注册会话状态变化回调，回调按状态变化的顺序串行执行；
+// 回调中不能同步触发新的会话事件
+func (h *ConnectionHandler) OnStateChange(hook func(from, to SessionState)) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	h.stateHooks = append(h.stateHooks, hook)
+}
+
+// fire 串行处理会话事件：事件有效时在状态锁内执行 apply 修改会话字段，apply 返回true时切换状态，
+// 状态变化后按顺序调用回调。返回事件是否被处理
+func (h *ConnectionHandler) fire(event SessionEvent, apply func() bool) bool {
+	h.stateMu.Lock()
+	from := h.state
+	if _, ok := nextState(from, event, h.clientListening); !ok {
+		h.stateMu.Unlock()
+		h.logger.Debug("会话状态 %s 下忽略事件 %s", from, event)
+		return false
+	}
+	if apply != nil && !apply() {
+		h.stateMu.Unlock()
+		return false
+	}
+	to, _ := nextState(from, event, h.clientListening)
+	if to == from {
+		h.stateMu.Unlock()
+		return true
+	}
+	h.state = to
+	h.stateSince = time.Now()
+	hooks := h.stateHooks
+
+	// 先取得回调锁再释放状态锁，保证回调顺序与状态变化顺序一致
+	h.hookMu.Lock()
+	h.stateMu.Unlock()
+	defer h.hookMu.Unlock()
+
+	h.logger.Debug("会话状态变化: %s -> %s (%s)", from, to, event)
+	for _, hook := range hooks {
+		hook(from, to)
+	}
+	return true
+}
+
+// currentRound 返回当前对话轮次
+func (h *ConnectionHandler) currentRound() int {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	return h.talkRound
+}
+
+// lastTextIndex 返回当前轮次最后一句的索引，-1表示尚未确定
+func (h *ConnectionHandler) lastTextIndex() int {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	return h.tts_last_text_index
+}
+
+// setLastTextIndex 设置当前轮次最后一句的索引
+func (h *ConnectionHandler) setLastTextIndex(index int) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	h.tts_last_text_index = index
+}
+
+// listenMode 返回客户端拾音模式
+func (h *ConnectionHandler) listenMode() string {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	return h.clientListenMode
+}
+
+// setListenMode 设置客户端拾音模式
+func (h *ConnectionHandler) setListenMode(mode string) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	h.clientListenMode = mode
+}
+
+// appendAsrText manual模式下累加ASR识别文本，返回累计文本以及客户端是否已停止拾音
+func (h *ConnectionHandler) appendAsrText(result string) (string, bool) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	h.client_asr_text += result
+	return h.client_asr_text, h.clientVoiceStop
+}
+
+// setCloseAfterChat 标记本轮对话结束后关闭连接
+func (h *ConnectionHandler) setCloseAfterChat() {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	h.closeAfterChat = true
+}
+
+// shouldCloseAfterChat 本轮对话结束后是否关闭连接
+func (h *ConnectionHandler) shouldCloseAfterChat() bool {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	return h.closeAfterChat
+}
@@ -0,0 +1,302 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// fakeConn 模拟客户端连接，记录下发的文本消息
+type fakeConn struct {
+	mu       sync.Mutex
+	messages []map[string]interface{}
+	closed   bool
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	return 0, nil, errors.New("fake connection")
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) GetID() string                      { return "fake" }
+func (c *fakeConn) GetType() string                    { return "fake" }
+func (c *fakeConn) IsClosed() bool                     { return false }
+func (c *fakeConn) GetLastActiveTime() time.Time       { return time.Now() }
+func (c *fakeConn) IsStale(timeout time.Duration) bool { return false }
+
+// ttsStates 返回下发的 tts 消息状态
+func (c *fakeConn) ttsStates() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var states []string
+	for _, msg := range c.messages {
+		if msg["type"] == "tts" {
+			states = append(states, msg["state"].(string))
+		}
+	}
+	return states
+}
+
+// fakeASR 空实现的语音识别提供者
+type fakeASR struct{}
+
+func (fakeASR) Initialize() error                                           { return nil }
+func (fakeASR) Cleanup() error                                              { return nil }
+func (fakeASR) Transcribe(ctx context.Context, data []byte) (string, error) { return "", nil }
+func (fakeASR) AddAudio(data []byte) error                                  { return nil }
+func (fakeASR) SetListener(listener providers.AsrEventListener)             {}
+func (fakeASR) Reset() error                                                { return nil }
+func (fakeASR) GetSilenceCount() int                                        { return 0 }
+func (fakeASR) ResetStartListenTime()                                       {}
+
+func newTestHandler(t *testing.T) (*ConnectionHandler, *fakeConn) {
+	t.Helper()
+	cfg := &configs.Config{}
+	cfg.Log.LogDir = t.TempDir()
+	cfg.Log.LogFile = "test.log"
+	cfg.Log.LogLevel = "ERROR"
+	logger, err := utils.NewLogger(cfg)
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	conn := &fakeConn{}
+	h := &ConnectionHandler{
+		config:              cfg,
+		logger:              logger,
+		conn:                conn,
+		sessionID:           "test-session",
+		clientListenMode:    "auto",
+		stopChan:            make(chan struct{}),
+		ttsQueue:            make(chan ttsTask, 100),
+		audioMessagesQueue:  make(chan audioTask, 100),
+		tts_last_text_index: -1,
+		state:               StateIdle,
+		stateSince:          time.Now(),
+		ctx:                 context.Background(),
+	}
+	h.providers.asr = fakeASR{}
+	return h, conn
+}
+
+func listen(t *testing.T, h *ConnectionHandler, state, mode string) {
+	t.Helper()
+	msg := map[string]interface{}{"type": "listen", "state": state}
+	if mode != "" {
+		msg["mode"] = mode
+	}
+	if err := h.handleListenMessage(msg); err != nil {
+		t.Fatalf("处理listen %s失败: %v", state, err)
+	}
+}
+
+func TestNextState(t *testing.T) {
+	tests := []struct {
+		state     SessionState
+		event     SessionEvent
+		listening bool
+		want      SessionState
+		wantOK    bool
+	}{
+		{StateIdle, EventListenStart, true, StateListening, true},
+		{StateListening, EventListenStop, false, StateIdle, true},
+		{StateSpeaking, EventListenStart, true, StateSpeaking, true},
+		{StateListening, EventRoundStart, true, StateThinking, true},
+		{StateSpeaking, EventRoundStart, false, StateThinking, true},
+		{StateThinking, EventSpeechStart, false, StateSpeaking, true},
+		{StateSpeaking, EventSpeechEnd, false, StateIdle, true},
+		{StateSpeaking, EventSpeechEnd, true, StateListening, true},
+		{StateIdle, EventSpeechEnd, false, StateIdle, true},
+		{StateThinking, EventAbort, true, StateListening, true},
+		{StateSpeaking, EventClose, false, StateClosing, true},
+		{StateClosing, EventRoundStart, false, StateClosing, false},
+		{StateClosing, EventAbort, false, StateClosing, false},
+		{StateClosing, EventClose, false, StateClosing, true},
+	}
+	for _, tt := range tests {
+		got, ok := nextState(tt.state, tt.event, tt.listening)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("nextState(%s, %s, %v) = (%s, %v)，期望 (%s, %v)",
+				tt.state, tt.event, tt.listening, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSessionStateTransitions(t *testing.T) {
+	h, conn := newTestHandler(t)
+
+	var mu sync.Mutex
+	var changes []string
+	h.OnStateChange(func(from, to SessionState) {
+		mu.Lock()
+		changes = append(changes, from.String()+"->"+to.String())
+		mu.Unlock()
+	})
+
+	listen(t, h, "start", "auto")
+	if h.State() != StateListening {
+		t.Fatalf("listen start 后状态 = %s，期望 listening", h.State())
+	}
+
+	ctx, round := h.startRound(h.ctx)
+	if h.State() != StateThinking || round != 1 {
+		t.Fatalf("开始轮次后状态 = %s，轮次 = %d", h.State(), round)
+	}
+	if _, listening := h.ListenState(); listening {
+		t.Error("auto模式开始回复后应停止拾音")
+	}
+
+	h.setLastTextIndex(2)
+	if err := h.sendSentenceStart("你好", 1, round); err != nil {
+		t.Fatalf("发送句子开始失败: %v", err)
+	}
+	if h.State() != StateSpeaking {
+		t.Fatalf("首句下发后状态 = %s，期望 speaking", h.State())
+	}
+
+	h.finishAudioMessage(round, 1)
+	if h.State() != StateSpeaking {
+		t.Fatalf("非最后一句结束后状态 = %s，期望 speaking", h.State())
+	}
+	h.finishAudioMessage(round-1, 2)
+	if h.State() != StateSpeaking {
+		t.Fatalf("过期轮次的结束不应改变状态，当前 %s", h.State())
+	}
+	h.finishAudioMessage(round, 2)
+	if h.State() != StateIdle {
+		t.Fatalf("最后一句结束后状态 = %s，期望 idle", h.State())
+	}
+	if states := conn.ttsStates(); len(states) != 2 || states[1] != "stop" {
+		t.Errorf("tts消息 = %v，期望 [sentence_start stop]", states)
+	}
+
+	// realtime模式边说边听，中止后回到拾音状态并取消本轮上下文
+	listen(t, h, "start", "realtime")
+	ctx, _ = h.startRound(h.ctx)
+	if err := h.clientAbortChat(); err != nil {
+		t.Fatalf("中止对话失败: %v", err)
+	}
+	if h.State() != StateListening {
+		t.Fatalf("中止后状态 = %s，期望 listening", h.State())
+	}
+	if ctx.Err() == nil {
+		t.Error("中止后本轮上下文应被取消")
+	}
+
+	h.Close()
+	if h.State() != StateClosing {
+		t.Fatalf("关闭后状态 = %s，期望 closing", h.State())
+	}
+	if ctx, _ := h.startRound(h.ctx); ctx.Err() == nil {
+		t.Error("关闭后不应开始新的轮次")
+	}
+
+	want := []string{
+		"idle->listening", "listening->thinking", "thinking->speaking", "speaking->idle",
+		"idle->listening", "listening->thinking", "thinking->listening", "listening->closing",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) != len(want) {
+		t.Fatalf("状态变化 = %v，期望 %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("状态变化 = %v，期望 %v", changes, want)
+		}
+	}
+}
+
+// TestSessionEventsConcurrent 在 -race 下并发触发各类事件，验证状态字段没有数据竞争且回调顺序一致
+func TestSessionEventsConcurrent(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	var mu sync.Mutex
+	last := StateIdle
+	h.OnStateChange(func(from, to SessionState) {
+		mu.Lock()
+		defer mu.Unlock()
+		if from != last {
+			t.Errorf("状态变化回调乱序: 上一次进入 %s，本次从 %s 变化", last, from)
+		}
+		last = to
+	})
+
+	const workers, iterations = 4, 100
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					fn(i)
+				}
+			}()
+		}
+	}
+
+	// 文本协程：拾音开始与结束
+	run(func(i int) {
+		state := "start"
+		if i%2 == 1 {
+			state = "stop"
+		}
+		h.handleListenMessage(map[string]interface{}{"type": "listen", "state": state, "mode": "manual"})
+	})
+	// ASR回调：累加识别文本
+	run(func(i int) {
+		h.appendAsrText("字")
+		h.listenMode()
+	})
+	// 对话轮次：开始新轮次并播放
+	run(func(i int) {
+		_, round := h.startRound(h.ctx)
+		h.setLastTextIndex(1)
+		h.sendSentenceStart("你好", 1, round)
+		h.finishAudioMessage(round, 1)
+	})
+	// 中止与查询
+	run(func(i int) {
+		if i%10 == 0 {
+			h.clientAbortChat()
+		}
+		h.IsBusy()
+		h.GetTalkRound()
+		h.ListenState()
+		h.isAudioSendInterrupted(i)
+	})
+	wg.Wait()
+
+	if round := h.currentRound(); round != workers*iterations {
+		t.Errorf("轮次 = %d，期望 %d", round, workers*iterations)
+	}
+	h.Close()
+	if h.State() != StateClosing {
+		t.Errorf("关闭后状态 = %s，期望 closing", h.State())
+	}
+}
@@ -0,0 +1,316 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/mcp"
+	"xiaozhi-server-go/src/core/metrics"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultToolCallMaxIterations = 5
+	defaultToolCallTimeout       = 30 * time.Second
+	defaultToolCallFillerDelay   = 2 * time.Second
+)
+
+// defaultFillerPhrases 工具执行较慢时播放的等待提示语
+var defaultFillerPhrases = []string{
+	"请稍等，我查一下。",
+	"好的，马上为你处理。",
+	"稍等一下哦。",
+}
+
+// toolCallSettings 工具调用配置，未配置或配置无效时使用默认值
+type toolCallSettings struct {
+	maxIterations int
+	timeout       time.Duration
+	fillerDelay   time.Duration
+	fillerPhrases []string
+}
+
+func (h *ConnectionHandler) toolCallSettings() toolCallSettings {
+	settings := toolCallSettings{
+		maxIterations: defaultToolCallMaxIterations,
+		timeout:       defaultToolCallTimeout,
+		fillerDelay:   defaultToolCallFillerDelay,
+		fillerPhrases: defaultFillerPhrases,
+	}
+	if h.config == nil {
+		return settings
+	}
+	cfg := h.config.ToolCall
+	if cfg.MaxIterations > 0 {
+		settings.maxIterations = cfg.MaxIterations
+	}
+	if d, err := time.ParseDuration(cfg.Timeout); err == nil && d > 0 {
+		settings.timeout = d
+	}
+	if d, err := time.ParseDuration(cfg.FillerDelay); err == nil {
+		settings.fillerDelay = d // 配置为0时不播放等待提示语
+	}
+	if len(cfg.FillerPhrases) > 0 {
+		settings.fillerPhrases = cfg.FillerPhrases
+	}
+	return settings
+}
+
+// mergeToolCallDelta 合并流式响应中的工具调用片段，同一次响应中的多个工具调用按 Index 区分
+func mergeToolCallDelta(calls []types.ToolCall, delta types.ToolCall) []types.ToolCall {
+	for i := range calls {
+		if calls[i].Index != delta.Index {
+			continue
+		}
+		if delta.ID != "" && calls[i].ID != "" && delta.ID != calls[i].ID {
+			// 部分模型不返回 Index，以新的ID区分不同的工具调用
+			break
+		}
+		if delta.ID != "" {
+			calls[i].ID = delta.ID
+		}
+		if delta.Function.Name != "" {
+			calls[i].Function.Name = delta.Function.Name
+		}
+		calls[i].Function.Arguments += delta.Function.Arguments
+		return calls
+	}
+	if delta.ID != "" || delta.Function.Name != "" {
+		delta.Index = len(calls)
+		return append(calls, delta)
+	}
+	if len(calls) > 0 {
+		// 缺少ID和名称的参数片段归入最后一个工具调用
+		calls[len(calls)-1].Function.Arguments += delta.Function.Arguments
+	}
+	return calls
+}
+
+// parseTextToolCall 解析以 <tool_call>{"name":...,"arguments":...} 文本形式返回的工具调用
+func parseTextToolCall(content string) (types.ToolCall, bool) {
+	a := utils.Extract_json_from_string(content)
+	if a == nil {
+		return types.ToolCall{}, false
+	}
+	name, ok := a["name"].(string)
+	if !ok || name == "" {
+		return types.ToolCall{}, false
+	}
+	arguments, err := json.Marshal(a["arguments"])
+	if err != nil {
+		return types.ToolCall{}, false
+	}
+	return types.ToolCall{
+		ID:   uuid.New().String(),
+		Type: "function",
+		Function: types.FunctionCall{
+			Name:      name,
+			Arguments: string(arguments),
+		},
+	}, true
+}
+
+// handleToolCalls 并行执行LLM返回的工具调用，需要LLM继续处理的结果写入对话历史后再次请求LLM
+func (h *ConnectionHandler) handleToolCalls(ctx context.Context, calls []types.ToolCall, round int, depth int, textIndex int) error {
+	for i := range calls {
+		if calls[i].ID == "" {
+			calls[i].ID = uuid.New().String()
+		}
+		calls[i].Type = "function"
+		calls[i].Index = i
+	}
+	h.LogInfo(fmt.Sprintf("第 %d 轮工具调用，共 %d 个: %s", depth+1, len(calls), toolCallNames(calls)))
+
+	// 工具执行期间不结束播放，后续回复会重新设置最后一段的索引
+	h.setLastTextIndex(-1)
+	results := h.executeToolCalls(ctx, calls, round, &textIndex)
+	if ctx.Err() != nil {
+		h.LogInfo("对话已取消，丢弃工具调用结果")
+		return nil
+	}
+
+	var llmCalls []types.ToolCall
+	var llmResults []string
+	for i, result := range results {
+		if result.Action != types.ActionTypeReqLLM {
+			h.handleFunctionResult(result, round, &textIndex)
+			continue
+		}
+		llmCalls = append(llmCalls, calls[i])
+		llmResults = append(llmResults, toolResultText(result.Result))
+	}
+	if len(llmCalls) == 0 {
+		// 所有结果处理完后再设置最后一段的索引，避免提前结束播放
+		if h.lastTextIndex() == -1 && textIndex > 0 {
+			h.setLastTextIndex(textIndex)
+		}
+		return nil
+	}
+
+	h.dialogueManager.Put(chat.Message{
+		Role:      "assistant",
+		ToolCalls: llmCalls,
+	})
+	for i, call := range llmCalls {
+		h.LogInfo(fmt.Sprintf("函数调用结果: %s(%s) => %s", call.Function.Name, call.Function.Arguments, llmResults[i]))
+		h.dialogueManager.Put(chat.Message{
+			Role:       "tool",
+			ToolCallID: call.ID,
+			Content:    llmResults[i],
+		})
+	}
+	return h.genResponseWithTools(ctx, h.llmDialogue(), round, depth+1, textIndex)
+}
+
+// executeToolCalls 并行执行工具调用，结果与 calls 一一对应；
+// 超过等待时间仍未全部完成时播放一句等待提示语
+func (h *ConnectionHandler) executeToolCalls(ctx context.Context, calls []types.ToolCall, round int, textIndex *int) []types.ActionResponse {
+	settings := h.toolCallSettings()
+	results := make([]types.ActionResponse, len(calls))
+
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = h.executeToolCall(ctx, calls[i], settings.timeout)
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	if settings.fillerDelay > 0 && len(settings.fillerPhrases) > 0 {
+		timer := time.NewTimer(settings.fillerDelay)
+		defer timer.Stop()
+		select {
+		case <-done:
+			return results
+		case <-ctx.Done():
+		case <-timer.C:
+			phrase := settings.fillerPhrases[rand.Intn(len(settings.fillerPhrases))]
+			*textIndex++
+			h.LogInfo(fmt.Sprintf("工具执行超过 %v，播放等待提示: %s, index: %d, round:%d", settings.fillerDelay, phrase, *textIndex, round))
+			h.SpeakAndPlay(phrase, *textIndex, round)
+		}
+	}
+	<-done
+	return results
+}
+
+// executeToolCall 执行单个工具调用，超时或出错时返回交给LLM处理的错误说明
+func (h *ConnectionHandler) executeToolCall(ctx context.Context, call types.ToolCall, timeout time.Duration) types.ActionResponse {
+	name := call.Function.Name
+	metrics.ToolCalls.Inc(name)
+	start := time.Now()
+
+	toolCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type toolOutcome struct {
+		result types.ActionResponse
+		err    error
+	}
+	resultCh := make(chan toolOutcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				h.LogError(fmt.Sprintf("工具 %s 执行发生panic: %v", name, r))
+				resultCh <- toolOutcome{
+					result: types.ActionResponse{Action: types.ActionTypeReqLLM, Result: fmt.Sprintf("工具 %s 执行失败", name)},
+					err:    fmt.Errorf("panic: %v", r),
+				}
+			}
+		}()
+		result, err := h.callTool(toolCtx, call)
+		resultCh <- toolOutcome{result: result, err: err}
+	}()
+
+	select {
+	case outcome := <-resultCh:
+		result := outcome.result
+		if outcome.err != nil || result.Action == types.ActionTypeError || result.Action == types.ActionTypeNotFound {
+			metrics.ToolCallErrors.Inc(name)
+		}
+		h.LogInfo(fmt.Sprintf("工具 %s 执行完成，耗时 %v", name, time.Since(start)))
+		return result
+	case <-toolCtx.Done():
+		metrics.ToolCallErrors.Inc(name)
+		if ctx.Err() != nil {
+			return types.ActionResponse{Action: types.ActionTypeNone, Result: "对话已取消"}
+		}
+		h.LogError(fmt.Sprintf("工具 %s 执行超时(%v)", name, timeout))
+		return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: fmt.Sprintf("工具 %s 执行超时", name)}
+	}
+}
+
+// callTool 调用MCP工具或本地注册的函数，返回的错误仅用于统计，错误说明已写入结果交给LLM处理
+func (h *ConnectionHandler) callTool(ctx context.Context, call types.ToolCall) (types.ActionResponse, error) {
+	name := call.Function.Name
+	rawArguments := call.Function.Arguments
+	if rawArguments == "" {
+		rawArguments = "{}"
+	}
+	arguments := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
+		h.LogError(fmt.Sprintf("函数调用参数解析失败: %s(%s) %v", name, rawArguments, err))
+		return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: fmt.Sprintf("工具 %s 的参数不是有效的JSON: %v", name, err)}, err
+	}
+	h.LogInfo(fmt.Sprintf("函数调用: %s %v", name, arguments))
+
+	var result interface{}
+	var err error
+	switch {
+	case h.mcpManager != nil && h.mcpManager.IsMCPTool(name):
+		result, err = h.mcpManager.ExecuteTool(mcp.WithDeviceID(ctx, h.deviceID), name, arguments)
+	case h.functionRegister.FunctionExists(name):
+		result, err = h.functionRegister.CallFunction(ctx, name, json.RawMessage(rawArguments))
+	default:
+		h.LogError(fmt.Sprintf("未找到工具: %s", name))
+		return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: fmt.Sprintf("未找到工具 %s", name)}, fmt.Errorf("未找到工具 %s", name)
+	}
+	if err != nil {
+		h.LogError(fmt.Sprintf("工具 %s 调用失败: %v", name, err))
+		if result == nil {
+			result = fmt.Sprintf("工具 %s 调用失败: %v", name, err)
+		}
+	}
+
+	if actionResult, ok := result.(types.ActionResponse); ok {
+		return actionResult, err
+	}
+	return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: result}, err
+}
+
+// toolResultText 将工具结果转换为写入对话历史的文本
+func toolResultText(result interface{}) string {
+	switch v := result.(type) {
+	case string:
+		return v
+	case nil:
+		return "工具执行完成，无返回内容"
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf("%v", result)
+	}
+	return string(data)
+}
+
+func toolCallNames(calls []types.ToolCall) string {
+	names := make([]string, 0, len(calls))
+	for _, call := range calls {
+		names = append(names, call.Function.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"xiaozhi-server-go/src/core/function"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// TestToolCallResponsesKeepTextIndex 并行工具的直接回复沿用本轮文本索引，全部处理完后才设置最后一段
+func TestToolCallResponsesKeepTextIndex(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.config.ToolCall.FillerDelay = "0s"
+	h.functionRegister = function.NewFunctionRegistry()
+	for name, reply := range map[string]string{"turn_on": "好的。灯已打开。", "set_volume": "音量已调到50。"} {
+		reply := reply
+		handler := func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			return types.ActionResponse{Action: types.ActionTypeResponse, Response: reply}, nil
+		}
+		if err := h.functionRegister.RegisterHandler(name, openai.Tool{}, handler); err != nil {
+			t.Fatalf("注册函数失败: %v", err)
+		}
+	}
+
+	calls := []types.ToolCall{
+		{ID: "1", Function: types.FunctionCall{Name: "turn_on"}},
+		{ID: "2", Function: types.FunctionCall{Name: "set_volume"}},
+	}
+	// 本轮已播放两段LLM回复
+	if err := h.handleToolCalls(context.Background(), calls, 0, 0, 2); err != nil {
+		t.Fatalf("handleToolCalls() 失败: %v", err)
+	}
+
+	var indexes []int
+	for len(h.ttsQueue) > 0 {
+		indexes = append(indexes, (<-h.ttsQueue).textIndex)
+	}
+	want := []int{3, 4, 5}
+	if len(indexes) != len(want) {
+		t.Fatalf("播放的文本索引 = %v，期望 %v", indexes, want)
+	}
+	for i := range want {
+		if indexes[i] != want[i] {
+			t.Fatalf("播放的文本索引 = %v，期望 %v", indexes, want)
+		}
+	}
+	if got := h.lastTextIndex(); got != 5 {
+		t.Errorf("最后一段的索引 = %d，期望 5", got)
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// defaultTTSConcurrency 默认同时合成的句子数
+	defaultTTSConcurrency = 2
+	// ttsPendingResults 已开始合成、等待按顺序发送的句子上限
+	ttsPendingResults = 100
+)
+
+// ttsTask 待合成的句子
+type ttsTask struct {
+	ctx       context.Context // 所属轮次的上下文
+	text      string
+	round     int // 轮次
+	textIndex int
+}
+
+// audioTask 待发送的音频
+type audioTask struct {
+	filepath    string
+	text        string
+	round       int // 轮次
+	textIndex   int
+	frames      <-chan []byte      // 流式TTS音频帧，非空时忽略filepath
+	cancel      context.CancelFunc // 取消流式TTS合成
+	synthesized <-chan struct{}    // 流式TTS合成协程退出时关闭，合成期间占用并发名额
+}
+
+// ttsConcurrency 当前TTS提供者同时合成的句子数，提供者可能按用户设置切换，每个句子入队时重新读取
+func (h *ConnectionHandler) ttsConcurrency() int {
+	if h.config != nil {
+		if n := h.config.TTS[h.providerName("TTS")].Concurrency; n > 0 {
+			return n
+		}
+	}
+	return defaultTTSConcurrency
+}
+
+// processTTSQueueCoroutine 处理TTS队列：同时合成多个后续句子，
+// 合成结果按入队顺序交给音频发送协程，保证同一轮次内按 textIndex 顺序播放；
+// 流式合成在合成协程退出前一直占用并发名额，与文件合成共用同一上限
+func (h *ConnectionHandler) processTTSQueueCoroutine() {
+	pending := make(chan chan audioTask, ttsPendingResults)
+	go h.forwardAudioTasks(pending)
+
+	inflight := 0
+	done := make(chan struct{}, ttsPendingResults)
+	for {
+		select {
+		case <-h.stopChan:
+			return
+		case <-done:
+			inflight--
+		case task := <-h.ttsQueue:
+			for inflight >= h.ttsConcurrency() {
+				select {
+				case <-h.stopChan:
+					return
+				case <-done:
+					inflight--
+				}
+			}
+
+			result := make(chan audioTask, 1)
+			select {
+			case <-h.stopChan:
+				return
+			case pending <- result:
+			}
+			inflight++
+			go func() {
+				defer func() {
+					select {
+					case done <- struct{}{}:
+					case <-h.stopChan:
+					}
+				}()
+				audio := h.processTTSTask(task.ctx, task.text, task.textIndex, task.round)
+				result <- audio
+				if audio.synthesized != nil {
+					select {
+					case <-audio.synthesized:
+					case <-h.stopChan:
+					}
+				}
+			}()
+		}
+	}
+}
+
+// forwardAudioTasks 按入队顺序等待合成结果并交给音频发送协程，过期轮次的结果直接丢弃
+func (h *ConnectionHandler) forwardAudioTasks(pending <-chan chan audioTask) {
+	for {
+		var result chan audioTask
+		select {
+		case <-h.stopChan:
+			return
+		case result = <-pending:
+		}
+
+		var task audioTask
+		select {
+		case <-h.stopChan:
+			return
+		case task = <-result:
+		}
+
+		if current := h.currentRound(); task.round != current {
+			h.LogInfo(fmt.Sprintf("丢弃过期轮次的TTS结果: 任务轮次=%d, 当前轮次=%d, 文本=%s", task.round, current, task.text))
+			if task.cancel != nil {
+				task.cancel()
+			}
+			h.deleteAudioFileIfNeeded(task.filepath, "丢弃过期轮次的TTS结果时")
+			continue
+		}
+
+		select {
+		case <-h.stopChan:
+			return
+		case h.audioMessagesQueue <- task:
+		}
+	}
+}
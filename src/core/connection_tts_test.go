@@ -0,0 +1,176 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers"
+)
+
+// fakeTTS 模拟TTS提供者，句子越靠前合成越慢，并记录同时合成的最大数量
+type fakeTTS struct {
+	delay func(text string) time.Duration
+
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (p *fakeTTS) Initialize() error           { return nil }
+func (p *fakeTTS) Cleanup() error              { return nil }
+func (p *fakeTTS) SetVoice(voice string) error { return nil }
+func (p *fakeTTS) peakConcurrency() int        { p.mu.Lock(); defer p.mu.Unlock(); return p.peak }
+func (p *fakeTTS) ToTTS(ctx context.Context, text string) (string, error) {
+	if err := p.synthesize(ctx, text); err != nil {
+		return "", err
+	}
+	return "fake-" + text, nil
+}
+
+// synthesize 模拟一次合成，合成期间计入同时合成的数量
+func (p *fakeTTS) synthesize(ctx context.Context, text string) error {
+	p.mu.Lock()
+	p.running++
+	if p.running > p.peak {
+		p.peak = p.running
+	}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(p.delay(text)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fakeStreamingTTS 模拟流式TTS提供者，合成结束后输出一帧以文本为内容的音频
+type fakeStreamingTTS struct {
+	*fakeTTS
+}
+
+func (p fakeStreamingTTS) ToTTSStream(ctx context.Context, text string, format string, sampleRate int, frames chan<- []byte) error {
+	if err := p.synthesize(ctx, text); err != nil {
+		return err
+	}
+	select {
+	case frames <- []byte(text):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newTTSTestHandler(t *testing.T, tts providers.TTSProvider, concurrency int) *ConnectionHandler {
+	t.Helper()
+	h, _ := newTestHandler(t)
+	h.config.SelectedModule = map[string]string{"TTS": "fake"}
+	h.config.TTS = map[string]configs.TTSConfig{"fake": {Type: "fake", Concurrency: concurrency}}
+	h.providers.tts = tts
+	go h.processTTSQueueCoroutine()
+	t.Cleanup(func() { close(h.stopChan) })
+	return h
+}
+
+func receiveAudio(t *testing.T, h *ConnectionHandler) audioTask {
+	t.Helper()
+	select {
+	case task := <-h.audioMessagesQueue:
+		return task
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待合成结果超时")
+		return audioTask{}
+	}
+}
+
+func TestTTSParallelSynthesisOrderedPlayback(t *testing.T) {
+	const sentences = 6
+	tts := &fakeTTS{delay: func(text string) time.Duration {
+		var index int
+		fmt.Sscanf(text, "句子%d", &index)
+		return time.Duration(sentences-index+1) * 20 * time.Millisecond
+	}}
+	h := newTTSTestHandler(t, tts, 3)
+
+	_, round := h.startRound(h.ctx)
+	for i := 1; i <= sentences; i++ {
+		h.SpeakAndPlay(fmt.Sprintf("句子%d", i), i, round)
+	}
+
+	for i := 1; i <= sentences; i++ {
+		task := receiveAudio(t, h)
+		if task.textIndex != i || task.filepath != fmt.Sprintf("fake-句子%d", i) {
+			t.Fatalf("第 %d 个发送的音频为 %d(%s)，期望按顺序发送", i, task.textIndex, task.filepath)
+		}
+	}
+	if peak := tts.peakConcurrency(); peak < 2 || peak > 3 {
+		t.Errorf("同时合成的句子数 = %d，期望 2~3", peak)
+	}
+}
+
+func TestTTSStreamingHoldsConcurrencySlot(t *testing.T) {
+	const sentences = 5
+	tts := fakeStreamingTTS{&fakeTTS{delay: func(text string) time.Duration {
+		return 50 * time.Millisecond
+	}}}
+	h := newTTSTestHandler(t, tts, 2)
+
+	_, round := h.startRound(h.ctx)
+	for i := 1; i <= sentences; i++ {
+		h.SpeakAndPlay(fmt.Sprintf("句子%d", i), i, round)
+	}
+
+	for i := 1; i <= sentences; i++ {
+		task := receiveAudio(t, h)
+		if task.frames == nil {
+			t.Fatalf("第 %d 句未使用流式合成", i)
+		}
+		var got []string
+		for frame := range task.frames {
+			got = append(got, string(frame))
+		}
+		if want := fmt.Sprintf("句子%d", i); task.textIndex != i || len(got) != 1 || got[0] != want {
+			t.Fatalf("第 %d 个发送的音频为 %d %v，期望 %s", i, task.textIndex, got, want)
+		}
+	}
+	// 流式合成在合成协程退出前占用名额，同时合成的句子数不超过配置
+	if peak := tts.peakConcurrency(); peak != 2 {
+		t.Errorf("同时合成的句子数 = %d，期望 2", peak)
+	}
+}
+
+func TestTTSDiscardsStaleRound(t *testing.T) {
+	tts := &fakeTTS{delay: func(text string) time.Duration {
+		if text == "旧句子" {
+			return 200 * time.Millisecond
+		}
+		return 10 * time.Millisecond
+	}}
+	h := newTTSTestHandler(t, tts, 2)
+
+	_, oldRound := h.startRound(h.ctx)
+	h.SpeakAndPlay("旧句子", 1, oldRound)
+	time.Sleep(20 * time.Millisecond) // 等待旧句子开始合成
+
+	_, round := h.startRound(h.ctx)
+	h.SpeakAndPlay("新句子", 1, round)
+
+	task := receiveAudio(t, h)
+	if task.round != round || task.text != "新句子" {
+		t.Fatalf("收到轮次 %d 的音频 %q，期望只收到新轮次的结果", task.round, task.text)
+	}
+	select {
+	case task := <-h.audioMessagesQueue:
+		t.Fatalf("过期轮次的结果未被丢弃: 轮次 %d, %q", task.round, task.text)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+)
+
+// userSettingModules 用户设置可覆盖的模块，按顺序切换
+var userSettingModules = []string{"ASR", "LLM", "TTS", "VLLLM"}
+
+// loadUserSetting 按 设备 -> 绑定用户 -> 用户设置 查找当前设备的个性化设置，未找到时返回nil
+func (h *ConnectionHandler) loadUserSetting() (*models.UserSetting, error) {
+	binding, err := auth.NewBindingStore(database.DB).Get(h.deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if binding == nil || binding.Status != models.DeviceBindingBound || binding.Owner == "" {
+		return nil, nil
+	}
+
+	var user models.User
+	err = database.DB.Preload("Setting").Where("username = ?", binding.Owner).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询用户 %s 失败: %v", binding.Owner, err)
+	}
+	if user.Setting.ID == 0 {
+		return nil, nil
+	}
+	return &user.Setting, nil
+}
+
+// applyUserSetting 启用私有配置时，按设备所属用户的设置切换提供者、提示词和快速回复词。
+// 只在文本协程处理首个hello、音频和TTS协程启动前执行，切换提供者时没有其他协程在使用旧提供者
+func (h *ConnectionHandler) applyUserSetting() {
+	if atomic.LoadInt32(&h.workersStarted) == 1 {
+		return
+	}
+	if !h.config.UsePrivateConfig || database.DB == nil || h.deviceID == "" {
+		return
+	}
+
+	setting, err := h.loadUserSetting()
+	if err != nil {
+		h.LogError(fmt.Sprintf("加载用户设置失败: %v", err))
+		return
+	}
+	if setting == nil {
+		return
+	}
+
+	selections := map[string]string{
+		"ASR":   setting.SelectedASR,
+		"LLM":   setting.SelectedLLM,
+		"TTS":   setting.SelectedTTS,
+		"VLLLM": setting.SelectedVLLLM,
+	}
+	swapped := make(map[string]bool)
+	if h.poolManager != nil && h.providerSet != nil {
+		for _, module := range userSettingModules {
+			name := selections[module]
+			if name == "" || name == h.providerSet.ProviderName(module) {
+				continue
+			}
+			if err := h.poolManager.SwapProvider(h.providerSet, module, name); err != nil {
+				h.LogError(fmt.Sprintf("切换用户%s提供者 %s 失败: %v", module, name, err))
+				continue
+			}
+			swapped[module] = true
+			h.LogInfo(fmt.Sprintf("已按用户设置切换%s提供者: %s", module, name))
+		}
+
+		h.providers.asr = h.providerSet.ASR
+		h.providers.llm = h.providerSet.LLM
+		h.providers.tts = h.providerSet.TTS
+		h.providers.vlllm = h.providerSet.VLLLM
+	}
+
+	if swapped["TTS"] {
+		h.initTTSVoice()
+	}
+	if swapped["LLM"] && h.dialogueManager.HasMemory() {
+		// 会话摘要改用用户选择的LLM生成
+		h.dialogueManager.SetMemory(chat.NewDBMemory(database.DB, h.deviceID, h.providers.llm, h.logger))
+	}
+
+	if setting.PromptOverride != "" {
+		h.dialogueManager.SetSystemMessage(setting.PromptOverride)
+	}
+
+	if len(setting.QuickReplyWords) > 0 {
+		var words []string
+		if err := json.Unmarshal(setting.QuickReplyWords, &words); err != nil {
+			h.LogError(fmt.Sprintf("解析用户快速回复词失败: %v", err))
+		} else if len(words) > 0 {
+			h.quickReplyWords = words
+		}
+	}
+}
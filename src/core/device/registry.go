@@ -0,0 +1,495 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrDeviceNotFound 设备未注册
+	ErrDeviceNotFound = errors.New("设备不存在")
+	// ErrDeviceOffline 设备当前不在线
+	ErrDeviceOffline = errors.New("设备不在线")
+)
+
+// 断开原因
+const (
+	DisconnectClosed   = "closed"   // 连接正常结束
+	DisconnectKicked   = "kicked"   // 管理员强制断开
+	DisconnectReplaced = "replaced" // 同一设备建立了新连接
+	DisconnectRestart  = "restart"  // 服务重启时未正常结束的会话
+)
+
+// Session 设备的在线会话，由WebSocket连接处理器实现
+type Session interface {
+	SessionID() string
+	GetTalkRound() int
+	// ListenState 返回拾音模式及当前是否处于拾音状态
+	ListenState() (mode string, listening bool)
+	// LastActiveTime 最近一次收到客户端消息的时间
+	LastActiveTime() time.Time
+	// Abort 中止服务端正在进行的播报
+	Abort() error
+	// Disconnect 强制断开连接
+	Disconnect()
+}
+
+// Transcript 最近一轮对话的用户文本和助手回复
+type Transcript struct {
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AudioParams 客户端在hello中上报的音频参数
+type AudioParams struct {
+	Format        string `json:"format"`
+	SampleRate    int    `json:"sample_rate"`
+	Channels      int    `json:"channels"`
+	FrameDuration int    `json:"frame_duration"`
+}
+
+// Status 设备状态
+type Status struct {
+	DeviceID        string      `json:"device_id"`
+	SessionID       string      `json:"session_id,omitempty"`
+	ClientID        string      `json:"client_id"`
+	DeviceName      string      `json:"device_name"`
+	FirmwareVersion string      `json:"firmware_version"`
+	Online          bool        `json:"online"`
+	AudioParams     AudioParams `json:"audio_params"`
+	TalkRound       int         `json:"talk_round"`
+	ListenMode      string      `json:"listen_mode,omitempty"`
+	Listening       bool        `json:"listening"`
+	ConnectedAt     *time.Time  `json:"connected_at,omitempty"`
+	DisconnectedAt  *time.Time  `json:"disconnected_at,omitempty"`
+	LastSeen        *time.Time  `json:"last_seen,omitempty"`
+}
+
+// ConnectInfo 设备连接时的基本信息
+type ConnectInfo struct {
+	ClientID    string
+	DeviceName  string
+	ConnectedAt time.Time
+}
+
+// entry 注册表中的设备记录
+type entry struct {
+	status  Status
+	session Session
+}
+
+// Registry 设备注册表，记录设备连接、断开、固件版本和音频参数，
+// 在线设备的对话轮次和拾音状态从会话实时读取，历史记录持久化到数据库
+type Registry struct {
+	mu      sync.RWMutex
+	devices map[string]*entry
+	db      *gorm.DB
+	logger  *utils.Logger
+}
+
+// NewRegistry 创建设备注册表，db为nil时仅在内存中记录
+func NewRegistry(db *gorm.DB, logger *utils.Logger) *Registry {
+	return &Registry{
+		devices: make(map[string]*entry),
+		db:      db,
+		logger:  logger,
+	}
+}
+
+var defaultRegistry = NewRegistry(nil, nil)
+
+// Default 返回全局设备注册表
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Init 设置持久化数据库，并将上次运行遗留的在线状态和未结束的会话标记为离线
+func (r *Registry) Init(db *gorm.DB, logger *utils.Logger) error {
+	r.mu.Lock()
+	r.db = db
+	r.logger = logger
+	r.mu.Unlock()
+
+	if db == nil {
+		return nil
+	}
+	now := time.Now()
+	if err := db.Model(&models.Device{}).Where("online = ?", true).
+		Updates(map[string]interface{}{"online": false, "last_disconnected_at": now}).Error; err != nil {
+		return fmt.Errorf("重置设备在线状态失败: %v", err)
+	}
+	if err := db.Model(&models.DeviceSession{}).Where("disconnected_at IS NULL").
+		Updates(map[string]interface{}{"disconnected_at": now, "disconnect_reason": DisconnectRestart}).Error; err != nil {
+		return fmt.Errorf("关闭遗留会话记录失败: %v", err)
+	}
+	return nil
+}
+
+// Connect 记录设备上线，同一会话重复调用时只更新信息，设备已有其他在线会话时旧会话记为被替换
+func (r *Registry) Connect(deviceID string, session Session, info ConnectInfo) {
+	if deviceID == "" || session == nil {
+		return
+	}
+	if info.ConnectedAt.IsZero() {
+		info.ConnectedAt = time.Now()
+	}
+
+	r.mu.Lock()
+	e := r.entryLocked(deviceID)
+	if e.session != nil && e.session.SessionID() == session.SessionID() {
+		if info.ClientID != "" {
+			e.status.ClientID = info.ClientID
+		}
+		if info.DeviceName != "" {
+			e.status.DeviceName = info.DeviceName
+		}
+		r.mu.Unlock()
+		return
+	}
+	previous := e.session
+	e.session = session
+	e.status.SessionID = session.SessionID()
+	e.status.Online = true
+	if info.ClientID != "" {
+		e.status.ClientID = info.ClientID
+	}
+	if info.DeviceName != "" {
+		e.status.DeviceName = info.DeviceName
+	}
+	connectedAt := info.ConnectedAt
+	e.status.ConnectedAt = &connectedAt
+	e.status.DisconnectedAt = nil
+	e.status.AudioParams = AudioParams{}
+	status := e.status
+	r.mu.Unlock()
+
+	if previous != nil {
+		r.closeSession(deviceID, previous.SessionID(), previous.GetTalkRound(), connectedAt, DisconnectReplaced)
+	}
+	r.persistConnect(status)
+}
+
+// Disconnect 记录设备会话结束，会话已被替换时忽略
+func (r *Registry) Disconnect(deviceID string, session Session, reason string) {
+	if deviceID == "" || session == nil {
+		return
+	}
+
+	r.mu.Lock()
+	e, ok := r.devices[deviceID]
+	if !ok || e.session == nil || e.session.SessionID() != session.SessionID() {
+		r.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	lastSeen := session.LastActiveTime()
+	e.session = nil
+	e.status.Online = false
+	e.status.TalkRound = session.GetTalkRound()
+	e.status.ListenMode, e.status.Listening = "", false
+	e.status.DisconnectedAt = &now
+	if !lastSeen.IsZero() {
+		e.status.LastSeen = &lastSeen
+	}
+	r.mu.Unlock()
+
+	r.closeSession(deviceID, session.SessionID(), session.GetTalkRound(), now, reason)
+	r.updateDevice(deviceID, map[string]interface{}{"online": false, "last_disconnected_at": now})
+}
+
+// SetFirmwareVersion 记录设备通过OTA上报的固件版本
+func (r *Registry) SetFirmwareVersion(deviceID, version string) {
+	if deviceID == "" || version == "" {
+		return
+	}
+	r.mu.Lock()
+	e := r.entryLocked(deviceID)
+	e.status.FirmwareVersion = version
+	r.mu.Unlock()
+
+	if r.database() == nil {
+		return
+	}
+	r.upsertDevice(&models.Device{DeviceID: deviceID, FirmwareVersion: version}, "firmware_version", "updated_at")
+}
+
+// SetAudioParams 记录设备在hello中上报的音频参数
+func (r *Registry) SetAudioParams(deviceID string, params AudioParams) {
+	if deviceID == "" {
+		return
+	}
+	r.mu.Lock()
+	if e, ok := r.devices[deviceID]; ok {
+		e.status.AudioParams = params
+	}
+	r.mu.Unlock()
+
+	r.updateDevice(deviceID, map[string]interface{}{
+		"audio_format":   params.Format,
+		"sample_rate":    params.SampleRate,
+		"channels":       params.Channels,
+		"frame_duration": params.FrameDuration,
+	})
+}
+
+// Get 查询设备状态，内存中没有时从数据库加载
+func (r *Registry) Get(deviceID string) (*Status, error) {
+	r.mu.RLock()
+	e, ok := r.devices[deviceID]
+	if ok {
+		status := e.snapshot()
+		r.mu.RUnlock()
+		return &status, nil
+	}
+	r.mu.RUnlock()
+
+	db := r.database()
+	if db == nil {
+		return nil, ErrDeviceNotFound
+	}
+	var device models.Device
+	err := db.Where("device_id = ?", deviceID).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询设备失败: %v", err)
+	}
+	status := statusFromModel(&device)
+	return &status, nil
+}
+
+// List 列出设备，online为nil时返回全部设备，否则按在线状态过滤
+func (r *Registry) List(online *bool) ([]Status, error) {
+	result := make(map[string]Status)
+
+	if db := r.database(); db != nil {
+		var devices []models.Device
+		if err := db.Order("device_id").Find(&devices).Error; err != nil {
+			return nil, fmt.Errorf("查询设备列表失败: %v", err)
+		}
+		for i := range devices {
+			result[devices[i].DeviceID] = statusFromModel(&devices[i])
+		}
+	}
+
+	r.mu.RLock()
+	for deviceID, e := range r.devices {
+		result[deviceID] = e.snapshot()
+	}
+	r.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(result))
+	for _, status := range result {
+		if online != nil && status.Online != *online {
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].DeviceID < statuses[j].DeviceID
+	})
+	return statuses, nil
+}
+
+// History 查询设备的连接历史，按连接时间倒序
+func (r *Registry) History(deviceID string, limit int) ([]models.DeviceSession, error) {
+	db := r.database()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	var sessions []models.DeviceSession
+	err := db.Where("device_id = ?", deviceID).Order("connected_at DESC").Limit(limit).Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询设备连接历史失败: %v", err)
+	}
+	return sessions, nil
+}
+
+// Abort 中止在线设备当前的播报
+func (r *Registry) Abort(deviceID string) error {
+	session, err := r.onlineSession(deviceID)
+	if err != nil {
+		return err
+	}
+	return session.Abort()
+}
+
+// Kick 强制断开在线设备的会话
+func (r *Registry) Kick(deviceID string) error {
+	session, err := r.onlineSession(deviceID)
+	if err != nil {
+		return err
+	}
+	r.Disconnect(deviceID, session, DisconnectKicked)
+	session.Disconnect()
+	return nil
+}
+
+// Session 获取在线设备的会话，设备不存在或离线时返回错误
+func (r *Registry) Session(deviceID string) (Session, error) {
+	return r.onlineSession(deviceID)
+}
+
+// onlineSession 获取设备的在线会话
+func (r *Registry) onlineSession(deviceID string) (Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.devices[deviceID]
+	if !ok {
+		return nil, ErrDeviceNotFound
+	}
+	if e.session == nil {
+		return nil, ErrDeviceOffline
+	}
+	return e.session, nil
+}
+
+// snapshot 生成设备状态快照，在线设备实时读取会话状态
+func (e *entry) snapshot() Status {
+	status := e.status
+	if e.session != nil {
+		status.TalkRound = e.session.GetTalkRound()
+		status.ListenMode, status.Listening = e.session.ListenState()
+		if lastSeen := e.session.LastActiveTime(); !lastSeen.IsZero() {
+			status.LastSeen = &lastSeen
+		}
+	}
+	return status
+}
+
+// entryLocked 获取设备记录，不存在时以数据库中保存的信息创建，调用方需持有写锁
+func (r *Registry) entryLocked(deviceID string) *entry {
+	if e, ok := r.devices[deviceID]; ok {
+		return e
+	}
+	e := &entry{status: Status{DeviceID: deviceID}}
+	if r.db != nil {
+		var device models.Device
+		if err := r.db.Where("device_id = ?", deviceID).Limit(1).Find(&device).Error; err == nil && device.ID != 0 {
+			e.status = statusFromModel(&device)
+		}
+	}
+	r.devices[deviceID] = e
+	return e
+}
+
+// statusFromModel 将数据库记录转换为设备状态
+func statusFromModel(device *models.Device) Status {
+	return Status{
+		DeviceID:        device.DeviceID,
+		SessionID:       device.LastSessionID,
+		ClientID:        device.ClientID,
+		DeviceName:      device.DeviceName,
+		FirmwareVersion: device.FirmwareVersion,
+		AudioParams: AudioParams{
+			Format:        device.AudioFormat,
+			SampleRate:    device.SampleRate,
+			Channels:      device.Channels,
+			FrameDuration: device.FrameDuration,
+		},
+		ConnectedAt:    device.LastConnectedAt,
+		DisconnectedAt: device.LastDisconnectedAt,
+		LastSeen:       device.LastDisconnectedAt,
+	}
+}
+
+func (r *Registry) database() *gorm.DB {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.db
+}
+
+// persistConnect 保存设备上线信息并新增会话记录
+func (r *Registry) persistConnect(status Status) {
+	db := r.database()
+	if db == nil {
+		return
+	}
+	device := &models.Device{
+		DeviceID:        status.DeviceID,
+		ClientID:        status.ClientID,
+		DeviceName:      status.DeviceName,
+		FirmwareVersion: status.FirmwareVersion,
+		Online:          true,
+		LastSessionID:   status.SessionID,
+		LastConnectedAt: status.ConnectedAt,
+	}
+	columns := []string{"client_id", "online", "last_session_id", "last_connected_at", "updated_at"}
+	if status.DeviceName != "" {
+		columns = append(columns, "device_name")
+	}
+	if status.FirmwareVersion != "" {
+		columns = append(columns, "firmware_version")
+	}
+	r.upsertDevice(device, columns...)
+
+	session := &models.DeviceSession{
+		DeviceID:        status.DeviceID,
+		SessionID:       status.SessionID,
+		ClientID:        status.ClientID,
+		FirmwareVersion: status.FirmwareVersion,
+		ConnectedAt:     *status.ConnectedAt,
+	}
+	if err := db.Create(session).Error; err != nil {
+		r.logError("保存设备 %s 会话记录失败: %v", status.DeviceID, err)
+	}
+}
+
+// closeSession 结束会话记录
+func (r *Registry) closeSession(deviceID, sessionID string, talkRounds int, at time.Time, reason string) {
+	db := r.database()
+	if db == nil {
+		return
+	}
+	err := db.Model(&models.DeviceSession{}).
+		Where("device_id = ? AND session_id = ? AND disconnected_at IS NULL", deviceID, sessionID).
+		Updates(map[string]interface{}{"disconnected_at": at, "talk_rounds": talkRounds, "disconnect_reason": reason}).Error
+	if err != nil {
+		r.logError("更新设备 %s 会话记录失败: %v", deviceID, err)
+	}
+}
+
+// upsertDevice 新增设备记录，已存在时只更新指定列
+func (r *Registry) upsertDevice(device *models.Device, columns ...string) {
+	err := r.database().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(device).Error
+	if err != nil {
+		r.logError("保存设备 %s 信息失败: %v", device.DeviceID, err)
+	}
+}
+
+// updateDevice 更新设备记录的指定字段
+func (r *Registry) updateDevice(deviceID string, values map[string]interface{}) {
+	db := r.database()
+	if db == nil {
+		return
+	}
+	if err := db.Model(&models.Device{}).Where("device_id = ?", deviceID).Updates(values).Error; err != nil {
+		r.logError("更新设备 %s 信息失败: %v", deviceID, err)
+	}
+}
+
+func (r *Registry) logError(format string, args ...interface{}) {
+	r.mu.RLock()
+	logger := r.logger
+	r.mu.RUnlock()
+	if logger != nil {
+		logger.Error(format, args...)
+	}
+}
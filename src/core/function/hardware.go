@@ -1,6 +1,7 @@
 package function
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -31,8 +32,8 @@ func SetHardwareController(controller HardwareController) {
 	GlobalHardwareController = controller
 }
 
-// RegisterHardwareFunctions 注册硬件控制函数
-func (fr *FunctionRegistry) RegisterHardwareFunctions() error {
+// RegisterHardwareFunctions 注册硬件控制函数，函数调用由 controller 执行，controller 为nil时使用全局硬件控制器
+func (fr *FunctionRegistry) RegisterHardwareFunctions(controller HardwareController) error {
 	// 电机控制函数
 	motorSpeedFunc := openai.Tool{
 		Type: openai.ToolTypeFunction,
@@ -181,7 +182,11 @@ func (fr *FunctionRegistry) RegisterHardwareFunctions() error {
 	}
 
 	for _, function := range functions {
-		if err := fr.RegisterFunction(function.Function.Name, function); err != nil {
+		name := function.Function.Name
+		handler := func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			return HandleHardwareFunction(controller, name, arguments)
+		}
+		if err := fr.RegisterHandler(name, function, handler); err != nil {
 			return fmt.Errorf("注册硬件函数失败: %v", err)
 		}
 	}
@@ -190,8 +195,11 @@ func (fr *FunctionRegistry) RegisterHardwareFunctions() error {
 }
 
 // HandleHardwareFunction 处理硬件控制函数调用
-func HandleHardwareFunction(functionName string, arguments json.RawMessage) (interface{}, error) {
-	if GlobalHardwareController == nil {
+func HandleHardwareFunction(controller HardwareController, functionName string, arguments json.RawMessage) (interface{}, error) {
+	if controller == nil {
+		controller = GlobalHardwareController
+	}
+	if controller == nil {
 		return nil, fmt.Errorf("硬件控制器未初始化")
 	}
 
@@ -204,7 +212,7 @@ func HandleHardwareFunction(functionName string, arguments json.RawMessage) (int
 		if err := json.Unmarshal(arguments, &args); err != nil {
 			return nil, fmt.Errorf("解析参数失败: %v", err)
 		}
-		err := GlobalHardwareController.SetMotorSpeed(args.MotorID, args.Speed)
+		err := controller.SetMotorSpeed(args.MotorID, args.Speed)
 		if err != nil {
 			return nil, err
 		}
@@ -221,7 +229,7 @@ func HandleHardwareFunction(functionName string, arguments json.RawMessage) (int
 		if err := json.Unmarshal(arguments, &args); err != nil {
 			return nil, fmt.Errorf("解析参数失败: %v", err)
 		}
-		err := GlobalHardwareController.SetMotorDirection(args.MotorID, args.Direction)
+		err := controller.SetMotorDirection(args.MotorID, args.Direction)
 		if err != nil {
 			return nil, err
 		}
@@ -238,7 +246,7 @@ func HandleHardwareFunction(functionName string, arguments json.RawMessage) (int
 		if err := json.Unmarshal(arguments, &args); err != nil {
 			return nil, fmt.Errorf("解析参数失败: %v", err)
 		}
-		err := GlobalHardwareController.SetLED(args.LEDID, args.State)
+		err := controller.SetLED(args.LEDID, args.State)
 		if err != nil {
 			return nil, err
 		}
@@ -257,7 +265,7 @@ func HandleHardwareFunction(functionName string, arguments json.RawMessage) (int
 		if err := json.Unmarshal(arguments, &args); err != nil {
 			return nil, fmt.Errorf("解析参数失败: %v", err)
 		}
-		err := GlobalHardwareController.SetLEDColor(args.LEDID, args.R, args.G, args.B)
+		err := controller.SetLEDColor(args.LEDID, args.R, args.G, args.B)
 		if err != nil {
 			return nil, err
 		}
@@ -267,7 +275,7 @@ func HandleHardwareFunction(functionName string, arguments json.RawMessage) (int
 		}, nil
 
 	case "get_temperature":
-		temp, err := GlobalHardwareController.GetTemperature()
+		temp, err := controller.GetTemperature()
 		if err != nil {
 			return nil, err
 		}
@@ -277,7 +285,7 @@ func HandleHardwareFunction(functionName string, arguments json.RawMessage) (int
 		}, nil
 
 	case "get_humidity":
-		humidity, err := GlobalHardwareController.GetHumidity()
+		humidity, err := controller.GetHumidity()
 		if err != nil {
 			return nil, err
 		}
@@ -287,7 +295,7 @@ func HandleHardwareFunction(functionName string, arguments json.RawMessage) (int
 		}, nil
 
 	case "get_distance":
-		distance, err := GlobalHardwareController.GetDistance()
+		distance, err := controller.GetDistance()
 		if err != nil {
 			return nil, err
 		}
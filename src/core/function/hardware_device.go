@@ -0,0 +1,198 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+* 通过设备 WebSocket 连接执行硬件控制，使用 iot 消息下发指令：
+* 服务端 -> 设备: {"type":"iot","session_id":"...","request_id":"hw-1","commands":[{"name":"Hardware","method":"SetMotorSpeed","parameters":{...}}]}
+* 设备 -> 服务端: {"type":"iot","request_id":"hw-1","success":true,"result":{"value":25.5}}，失败时 success 为false并携带 error
+* 请求和响应按 request_id 关联，超时未响应的请求返回错误。
+ */
+
+// DefaultHardwareTimeout 等待设备响应的默认超时时间
+const DefaultHardwareTimeout = 10 * time.Second
+
+// hardwareThingName 硬件控制指令使用的 IoT 设备名称
+const hardwareThingName = "Hardware"
+
+// MessageWriter 向设备发送消息的连接
+type MessageWriter interface {
+	WriteMessage(messageType int, data []byte) error
+}
+
+// hardwareReply 设备对硬件控制指令的响应
+type hardwareReply struct {
+	result map[string]interface{}
+	err    error
+}
+
+// DeviceHardwareController 将硬件控制函数转换为下发到设备的 iot 指令
+type DeviceHardwareController struct {
+	conn      MessageWriter
+	sessionID string
+	timeout   time.Duration
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan hardwareReply
+	closed  bool
+}
+
+// NewDeviceHardwareController 创建设备硬件控制器，timeout 不大于0时使用默认超时时间
+func NewDeviceHardwareController(conn MessageWriter, sessionID string, timeout time.Duration) *DeviceHardwareController {
+	if timeout <= 0 {
+		timeout = DefaultHardwareTimeout
+	}
+	return &DeviceHardwareController{
+		conn:      conn,
+		sessionID: sessionID,
+		timeout:   timeout,
+		pending:   make(map[string]chan hardwareReply),
+	}
+}
+
+// SetMotorSpeed 设置电机转速
+func (c *DeviceHardwareController) SetMotorSpeed(motorID int, speed int) error {
+	_, err := c.call("SetMotorSpeed", map[string]interface{}{"motor_id": motorID, "speed": speed})
+	return err
+}
+
+// SetMotorDirection 设置电机方向
+func (c *DeviceHardwareController) SetMotorDirection(motorID int, direction string) error {
+	_, err := c.call("SetMotorDirection", map[string]interface{}{"motor_id": motorID, "direction": direction})
+	return err
+}
+
+// SetLED 设置LED开关
+func (c *DeviceHardwareController) SetLED(ledID int, state bool) error {
+	_, err := c.call("SetLED", map[string]interface{}{"led_id": ledID, "state": state})
+	return err
+}
+
+// SetLEDColor 设置LED颜色
+func (c *DeviceHardwareController) SetLEDColor(ledID int, r, g, b int) error {
+	_, err := c.call("SetLEDColor", map[string]interface{}{"led_id": ledID, "r": r, "g": g, "b": b})
+	return err
+}
+
+// GetTemperature 读取温度
+func (c *DeviceHardwareController) GetTemperature() (float64, error) {
+	return c.readSensor("GetTemperature")
+}
+
+// GetHumidity 读取湿度
+func (c *DeviceHardwareController) GetHumidity() (float64, error) {
+	return c.readSensor("GetHumidity")
+}
+
+// GetDistance 读取距离
+func (c *DeviceHardwareController) GetDistance() (float64, error) {
+	return c.readSensor("GetDistance")
+}
+
+func (c *DeviceHardwareController) readSensor(method string) (float64, error) {
+	result, err := c.call(method, map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	value, ok := result["value"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("设备返回的%s结果缺少数值: %v", method, result)
+	}
+	return value, nil
+}
+
+// call 下发指令并等待设备响应
+func (c *DeviceHardwareController) call(method string, parameters map[string]interface{}) (map[string]interface{}, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("设备连接已关闭")
+	}
+	c.nextID++
+	requestID := fmt.Sprintf("hw-%d", c.nextID)
+	replyCh := make(chan hardwareReply, 1)
+	c.pending[requestID] = replyCh
+	c.mu.Unlock()
+	defer c.removePending(requestID)
+
+	message := map[string]interface{}{
+		"type":       "iot",
+		"session_id": c.sessionID,
+		"request_id": requestID,
+		"commands": []map[string]interface{}{{
+			"name":       hardwareThingName,
+			"method":     method,
+			"parameters": parameters,
+		}},
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("序列化硬件控制指令失败: %v", err)
+	}
+	if err := c.conn.WriteMessage(1, data); err != nil {
+		return nil, fmt.Errorf("发送硬件控制指令失败: %v", err)
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-replyCh:
+		return reply.result, reply.err
+	case <-timer.C:
+		return nil, fmt.Errorf("等待设备执行 %s 超时(%v)", method, c.timeout)
+	}
+}
+
+func (c *DeviceHardwareController) removePending(requestID string) {
+	c.mu.Lock()
+	delete(c.pending, requestID)
+	c.mu.Unlock()
+}
+
+// HandleResponse 处理设备返回的 iot 消息，消息是硬件控制指令的响应时返回true
+func (c *DeviceHardwareController) HandleResponse(msgMap map[string]interface{}) bool {
+	requestID, ok := msgMap["request_id"].(string)
+	if !ok || requestID == "" {
+		return false
+	}
+	c.mu.Lock()
+	replyCh, exists := c.pending[requestID]
+	delete(c.pending, requestID)
+	c.mu.Unlock()
+	if !exists {
+		// 请求已超时，丢弃迟到的响应
+		return true
+	}
+
+	reply := hardwareReply{}
+	if success, _ := msgMap["success"].(bool); !success {
+		errMsg, _ := msgMap["error"].(string)
+		if errMsg == "" {
+			errMsg = "未知错误"
+		}
+		reply.err = fmt.Errorf("设备执行失败: %s", errMsg)
+	} else if result, ok := msgMap["result"].(map[string]interface{}); ok {
+		reply.result = result
+	} else {
+		reply.result = map[string]interface{}{}
+	}
+	replyCh <- reply
+	return true
+}
+
+// Close 关闭控制器，等待中的请求立即返回错误
+func (c *DeviceHardwareController) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for requestID, replyCh := range c.pending {
+		replyCh <- hardwareReply{err: fmt.Errorf("设备连接已关闭")}
+		delete(c.pending, requestID)
+	}
+}
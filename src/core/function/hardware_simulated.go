@@ -0,0 +1,134 @@
+package function
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SimulatedHardwareController 模拟硬件控制器，记录状态并校验参数范围，用于测试和没有硬件的设备
+type SimulatedHardwareController struct {
+	mu          sync.Mutex
+	motorSpeeds map[int]int
+	motorDirs   map[int]string
+	leds        map[int]bool
+	ledColors   map[int][3]int
+
+	Temperature float64
+	Humidity    float64
+	Distance    float64
+}
+
+// NewSimulatedHardwareController 创建模拟硬件控制器
+func NewSimulatedHardwareController() *SimulatedHardwareController {
+	return &SimulatedHardwareController{
+		motorSpeeds: make(map[int]int),
+		motorDirs:   make(map[int]string),
+		leds:        make(map[int]bool),
+		ledColors:   make(map[int][3]int),
+		Temperature: 25.0,
+		Humidity:    50.0,
+		Distance:    100.0,
+	}
+}
+
+// SetMotorSpeed 设置电机转速
+func (c *SimulatedHardwareController) SetMotorSpeed(motorID int, speed int) error {
+	if err := checkRange("电机ID", motorID, 1, 4); err != nil {
+		return err
+	}
+	if err := checkRange("转速", speed, -100, 100); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.motorSpeeds[motorID] = speed
+	return nil
+}
+
+// SetMotorDirection 设置电机方向
+func (c *SimulatedHardwareController) SetMotorDirection(motorID int, direction string) error {
+	if err := checkRange("电机ID", motorID, 1, 4); err != nil {
+		return err
+	}
+	switch direction {
+	case "forward", "backward", "stop":
+	default:
+		return fmt.Errorf("无效的电机方向: %s", direction)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.motorDirs[motorID] = direction
+	if direction == "stop" {
+		c.motorSpeeds[motorID] = 0
+	}
+	return nil
+}
+
+// SetLED 设置LED开关
+func (c *SimulatedHardwareController) SetLED(ledID int, state bool) error {
+	if err := checkRange("LED ID", ledID, 1, 8); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leds[ledID] = state
+	return nil
+}
+
+// SetLEDColor 设置LED颜色
+func (c *SimulatedHardwareController) SetLEDColor(ledID int, r, g, b int) error {
+	if err := checkRange("LED ID", ledID, 1, 8); err != nil {
+		return err
+	}
+	for _, v := range []int{r, g, b} {
+		if err := checkRange("颜色分量", v, 0, 255); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ledColors[ledID] = [3]int{r, g, b}
+	return nil
+}
+
+// GetTemperature 读取温度
+func (c *SimulatedHardwareController) GetTemperature() (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Temperature, nil
+}
+
+// GetHumidity 读取湿度
+func (c *SimulatedHardwareController) GetHumidity() (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Humidity, nil
+}
+
+// GetDistance 读取距离
+func (c *SimulatedHardwareController) GetDistance() (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Distance, nil
+}
+
+// MotorState 返回电机的转速和方向
+func (c *SimulatedHardwareController) MotorState(motorID int) (int, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.motorSpeeds[motorID], c.motorDirs[motorID]
+}
+
+// LEDState 返回LED的开关状态和颜色
+func (c *SimulatedHardwareController) LEDState(ledID int) (bool, [3]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leds[ledID], c.ledColors[ledID]
+}
+
+func checkRange(name string, value, min, max int) error {
+	if value < min || value > max {
+		return fmt.Errorf("%s超出范围(%d-%d): %d", name, min, max, value)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHardwareFunctionsWithSimulatedController(t *testing.T) {
+	controller := NewSimulatedHardwareController()
+	fr := NewFunctionRegistry()
+	if err := fr.RegisterHardwareFunctions(controller); err != nil {
+		t.Fatalf("注册硬件函数失败: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		function  string
+		arguments string
+		wantErr   bool
+	}{
+		{name: "设置电机转速", function: "set_motor_speed", arguments: `{"motor_id":1,"speed":60}`},
+		{name: "设置电机方向", function: "set_motor_direction", arguments: `{"motor_id":2,"direction":"backward"}`},
+		{name: "打开LED", function: "set_led", arguments: `{"led_id":3,"state":true}`},
+		{name: "设置LED颜色", function: "set_led_color", arguments: `{"led_id":3,"r":255,"g":128,"b":0}`},
+		{name: "读取温度", function: "get_temperature", arguments: `{}`},
+		{name: "电机ID越界", function: "set_motor_speed", arguments: `{"motor_id":5,"speed":10}`, wantErr: true},
+		{name: "转速越界", function: "set_motor_speed", arguments: `{"motor_id":1,"speed":150}`, wantErr: true},
+		{name: "无效方向", function: "set_motor_direction", arguments: `{"motor_id":1,"direction":"left"}`, wantErr: true},
+		{name: "颜色越界", function: "set_led_color", arguments: `{"led_id":1,"r":300,"g":0,"b":0}`, wantErr: true},
+		{name: "参数格式错误", function: "set_led", arguments: `{"led_id":"x"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fr.CallFunction(context.Background(), tt.function, json.RawMessage(tt.arguments))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CallFunction(%s) error = %v, wantErr %v", tt.function, err, tt.wantErr)
+			}
+		})
+	}
+
+	if speed, dir := controller.MotorState(1); speed != 60 || dir != "" {
+		t.Errorf("电机1状态 = (%d, %q)，期望 (60, \"\")", speed, dir)
+	}
+	if _, dir := controller.MotorState(2); dir != "backward" {
+		t.Errorf("电机2方向 = %q，期望 backward", dir)
+	}
+	if on, color := controller.LEDState(3); !on || color != [3]int{255, 128, 0} {
+		t.Errorf("LED3状态 = (%v, %v)，期望 (true, [255 128 0])", on, color)
+	}
+
+	result, err := fr.CallFunction(context.Background(), "get_distance", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("读取距离失败: %v", err)
+	}
+	if distance := result.(map[string]interface{})["distance"]; distance != 100.0 {
+		t.Errorf("距离 = %v，期望 100", distance)
+	}
+}
+
+// fakeDevice 模拟设备端，收到 iot 指令后按 respond 生成响应
+type fakeDevice struct {
+	mu       sync.Mutex
+	messages []map[string]interface{}
+	respond  func(msg map[string]interface{}) map[string]interface{}
+	deliver  func(reply map[string]interface{})
+}
+
+func (d *fakeDevice) WriteMessage(messageType int, data []byte) error {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.messages = append(d.messages, msg)
+	d.mu.Unlock()
+	if d.respond != nil {
+		if reply := d.respond(msg); reply != nil {
+			// 设备响应经由连接的读循环异步到达
+			go d.deliver(reply)
+		}
+	}
+	return nil
+}
+
+func TestDeviceHardwareController(t *testing.T) {
+	device := &fakeDevice{}
+	controller := NewDeviceHardwareController(device, "session-1", time.Second)
+	device.deliver = func(reply map[string]interface{}) {
+		if !controller.HandleResponse(reply) {
+			t.Errorf("响应未被处理: %v", reply)
+		}
+	}
+	device.respond = func(msg map[string]interface{}) map[string]interface{} {
+		command := msg["commands"].([]interface{})[0].(map[string]interface{})
+		reply := map[string]interface{}{"type": "iot", "request_id": msg["request_id"], "success": true}
+		switch command["method"] {
+		case "GetTemperature":
+			reply["result"] = map[string]interface{}{"value": 21.5}
+		case "SetLED":
+			reply["success"] = false
+			reply["error"] = "LED故障"
+		}
+		return reply
+	}
+
+	if err := controller.SetMotorSpeed(1, 50); err != nil {
+		t.Fatalf("SetMotorSpeed 失败: %v", err)
+	}
+	device.mu.Lock()
+	msg := device.messages[0]
+	device.mu.Unlock()
+	if msg["type"] != "iot" || msg["session_id"] != "session-1" || msg["request_id"] == "" {
+		t.Errorf("指令格式错误: %v", msg)
+	}
+	command := msg["commands"].([]interface{})[0].(map[string]interface{})
+	params := command["parameters"].(map[string]interface{})
+	if command["name"] != "Hardware" || command["method"] != "SetMotorSpeed" || params["speed"] != 50.0 {
+		t.Errorf("指令内容错误: %v", command)
+	}
+
+	temp, err := controller.GetTemperature()
+	if err != nil || temp != 21.5 {
+		t.Errorf("GetTemperature = (%v, %v)，期望 (21.5, nil)", temp, err)
+	}
+
+	if err := controller.SetLED(1, true); err == nil || !strings.Contains(err.Error(), "LED故障") {
+		t.Errorf("SetLED 期望返回设备错误，实际: %v", err)
+	}
+
+	// 并发调用按 request_id 各自收到响应
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := controller.GetTemperature(); err != nil {
+				t.Errorf("并发读取温度失败: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDeviceHardwareControllerTimeoutAndClose(t *testing.T) {
+	device := &fakeDevice{}
+	controller := NewDeviceHardwareController(device, "session-1", 50*time.Millisecond)
+
+	start := time.Now()
+	if err := controller.SetMotorDirection(1, "forward"); err == nil || !strings.Contains(err.Error(), "超时") {
+		t.Fatalf("期望超时错误，实际: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("超时返回耗时过长: %v", elapsed)
+	}
+
+	// 超时后迟到的响应被丢弃
+	device.mu.Lock()
+	late := map[string]interface{}{"type": "iot", "request_id": device.messages[0]["request_id"], "success": true}
+	device.mu.Unlock()
+	if !controller.HandleResponse(late) {
+		t.Errorf("迟到的响应应被识别为硬件响应")
+	}
+
+	// 设备描述符等其他 iot 消息不由控制器处理
+	if controller.HandleResponse(map[string]interface{}{"type": "iot", "descriptors": []interface{}{}}) {
+		t.Errorf("非响应消息不应被控制器处理")
+	}
+
+	// 关闭后等待中的请求立即返回
+	controller = NewDeviceHardwareController(device, "session-1", 10*time.Second)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- controller.SetLED(1, false)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	controller.Close()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Errorf("关闭后期望返回错误")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("关闭后请求未返回")
+	}
+	if err := controller.SetLED(1, true); err == nil {
+		t.Errorf("关闭后的调用期望返回错误")
+	}
+}
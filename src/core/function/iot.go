@@ -0,0 +1,344 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+* 小智固件 IoT 协议：设备上报能力描述符和状态，服务端下发指令。
+* 设备 -> 服务端: {"type":"iot","descriptors":[{"name":"Speaker","description":"扬声器","properties":{...},"methods":{...}}]}
+* 设备 -> 服务端: {"type":"iot","states":[{"name":"Speaker","state":{"volume":50}}]}
+* 服务端 -> 设备: {"type":"iot","commands":[{"name":"Speaker","method":"SetVolume","parameters":{"volume":60}}]}
+* 每个方法注册为 iot_<设备>_<方法> 工具，每个属性注册为 iot_get_<设备>_<属性> 查询工具。
+ */
+
+// IoTProperty IoT设备属性或方法参数的描述
+type IoTProperty struct {
+	Description string `json:"description"`
+	Type        string `json:"type"` // number、boolean、string
+}
+
+// IoTMethod IoT设备方法的描述
+type IoTMethod struct {
+	Description string                 `json:"description"`
+	Parameters  map[string]IoTProperty `json:"parameters"`
+}
+
+// IoTThing IoT设备能力描述符
+type IoTThing struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Properties  map[string]IoTProperty `json:"properties"`
+	Methods     map[string]IoTMethod   `json:"methods"`
+}
+
+// IoTDevice 连接的IoT设备，将描述符注册为工具并缓存设备状态
+type IoTDevice struct {
+	conn      MessageWriter
+	sessionID string
+	registry  *FunctionRegistry
+
+	mu     sync.RWMutex
+	things map[string]IoTThing
+	tools  map[string][]string // 设备名 -> 已注册的工具名
+	states map[string]map[string]interface{}
+}
+
+// NewIoTDevice 创建连接的IoT设备，工具注册到 registry，指令通过 conn 下发
+func NewIoTDevice(conn MessageWriter, sessionID string, registry *FunctionRegistry) *IoTDevice {
+	return &IoTDevice{
+		conn:      conn,
+		sessionID: sessionID,
+		registry:  registry,
+		things:    make(map[string]IoTThing),
+		tools:     make(map[string][]string),
+		states:    make(map[string]map[string]interface{}),
+	}
+}
+
+// UpdateDescriptors 处理设备上报的描述符，同名设备的工具会被重新注册，返回注册的工具数量
+func (d *IoTDevice) UpdateDescriptors(descriptors []interface{}) (int, error) {
+	registered := 0
+	var errs []string
+	for _, raw := range descriptors {
+		thing, err := parseIoTThing(raw)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		d.mu.Lock()
+		for _, name := range d.tools[thing.Name] {
+			d.registry.UnregisterFunction(name)
+		}
+		d.things[thing.Name] = thing
+		d.tools[thing.Name] = nil
+		d.mu.Unlock()
+
+		names, err := d.registerThing(thing)
+		registered += len(names)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		d.mu.Lock()
+		d.tools[thing.Name] = names
+		d.mu.Unlock()
+	}
+	if len(errs) > 0 {
+		return registered, fmt.Errorf("处理IoT描述符失败: %s", strings.Join(errs, "; "))
+	}
+	return registered, nil
+}
+
+func parseIoTThing(raw interface{}) (IoTThing, error) {
+	var thing IoTThing
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return thing, fmt.Errorf("序列化描述符失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &thing); err != nil {
+		return thing, fmt.Errorf("解析描述符失败: %v", err)
+	}
+	if thing.Name == "" {
+		return thing, fmt.Errorf("描述符缺少设备名称: %s", string(data))
+	}
+	return thing, nil
+}
+
+// registerThing 将设备的方法和属性注册为工具，返回成功注册的工具名
+func (d *IoTDevice) registerThing(thing IoTThing) ([]string, error) {
+	var names []string
+	var errs []string
+
+	for methodName, method := range thing.Methods {
+		name := iotToolName("iot", thing.Name, methodName)
+		tool := openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        name,
+				Description: fmt.Sprintf("%s - %s", iotThingLabel(thing), method.Description),
+				Parameters:  iotParametersSchema(method.Parameters),
+			},
+		}
+		if err := d.registry.RegisterHandler(name, tool, d.methodHandler(thing.Name, methodName, method)); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		names = append(names, name)
+	}
+
+	for propName, prop := range thing.Properties {
+		name := iotToolName("iot_get", thing.Name, propName)
+		tool := openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        name,
+				Description: fmt.Sprintf("查询%s的%s", iotThingLabel(thing), prop.Description),
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		}
+		if err := d.registry.RegisterHandler(name, tool, d.propertyHandler(thing.Name, propName)); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		names = append(names, name)
+	}
+
+	if len(errs) > 0 {
+		return names, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return names, nil
+}
+
+// methodHandler 调用设备方法：校验参数后下发 iot 指令
+func (d *IoTDevice) methodHandler(thingName, methodName string, method IoTMethod) FunctionHandler {
+	return func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+		parameters := make(map[string]interface{})
+		if len(arguments) > 0 {
+			if err := json.Unmarshal(arguments, &parameters); err != nil {
+				return nil, fmt.Errorf("解析参数失败: %v", err)
+			}
+		}
+		for paramName := range method.Parameters {
+			if _, ok := parameters[paramName]; !ok {
+				return nil, fmt.Errorf("缺少参数: %s", paramName)
+			}
+		}
+		if err := d.SendCommand(thingName, methodName, parameters); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"status":  "success",
+			"message": fmt.Sprintf("已向设备 %s 发送 %s 指令", thingName, methodName),
+		}, nil
+	}
+}
+
+// propertyHandler 查询设备属性，返回最近一次上报的状态
+func (d *IoTDevice) propertyHandler(thingName, propName string) FunctionHandler {
+	return func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+		value, ok := d.State(thingName, propName)
+		if !ok {
+			return nil, fmt.Errorf("设备 %s 尚未上报 %s 状态", thingName, propName)
+		}
+		return map[string]interface{}{
+			"device":   thingName,
+			"property": propName,
+			"value":    value,
+		}, nil
+	}
+}
+
+// SendCommand 向设备下发 iot 指令
+func (d *IoTDevice) SendCommand(thingName, methodName string, parameters map[string]interface{}) error {
+	message := map[string]interface{}{
+		"type":       "iot",
+		"session_id": d.sessionID,
+		"commands": []map[string]interface{}{{
+			"name":       thingName,
+			"method":     methodName,
+			"parameters": parameters,
+		}},
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化IoT指令失败: %v", err)
+	}
+	if err := d.conn.WriteMessage(1, data); err != nil {
+		return fmt.Errorf("发送IoT指令失败: %v", err)
+	}
+	return nil
+}
+
+// UpdateStates 缓存设备上报的状态，同一设备的状态按属性合并
+func (d *IoTDevice) UpdateStates(states []interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, raw := range states {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := item["name"].(string)
+		state, ok := item["state"].(map[string]interface{})
+		if name == "" || !ok {
+			continue
+		}
+		if d.states[name] == nil {
+			d.states[name] = make(map[string]interface{})
+		}
+		for key, value := range state {
+			d.states[name][key] = value
+		}
+	}
+}
+
+// State 返回设备属性的最近状态
+func (d *IoTDevice) State(thingName, propName string) (interface{}, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	value, ok := d.states[thingName][propName]
+	return value, ok
+}
+
+// StatesPrompt 生成描述设备当前状态的提示词，没有状态时返回空
+func (d *IoTDevice) StatesPrompt() string {
+	if d == nil {
+		return ""
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if len(d.states) == 0 {
+		return ""
+	}
+
+	thingNames := make([]string, 0, len(d.states))
+	for name := range d.states {
+		thingNames = append(thingNames, name)
+	}
+	sort.Strings(thingNames)
+
+	var sb strings.Builder
+	sb.WriteString("设备当前状态：")
+	for _, thingName := range thingNames {
+		state := d.states[thingName]
+		thing := d.things[thingName]
+		label := thingName
+		if thing.Name != "" {
+			label = iotThingLabel(thing)
+		}
+
+		propNames := make([]string, 0, len(state))
+		for name := range state {
+			propNames = append(propNames, name)
+		}
+		sort.Strings(propNames)
+
+		items := make([]string, 0, len(propNames))
+		for _, propName := range propNames {
+			desc := propName
+			if prop, ok := thing.Properties[propName]; ok && prop.Description != "" {
+				desc = prop.Description
+			}
+			items = append(items, fmt.Sprintf("%s=%v", desc, state[propName]))
+		}
+		sb.WriteString(fmt.Sprintf("\n- %s: %s", label, strings.Join(items, "，")))
+	}
+	return sb.String()
+}
+
+func iotThingLabel(thing IoTThing) string {
+	if thing.Description == "" {
+		return thing.Name
+	}
+	return fmt.Sprintf("%s(%s)", thing.Description, thing.Name)
+}
+
+// iotToolName 生成符合 ^[a-zA-Z0-9_-]+$ 的工具名
+func iotToolName(prefix, thingName, memberName string) string {
+	name := strings.ToLower(fmt.Sprintf("%s_%s_%s", prefix, thingName, memberName))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// iotParametersSchema 将方法参数描述转换为 JSON Schema，所有参数均为必填
+func iotParametersSchema(parameters map[string]IoTProperty) map[string]interface{} {
+	properties := make(map[string]interface{}, len(parameters))
+	required := make([]string, 0, len(parameters))
+	for name, param := range parameters {
+		properties[name] = map[string]interface{}{
+			"type":        iotSchemaType(param.Type),
+			"description": param.Description,
+		}
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+func iotSchemaType(t string) string {
+	switch t {
+	case "number", "integer", "boolean", "string":
+		return t
+	default:
+		return "string"
+	}
+}
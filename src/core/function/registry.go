@@ -1,22 +1,57 @@
 package function
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/sashabaranov/go-openai"
 )
 
+// FunctionHandler 本地函数的执行逻辑，arguments 为LLM生成的JSON参数
+type FunctionHandler func(ctx context.Context, arguments json.RawMessage) (interface{}, error)
+
+// FunctionRegistry 连接的函数注册表，设备消息（MCP工具列表、IoT描述符）和LLM调用可能并发访问
 type FunctionRegistry struct {
+	mu        sync.RWMutex
 	functions map[string]openai.Tool
+	handlers  map[string]FunctionHandler
 }
 
 func NewFunctionRegistry() *FunctionRegistry {
 	return &FunctionRegistry{
 		functions: make(map[string]openai.Tool),
+		handlers:  make(map[string]FunctionHandler),
+	}
+}
+
+// RegisterHandler 注册带执行逻辑的本地函数
+func (fr *FunctionRegistry) RegisterHandler(name string, function openai.Tool, handler FunctionHandler) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if _, exists := fr.functions[name]; exists {
+		return fmt.Errorf("function already registered: %s", name)
+	}
+	fr.functions[name] = function
+	fr.handlers[name] = handler
+	return nil
+}
+
+// CallFunction 执行本地函数，只注册了定义没有执行逻辑的函数（如MCP工具）返回错误
+func (fr *FunctionRegistry) CallFunction(ctx context.Context, name string, arguments json.RawMessage) (interface{}, error) {
+	fr.mu.RLock()
+	handler, exists := fr.handlers[name]
+	fr.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("function has no handler: %s", name)
 	}
+	return handler(ctx, arguments)
 }
 
 func (fr *FunctionRegistry) RegisterFunction(name string, function openai.Tool) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
 	if _, exists := fr.functions[name]; exists {
 		return fmt.Errorf("function already registered: %s", name)
 	}
@@ -25,6 +60,8 @@ func (fr *FunctionRegistry) RegisterFunction(name string, function openai.Tool)
 }
 
 func (fr *FunctionRegistry) GetFunction(name string) (openai.Tool, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
 	if function, exists := fr.functions[name]; exists {
 		return function, nil
 	}
@@ -32,6 +69,8 @@ func (fr *FunctionRegistry) GetFunction(name string) (openai.Tool, error) {
 }
 
 func (fr *FunctionRegistry) GetAllFunctions() []openai.Tool {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
 	functions := make([]openai.Tool, 0, len(fr.functions))
 	for _, function := range fr.functions {
 		functions = append(functions, function)
@@ -40,17 +79,23 @@ func (fr *FunctionRegistry) GetAllFunctions() []openai.Tool {
 }
 
 func (fr *FunctionRegistry) UnregisterAllFunctions() error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
 	// Unregister all functions
 	for name := range fr.functions {
 		delete(fr.functions, name)
+		delete(fr.handlers, name)
 	}
 	return nil
 }
 
 func (fr *FunctionRegistry) UnregisterFunction(name string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
 	// Unregister a specific function
 	if _, exists := fr.functions[name]; exists {
 		delete(fr.functions, name)
+		delete(fr.handlers, name)
 	} else {
 		return fmt.Errorf("function not found: %s", name)
 	}
@@ -58,6 +103,8 @@ func (fr *FunctionRegistry) UnregisterFunction(name string) error {
 }
 
 func (fr *FunctionRegistry) FunctionExists(name string) bool {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
 	_, exists := fr.functions[name]
 	return exists
 }
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/metrics"
 	"xiaozhi-server-go/src/core/utils"
 
 	"github.com/google/uuid"
@@ -71,10 +72,12 @@ func (p *ImageProcessor) ProcessImage(ctx context.Context, imageData ImageData)
 	if imageData.URL != "" {
 		// 处理URL类型图片
 		atomic.AddInt64(&p.metrics.URLDownloads, 1)
+		metrics.ImageProcessed.Inc("url")
 
 		base64Data, err := p.processURLImage(ctx, imageData.URL, imageData.Format)
 		if err != nil {
 			atomic.AddInt64(&p.metrics.FailedValidations, 1)
+			metrics.ImageValidationFailures.Inc()
 			return "", fmt.Errorf("URL图片处理失败: %v", err)
 		}
 
@@ -91,6 +94,7 @@ func (p *ImageProcessor) ProcessImage(ctx context.Context, imageData ImageData)
 	} else if imageData.Data != "" {
 		// 直接处理base64数据
 		atomic.AddInt64(&p.metrics.Base64Direct, 1)
+		metrics.ImageProcessed.Inc("base64")
 		finalImageData = imageData
 
 		p.logger.Debug("Base64图片处理开始 %v", map[string]interface{}{
@@ -105,8 +109,10 @@ func (p *ImageProcessor) ProcessImage(ctx context.Context, imageData ImageData)
 	validationResult := p.validator.ValidateImageData(finalImageData)
 	if !validationResult.IsValid {
 		atomic.AddInt64(&p.metrics.FailedValidations, 1)
+		metrics.ImageValidationFailures.Inc()
 		if validationResult.SecurityRisk != "" {
 			atomic.AddInt64(&p.metrics.SecurityIncidents, 1)
+			metrics.ImageSecurityIncidents.Inc()
 			p.logger.Warn("检测到安全威胁", map[string]interface{}{
 				"error":         validationResult.Error.Error(),
 				"security_risk": validationResult.SecurityRisk,
@@ -1,8 +1,11 @@
 package mcp
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,35 +13,90 @@ import (
 	"xiaozhi-server-go/src/core/utils"
 
 	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/sashabaranov/go-openai"
 )
 
+// MCP传输方式
+const (
+	TransportStdio          = "stdio"
+	TransportSSE            = "sse"
+	TransportStreamableHTTP = "streamable-http"
+)
+
+const (
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = time.Minute
+	pingTimeout         = 5 * time.Second
+)
+
 // Config 定义MCP客户端配置
 type Config struct {
-	Enabled       bool     `yaml:"enabled"`
-	ServerAddress string   `yaml:"server_address"`
-	ServerPort    int      `yaml:"server_port"`
-	Namespace     string   `yaml:"namespace"`
-	NodeID        string   `yaml:"node_id"`
-	ResourceTypes []string `yaml:"resource_types"`
-	Command       string   `yaml:"command,omitempty"` // 命令行连接方式
-	Args          []string `yaml:"args,omitempty"`    // 命令行参数
-	Env           []string `yaml:"env,omitempty"`     // 环境变量
-	URL           string   `yaml:"url,omitempty"`     // SSE连接URL
+	Enabled       bool              `yaml:"enabled"`
+	ServerAddress string            `yaml:"server_address"`
+	ServerPort    int               `yaml:"server_port"`
+	Namespace     string            `yaml:"namespace"`
+	NodeID        string            `yaml:"node_id"`
+	ResourceTypes []string          `yaml:"resource_types"`
+	Command       string            `yaml:"command,omitempty"`   // 命令行连接方式
+	Args          []string          `yaml:"args,omitempty"`      // 命令行参数
+	Env           []string          `yaml:"env,omitempty"`       // 环境变量
+	URL           string            `yaml:"url,omitempty"`       // SSE或streamable HTTP连接URL
+	Transport     string            `yaml:"transport,omitempty"` // stdio、sse、streamable-http，为空时根据command/url推断
+	Headers       map[string]string `yaml:"headers,omitempty"`   // 远程MCP服务的请求头
+	Token         string            `yaml:"token,omitempty"`     // 远程MCP服务的访问令牌，以 Bearer 方式发送
 }
 
-// Client 封装MCP客户端功能
+// transport 返回实际使用的传输方式，URL以/sse结尾时使用SSE，其他URL使用streamable HTTP
+func (cfg *Config) transport() string {
+	switch strings.ToLower(cfg.Transport) {
+	case TransportStdio:
+		return TransportStdio
+	case TransportSSE:
+		return TransportSSE
+	case TransportStreamableHTTP, "streamable_http", "streamablehttp", "http":
+		return TransportStreamableHTTP
+	}
+	if cfg.Command != "" {
+		return TransportStdio
+	}
+	if strings.HasSuffix(strings.TrimRight(cfg.URL, "/"), "/sse") {
+		return TransportSSE
+	}
+	return TransportStreamableHTTP
+}
+
+// headers 返回远程MCP服务的请求头，配置了令牌时添加 Authorization
+func (cfg *Config) headers() map[string]string {
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	if cfg.Token != "" {
+		if _, ok := headers["Authorization"]; !ok {
+			headers["Authorization"] = "Bearer " + cfg.Token
+		}
+	}
+	return headers
+}
+
+// Client 封装MCP客户端功能，支持 stdio、SSE 和 streamable HTTP 三种传输方式，可被多个连接并发调用
 type Client struct {
 	client         *mcpclient.Client
-	stdioClient    *mcpclient.Client
 	config         *Config
+	transport      string
 	name           string
 	tools          []Tool
 	ready          bool
 	mu             sync.RWMutex
-	useStdioClient bool
 	logger         *utils.Logger
+	onToolsChanged func()
+
+	// 连接生命周期，SSE长连接依赖该上下文，Stop时取消
+	ctx          context.Context
+	cancel       context.CancelFunc
+	reconnecting bool
 }
 
 // NewClient 创建一个新的MCP客户端实例
@@ -48,65 +106,67 @@ func NewClient(config *Config, logger *utils.Logger) (*Client, error) {
 	}
 
 	c := &Client{
-		config: config,
-		tools:  make([]Tool, 0),
-		ready:  false,
-		logger: logger,
-	}
-
-	// 根据配置选择适当的客户端类型
-	if config.Command != "" {
-		// 使用命令行方式连接
-		stdioClient, err := mcpclient.NewStdioMCPClient(
-			config.Command,
-			config.Env,
-			config.Args...,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create stdio MCP client: %w", err)
-		}
-		c.stdioClient = stdioClient
-		c.useStdioClient = true
-	} else {
-		fmt.Println("Unsupported MCP client type, only stdio client is supported")
+		config:    config,
+		transport: config.transport(),
+		tools:     make([]Tool, 0),
+		ready:     false,
+		logger:    logger,
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	if c.transport != TransportStdio && config.URL == "" {
+		return nil, fmt.Errorf("MCP %s transport requires url", c.transport)
+	}
+	if c.transport == TransportStdio && config.Command == "" {
+		return nil, fmt.Errorf("MCP stdio transport requires command")
 	}
 
 	return c, nil
 }
 
-// Start 启动MCP客户端并监听资源更新
-func (c *Client) Start(ctx context.Context) error {
-	if c.useStdioClient {
-		//c.logger.Info("Starting MCP stdio client with command: %s", c.config.Command)
-
-		// 创建初始化请求
-		initRequest := mcp.InitializeRequest{}
-		initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-		initRequest.Params.ClientInfo = mcp.Implementation{
-			Name:    "zhi-server",
-			Version: "1.0.0",
-		}
+// OnToolsChanged 设置服务端工具列表变化后的回调
+func (c *Client) OnToolsChanged(handler func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onToolsChanged = handler
+}
 
-		// 设置超时上下文
-		initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
+// target 返回用于日志的连接目标
+func (c *Client) target() string {
+	if c.transport == TransportStdio {
+		return c.config.Command
+	}
+	return c.config.URL
+}
 
-		// 初始化客户端
-		initResult, err := c.stdioClient.Initialize(initCtx, initRequest)
+// newTransportClient 按配置的传输方式创建底层客户端
+func (c *Client) newTransportClient() (*mcpclient.Client, error) {
+	switch c.transport {
+	case TransportStdio:
+		client, err := mcpclient.NewStdioMCPClient(c.config.Command, c.config.Env, c.config.Args...)
 		if err != nil {
-			return fmt.Errorf("failed to initialize stdio MCP client: %w", err)
+			return nil, fmt.Errorf("failed to create stdio MCP client: %w", err)
 		}
-		c.name = initResult.ServerInfo.Name
-		c.logger.Info("Initialized server: %s %s with conmmand: %s",
-			initResult.ServerInfo.Name,
-			initResult.ServerInfo.Version,
-			c.config.Command)
-
-		// 获取工具列表
-		err = c.fetchTools(ctx)
+		return client, nil
+	case TransportSSE:
+		client, err := mcpclient.NewSSEMCPClient(c.config.URL, transport.WithHeaders(c.config.headers()))
 		if err != nil {
-			return fmt.Errorf("failed to fetch tools: %w", err)
+			return nil, fmt.Errorf("failed to create SSE MCP client: %w", err)
 		}
+		return client, nil
+	default:
+		client, err := mcpclient.NewStreamableHttpClient(c.config.URL, transport.WithHTTPHeaders(c.config.headers()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create streamable HTTP MCP client: %w", err)
+		}
+		return client, nil
+	}
+}
+
+// Start 启动MCP客户端并监听资源更新
+func (c *Client) Start(ctx context.Context) error {
+	if err := c.connect(ctx); err != nil {
+		return err
 	}
 
 	c.mu.Lock()
@@ -116,68 +176,142 @@ func (c *Client) Start(ctx context.Context) error {
 	return nil
 }
 
+// connect 建立连接、初始化并获取工具列表，成功后替换当前底层客户端
+func (c *Client) connect(ctx context.Context) error {
+	client, err := c.newTransportClient()
+	if err != nil {
+		return err
+	}
+
+	// stdio客户端创建时已启动子进程，SSE需要建立长连接
+	if c.transport != TransportStdio {
+		if err := client.Start(c.ctx); err != nil {
+			return fmt.Errorf("failed to start %s MCP client %s: %w", c.transport, c.config.URL, err)
+		}
+	}
+	client.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == mcp.MethodNotificationToolsListChanged {
+			go c.refreshTools()
+		}
+	})
+
+	// 创建初始化请求
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    "zhi-server",
+		Version: "1.0.0",
+	}
+
+	// 设置超时上下文
+	initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// 初始化客户端
+	initResult, err := client.Initialize(initCtx, initRequest)
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("failed to initialize %s MCP client: %w", c.transport, err)
+	}
+	c.logger.Info("Initialized server: %s %s with %s: %s",
+		initResult.ServerInfo.Name,
+		initResult.ServerInfo.Version,
+		c.transport,
+		c.target())
+
+	c.mu.Lock()
+	old := c.client
+	c.client = client
+	c.name = initResult.ServerInfo.Name
+	c.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	if stderr, ok := mcpclient.GetStderr(client); ok {
+		go c.watchProcess(client, stderr)
+	}
+
+	// 获取工具列表
+	if err := c.fetchTools(ctx); err != nil {
+		return fmt.Errorf("failed to fetch tools: %w", err)
+	}
+	return nil
+}
+
+// refreshTools 收到 notifications/tools/list_changed 后重新获取工具列表
+func (c *Client) refreshTools() {
+	ctx, cancel := context.WithTimeout(c.ctx, 30*time.Second)
+	defer cancel()
+	if err := c.fetchTools(ctx); err != nil {
+		c.logger.Error("Failed to refresh tools of MCP server %s: %v", c.name, err)
+		return
+	}
+	c.mu.RLock()
+	handler := c.onToolsChanged
+	c.mu.RUnlock()
+	if handler != nil {
+		handler()
+	}
+}
+
 // fetchTools 获取可用的工具列表
 func (c *Client) fetchTools(ctx context.Context) error {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+	if client == nil {
+		return fmt.Errorf("MCP client is not connected")
+	}
 
-	if c.useStdioClient {
-		// 使用协议方式获取工具列表
-		toolsRequest := mcp.ListToolsRequest{}
-		tools, err := c.stdioClient.ListTools(ctx, toolsRequest)
-		if err != nil {
-			return fmt.Errorf("failed to list tools: %w", err)
-		}
+	// 使用协议方式获取工具列表
+	toolsRequest := mcp.ListToolsRequest{}
+	tools, err := client.ListTools(ctx, toolsRequest)
+	if err != nil {
+		return fmt.Errorf("failed to list tools: %w", err)
+	}
 
-		c.mu.Lock()
-		defer c.mu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		// 清空当前工具列表
-		c.tools = make([]Tool, 0, len(tools.Tools))
+	// 清空当前工具列表
+	c.tools = make([]Tool, 0, len(tools.Tools))
 
-		// 添加获取到的工具
-		toolNames := ""
-		for _, tool := range tools.Tools {
-			required := tool.InputSchema.Required
-			if required == nil {
-				required = make([]string, 0)
-			}
-			c.tools = append(c.tools, Tool{
-				Name:        tool.Name,
-				Description: tool.Description,
-				InputSchema: ToolInputSchema{
-					Type:       tool.InputSchema.Type,
-					Properties: tool.InputSchema.Properties,
-					Required:   required,
-				},
-			})
-			toolNames += fmt.Sprintf("%s, ", tool.Name)
-			//log.Printf("Added tool: %s - %s %v; %v; %v", tool.Name, tool.Description, tool.InputSchema, tool.RawInputSchema, tool.Annotations)
+	// 添加获取到的工具
+	toolNames := ""
+	for _, tool := range tools.Tools {
+		required := tool.InputSchema.Required
+		if required == nil {
+			required = make([]string, 0)
 		}
-		c.logger.Info("Fetching %s available tools %s", c.name, toolNames)
-		return nil
-	} else {
-		// 原有方式的实现保持不变
-		// 这里可以通过资源类型获取工具信息
-		return nil
+		c.tools = append(c.tools, Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: ToolInputSchema{
+				Type:       tool.InputSchema.Type,
+				Properties: tool.InputSchema.Properties,
+				Required:   required,
+			},
+		})
+		toolNames += fmt.Sprintf("%s, ", tool.Name)
 	}
+	c.logger.Info("Fetching %s available tools %s", c.name, toolNames)
+	return nil
 }
 
 // Stop 停止MCP客户端
 func (c *Client) Stop() {
-	if c.useStdioClient {
-		if c.stdioClient != nil {
-			c.logger.Info("Stopping MCP stdio client")
-			c.stdioClient.Close()
-		}
-	} else {
-		if c.client != nil {
-			c.logger.Info("Stopping MCP client")
-			c.client.Close()
-		}
-	}
+	c.cancel()
 
 	c.mu.Lock()
+	client := c.client
+	c.client = nil
 	c.ready = false
 	c.mu.Unlock()
+
+	if client != nil {
+		c.logger.Info("Stopping MCP %s client: %s", c.transport, c.target())
+		client.Close()
+	}
 }
 
 // HasTool 检查是否有指定名称的工具
@@ -233,52 +367,58 @@ func (c *Client) CallTool(ctx context.Context, name string, args map[string]any)
 		return nil, fmt.Errorf("tool %s not found", name)
 	}
 
-	if c.useStdioClient {
-		callRequest := mcp.CallToolRequest{}
-		callRequest.Params.Name = name
-		callRequest.Params.Arguments = args
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+	if client == nil {
+		return nil, fmt.Errorf("MCP server %s is reconnecting", c.target())
+	}
 
-		result, err := c.stdioClient.CallTool(ctx, callRequest)
-		if err != nil {
-			return nil, fmt.Errorf("failed to call tool %s: %w", name, err)
-		}
+	callRequest := mcp.CallToolRequest{}
+	callRequest.Params.Name = name
+	callRequest.Params.Arguments = args
 
-		// 处理返回结果
-		if result == nil || len(result.Content) == 0 {
-			return nil, nil
+	result, err := client.CallTool(ctx, callRequest)
+	if err != nil {
+		if ctx.Err() == nil {
+			// 工具调用失败可能是连接已断开，后台检查并重连
+			go c.checkConnection()
 		}
+		return nil, fmt.Errorf("failed to call tool %s: %w", name, err)
+	}
 
-		// 返回第一个内容项，或整个内容列表
-		if len(result.Content) == 1 {
-			// 如果是文本内容，直接返回文本
-			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
-				return textContent.Text, nil
-			}
-			ret := types.ActionResponse{
-				Action: types.ActionTypeReqLLM,
-				Result: result.Content[0],
-			}
-			return ret, nil
-		}
+	// 处理返回结果
+	if result == nil || len(result.Content) == 0 {
+		return nil, nil
+	}
 
-		// 处理多个内容项的情况
-		processedContent := make([]interface{}, 0, len(result.Content))
-		for _, content := range result.Content {
-			if textContent, ok := content.(mcp.TextContent); ok {
-				processedContent = append(processedContent, textContent.Text)
-			} else {
-				processedContent = append(processedContent, content)
-			}
+	// 返回第一个内容项，或整个内容列表
+	if len(result.Content) == 1 {
+		// 如果是文本内容，直接返回文本
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			return textContent.Text, nil
 		}
 		ret := types.ActionResponse{
 			Action: types.ActionTypeReqLLM,
-			Result: processedContent,
+			Result: result.Content[0],
 		}
 		return ret, nil
 	}
 
-	// 原始网络客户端不支持直接调用工具
-	return nil, fmt.Errorf("tool calling not implemented for network client")
+	// 处理多个内容项的情况
+	processedContent := make([]interface{}, 0, len(result.Content))
+	for _, content := range result.Content {
+		if textContent, ok := content.(mcp.TextContent); ok {
+			processedContent = append(processedContent, textContent.Text)
+		} else {
+			processedContent = append(processedContent, content)
+		}
+	}
+	ret := types.ActionResponse{
+		Action: types.ActionTypeReqLLM,
+		Result: processedContent,
+	}
+	return ret, nil
 }
 
 // IsReady 检查客户端是否已初始化完成并准备就绪
@@ -288,19 +428,92 @@ func (c *Client) IsReady() bool {
 	return c.ready
 }
 
-// ResetConnection 重置连接状态
+// ResetConnection 后台检查连接并在断开时重连，保留工具信息
 func (c *Client) ResetConnection() error {
+	go c.checkConnection()
+	return nil
+}
+
+// watchProcess 转发stdio子进程的标准错误输出，子进程意外退出时自动重启
+func (c *Client) watchProcess(client *mcpclient.Client, stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		c.logger.Debug("MCP server %s: %s", c.config.Command, scanner.Text())
+	}
+
+	// 子进程退出或客户端被替换、关闭时标准错误输出结束
+	c.mu.RLock()
+	current := c.client == client
+	c.mu.RUnlock()
+	if !current || c.ctx.Err() != nil {
+		return
+	}
+	c.logger.Warn("MCP server process %s exited unexpectedly, restarting", c.config.Command)
+	c.reconnect(false)
+}
+
+// checkConnection 探测连接是否可用，不可用时标记为未就绪并按退避间隔重连
+func (c *Client) checkConnection() {
+	c.reconnect(true)
+}
+
+// reconnect 按退避间隔重连直到成功或客户端停止，probe 为true时先探测连接，连接可用则直接返回。
+// 同一时间只有一个重连在进行，重连成功后通知工具列表变化
+func (c *Client) reconnect(probe bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	if c.reconnecting || c.ctx.Err() != nil {
+		c.mu.Unlock()
+		return
+	}
+	client := c.client
+	c.reconnecting = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.reconnecting = false
+		c.mu.Unlock()
+	}()
+
+	if probe && client != nil {
+		ctx, cancel := context.WithTimeout(c.ctx, pingTimeout)
+		err := client.Ping(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+		c.logger.Warn("MCP server %s is unreachable, reconnecting: %v", c.target(), err)
+	}
 
-	// 保留工具信息，只重置连接状态
+	c.mu.Lock()
 	c.ready = false
+	c.mu.Unlock()
 
-	// 如果有活跃连接，优雅关闭
-	if c.useStdioClient && c.stdioClient != nil {
-		// 不完全关闭，只标记为未就绪
-		// 在下次Start时会重新建立连接
-	}
+	backoff := reconnectMinBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(c.ctx, 30*time.Second)
+		err := c.connect(ctx)
+		cancel()
+		if err == nil {
+			c.mu.Lock()
+			c.ready = true
+			handler := c.onToolsChanged
+			c.mu.Unlock()
+			c.logger.Info("Reconnected to MCP server %s after %d attempt(s)", c.target(), attempt)
+			if handler != nil {
+				handler()
+			}
+			return
+		}
+		c.logger.Error("Failed to reconnect MCP server %s (attempt %d), retry in %v: %v", c.target(), attempt, backoff, err)
 
-	return nil
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+	}
 }
@@ -45,3 +45,17 @@ type MCPClient interface {
 
 // 确保Client实现了MCPClient接口
 var _ MCPClient = (*Client)(nil)
+
+// deviceIDKey 工具调用上下文中设备ID的键
+type deviceIDKey struct{}
+
+// WithDeviceID 在工具调用上下文中携带发起调用的设备ID
+func WithDeviceID(ctx context.Context, deviceID string) context.Context {
+	return context.WithValue(ctx, deviceIDKey{}, deviceID)
+}
+
+// DeviceIDFromContext 获取发起工具调用的设备ID
+func DeviceIDFromContext(ctx context.Context) string {
+	deviceID, _ := ctx.Value(deviceIDKey{}).(string)
+	return deviceID
+}
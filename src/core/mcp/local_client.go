@@ -65,6 +65,9 @@ func (c *LocalClient) RegisterTools() {
 		} else if funcName == "play_music" {
 			c.AddToolPlayMusic()
 			c.logger.Info("RegisterTools: play_music tool registered")
+		} else if funcName == "reminder" {
+			c.AddToolReminder()
+			c.logger.Info("RegisterTools: reminder tools registered")
 		} else {
 			c.logger.Warn("RegisterTools: unknown function name %s", funcName)
 		}
@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"xiaozhi-server-go/src/core/reminder"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/models"
+)
+
+// AddToolReminder 注册定时提醒相关的工具：新增、查询和取消提醒
+func (c *LocalClient) AddToolReminder() error {
+	c.AddTool("add_reminder",
+		"当用户要求在某个时间提醒自己、设置闹钟或周期性闹钟时调用。"+
+			"一次性提醒使用 delay_minutes 或 time 指定时间，周期闹钟使用 cron 表达式，三者只需提供一个",
+		ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"content": map[string]any{
+					"type":        "string",
+					"description": "到点后播报的提醒内容，例如：该喝水了",
+				},
+				"delay_minutes": map[string]any{
+					"type":        "number",
+					"description": "多少分钟后提醒，例如用户说二十分钟后提醒我时为20",
+				},
+				"time": map[string]any{
+					"type":        "string",
+					"description": "提醒的具体时间，格式为 2006-01-02 15:04，当天的时间可以只写 15:04",
+				},
+				"cron": map[string]any{
+					"type":        "string",
+					"description": "周期闹钟的cron表达式，格式为 分 时 日 月 周，例如每个工作日早上7点半为 30 7 * * 1-5",
+				},
+				"music": map[string]any{
+					"type":        "string",
+					"description": "提醒时播放的歌曲名，用户没有要求时不填",
+				},
+			},
+			Required: []string{"content"},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			deviceID := DeviceIDFromContext(ctx)
+			content, _ := args["content"].(string)
+			music, _ := args["music"].(string)
+			cron, _ := args["cron"].(string)
+			spec := reminder.Spec{Content: content, Music: music, Cron: strings.TrimSpace(cron)}
+			if spec.Cron == "" {
+				fireAt, err := parseReminderTime(args)
+				if err != nil {
+					return reminderResult("设置提醒失败：" + err.Error()), nil
+				}
+				spec.FireAt = fireAt
+			}
+
+			r, err := reminder.Default().Add(deviceID, spec)
+			if err != nil {
+				c.logger.Error("设备 %s 设置提醒失败: %v", deviceID, err)
+				return reminderResult("设置提醒失败：" + err.Error()), nil
+			}
+			return reminderResult("提醒已设置，" + describeReminder(r)), nil
+		})
+
+	c.AddTool("list_reminders",
+		"当用户询问自己设置了哪些提醒或闹钟时调用",
+		ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{},
+			Required:   []string{},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			reminders, err := reminder.Default().List(DeviceIDFromContext(ctx))
+			if err != nil {
+				return reminderResult("查询提醒失败：" + err.Error()), nil
+			}
+			if len(reminders) == 0 {
+				return reminderResult("当前没有设置任何提醒。"), nil
+			}
+			lines := make([]string, 0, len(reminders))
+			for i := range reminders {
+				lines = append(lines, describeReminder(&reminders[i]))
+			}
+			return reminderResult(fmt.Sprintf("当前共有 %d 个提醒：\n%s", len(reminders), strings.Join(lines, "\n"))), nil
+		})
+
+	c.AddTool("cancel_reminder",
+		"当用户要取消某个提醒或闹钟时调用，编号不明确时先调用 list_reminders 查询",
+		ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"id": map[string]any{
+					"type":        "number",
+					"description": "要取消的提醒编号",
+				},
+			},
+			Required: []string{"id"},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			id, ok := args["id"].(float64)
+			if !ok || id <= 0 {
+				return reminderResult("取消提醒失败：提醒编号无效"), nil
+			}
+			r, err := reminder.Default().Cancel(DeviceIDFromContext(ctx), uint(id))
+			if err != nil {
+				return reminderResult("取消提醒失败：" + err.Error()), nil
+			}
+			return reminderResult("已取消提醒，" + describeReminder(r)), nil
+		})
+
+	return nil
+}
+
+// parseReminderTime 根据 delay_minutes 或 time 参数计算一次性提醒的触发时间
+func parseReminderTime(args map[string]any) (time.Time, error) {
+	now := time.Now()
+	if delay, ok := args["delay_minutes"].(float64); ok && delay > 0 {
+		return now.Add(time.Duration(delay * float64(time.Minute))), nil
+	}
+
+	value, _ := args["time"].(string)
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("没有指定提醒时间")
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.ParseInLocation("15:04", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无法识别的时间格式: %s", value)
+	}
+	// 只有时分时取今天，已经过去则顺延到明天
+	fireAt := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.Local)
+	if !fireAt.After(now) {
+		fireAt = fireAt.AddDate(0, 0, 1)
+	}
+	return fireAt, nil
+}
+
+// describeReminder 生成提醒的描述文本，供LLM组织回复
+func describeReminder(r *models.Reminder) string {
+	desc := fmt.Sprintf("编号 %d：%s，", r.ID, r.Content)
+	if r.Cron != "" {
+		desc += fmt.Sprintf("周期闹钟（%s），下次响铃时间 %s", r.Cron, r.FireAt.Format("2006-01-02 15:04"))
+	} else {
+		desc += "提醒时间 " + r.FireAt.Format("2006-01-02 15:04")
+	}
+	if r.Music != "" {
+		desc += "，播放歌曲《" + r.Music + "》"
+	}
+	return desc
+}
+
+func reminderResult(text string) types.ActionResponse {
+	return types.ActionResponse{
+		Action: types.ActionTypeReqLLM,
+		Result: text,
+	}
+}
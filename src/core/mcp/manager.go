@@ -2,10 +2,7 @@ package mcp
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 	"xiaozhi-server-go/src/configs"
@@ -20,92 +17,58 @@ type Conn interface {
 	WriteMessage(messageType int, data []byte) error
 }
 
-// Manager MCP服务管理器
+// Manager MCP服务管理器，作为资源池对象只保存连接相关的部分：
+// 本地MCP工具、小智设备MCP客户端和函数注册；外部MCP服务由 SharedClients 在进程内共享
 type Manager struct {
 	logger                *utils.Logger
 	conn                  Conn
 	funcHandler           types.FunctionRegistryInterface
-	configPath            string
-	clients               map[string]MCPClient
-	localClient           *LocalClient // 本地MCP客户端
+	shared                *SharedClients
+	listenerID            int                  // 订阅共享客户端工具变化的ID
+	clients               map[string]MCPClient // 连接相关的客户端：local、xiaozhi
+	localClient           *LocalClient         // 本地MCP客户端
 	tools                 []string
 	XiaoZhiMCPClient      *XiaoZhiMCPClient // XiaoZhiMCPClient用于处理小智MCP相关逻辑
 	bRegisteredXiaoZhiMCP bool              // 是否已注册小智MCP工具
-	isInitialized         bool              // 添加初始化状态标记
 	systemCfg             *configs.Config
 	mu                    sync.RWMutex
 }
 
-// NewManagerForPool 创建用于资源池的MCP管理器
+// NewManagerForPool 创建用于资源池的MCP管理器，首次创建时启动共享的外部MCP服务
 func NewManagerForPool(lg *utils.Logger, cfg *configs.Config) *Manager {
-	projectDir := utils.GetProjectDir()
-	configPath := filepath.Join(projectDir, ".mcp_server_settings.json")
-
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		configPath = ""
-	}
+	shared := Shared()
+	shared.Start(lg)
 
 	mgr := &Manager{
 		logger:                lg,
 		funcHandler:           nil, // 将在绑定连接时设置
 		conn:                  nil, // 将在绑定连接时设置
-		configPath:            configPath,
+		shared:                shared,
 		clients:               make(map[string]MCPClient),
 		tools:                 make([]string, 0),
 		bRegisteredXiaoZhiMCP: false,
 		systemCfg:             cfg,
 	}
-	// 预先初始化非连接相关的MCP服务器
-	if err := mgr.preInitializeServers(); err != nil {
-		lg.Error("预初始化MCP服务器失败: %v", err)
-	}
+	mgr.localClient, _ = NewLocalClient(lg, cfg)
+	mgr.localClient.Start(context.Background())
+	mgr.clients["local"] = mgr.localClient
+	mgr.listenerID = shared.Subscribe(mgr.refreshExternalTools)
 
 	return mgr
 }
 
-// preInitializeServers 预初始化不依赖连接的MCP服务器
-func (m *Manager) preInitializeServers() error {
-
-	m.localClient, _ = NewLocalClient(m.logger, m.systemCfg)
-	m.localClient.Start(context.Background())
-	m.clients["local"] = m.localClient
-
-	config := m.LoadConfig()
-	if config == nil {
-		return fmt.Errorf("no valid MCP server configuration found")
-	}
-
-	for name, srvConfig := range config {
-		// 只初始化不需要连接的外部MCP服务器
-		srvConfigMap, ok := srvConfig.(map[string]interface{})
-
-		if !ok {
-			m.logger.Warn("Invalid configuration format for server %s", name)
-			continue
-		}
-
-		// 创建并启动外部MCP客户端
-		clientConfig, err := convertConfig(srvConfigMap)
-		if err != nil {
-			m.logger.Error("Failed to convert config for server %s: %v", name, err)
-			continue
-		}
-
-		client, err := NewClient(clientConfig, m.logger)
-		if err != nil {
-			m.logger.Error("Failed to create MCP client for server %s: %v", name, err)
-			continue
-		}
-
-		if err := client.Start(context.Background()); err != nil {
-			m.logger.Error("Failed to start MCP client %s: %v", name, err)
-			continue
+// toolClients 返回需要注册到函数注册表的客户端：本地客户端和共享的外部客户端，小智客户端单独处理
+func (m *Manager) toolClients() []MCPClient {
+	clients := make([]MCPClient, 0, len(m.clients))
+	for name, client := range m.clients {
+		if name != "xiaozhi" {
+			clients = append(clients, client)
 		}
-		m.clients[name] = client
 	}
-
-	m.isInitialized = true
-	return nil
+	for _, client := range m.shared.Clients() {
+		clients = append(clients, client)
+	}
+	return clients
 }
 
 // BindConnection 绑定连接到MCP Manager
@@ -167,9 +130,9 @@ func (m *Manager) registerAllToolsIfNeeded() {
 		m.bRegisteredXiaoZhiMCP = true
 	}
 
-	// 注册其他外部MCP客户端工具
-	for name, client := range m.clients {
-		if name != "xiaozhi" && client.IsReady() {
+	// 注册本地和外部MCP客户端工具
+	for _, client := range m.toolClients() {
+		if client.IsReady() {
 			tools := client.GetAvailableTools()
 			for _, tool := range tools {
 				toolName := tool.Function.Name
@@ -183,6 +146,36 @@ func (m *Manager) registerAllToolsIfNeeded() {
 	}
 }
 
+// refreshExternalTools 外部MCP服务的工具列表变化后，同步当前连接注册的工具
+func (m *Manager) refreshExternalTools() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	available := make(map[string]bool)
+	for _, client := range m.toolClients() {
+		if !client.IsReady() {
+			continue
+		}
+		for _, tool := range client.GetAvailableTools() {
+			available[tool.Function.Name] = true
+		}
+	}
+
+	tools := m.tools[:0]
+	for _, toolName := range m.tools {
+		if available[toolName] {
+			tools = append(tools, toolName)
+			continue
+		}
+		if m.funcHandler != nil {
+			m.funcHandler.UnregisterFunction(toolName)
+		}
+		m.logger.Info("MCP tool removed: %s", toolName)
+	}
+	m.tools = tools
+	m.registerAllToolsIfNeeded()
+}
+
 // 新增辅助方法
 func (m *Manager) isToolRegistered(toolName string) bool {
 	for _, tool := range m.tools {
@@ -209,50 +202,18 @@ func (m *Manager) Reset() error {
 		m.XiaoZhiMCPClient.ResetConnection() // 新增方法
 	}
 
-	// 对外部MCP客户端进行连接重置
-	for name, client := range m.clients {
-		if name != "xiaozhi" {
-			if resetter, ok := client.(interface{ ResetConnection() error }); ok {
-				resetter.ResetConnection()
-			}
-		}
-	}
-
 	return nil
 }
 
-// Cleanup 实现Provider接口的Cleanup方法
+// Cleanup 实现Provider接口的Cleanup方法，共享的外部MCP客户端不受影响
 func (m *Manager) Cleanup() error {
+	m.shared.Unsubscribe(m.listenerID)
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	m.CleanupAll(ctx)
 	return m.Reset()
 }
 
-// LoadConfig 加载MCP服务配置
-func (m *Manager) LoadConfig() map[string]interface{} {
-	if m.configPath == "" {
-		return nil
-	}
-
-	data, err := os.ReadFile(m.configPath)
-	if err != nil {
-		m.logger.Error(fmt.Sprintf("Error loading MCP config from %s: %v", m.configPath, err))
-		return nil
-	}
-
-	var config struct {
-		MCPServers map[string]interface{} `json:"mcpServers"`
-	}
-
-	if err := json.Unmarshal(data, &config); err != nil {
-		m.logger.Error(fmt.Sprintf("Error parsing MCP config: %v", err))
-		return nil
-	}
-
-	return config.MCPServers
-}
-
 func (m *Manager) HandleXiaoZhiMCPMessage(msgMap map[string]interface{}) error {
 	// 处理小智MCP消息
 	if m.XiaoZhiMCPClient == nil {
@@ -318,11 +279,31 @@ func convertConfig(cfg map[string]interface{}) (*Config, error) {
 		}
 	}
 
-	// SSE连接URL
+	// SSE或streamable HTTP连接URL
 	if url, ok := cfg["url"].(string); ok {
 		config.URL = url
 	}
 
+	// 传输方式，兼容常见配置中的 type 字段
+	if transport, ok := cfg["transport"].(string); ok {
+		config.Transport = transport
+	} else if transport, ok := cfg["type"].(string); ok {
+		config.Transport = transport
+	}
+
+	// 远程MCP服务的请求头和访问令牌
+	if headers, ok := cfg["headers"].(map[string]interface{}); ok {
+		config.Headers = make(map[string]string, len(headers))
+		for k, v := range headers {
+			if vStr, ok := v.(string); ok {
+				config.Headers[k] = vStr
+			}
+		}
+	}
+	if token, ok := cfg["token"].(string); ok {
+		config.Token = token
+	}
+
 	return config, nil
 }
 
@@ -368,19 +349,32 @@ func (m *Manager) IsMCPTool(toolName string) bool {
 func (m *Manager) ExecuteTool(ctx context.Context, toolName string, arguments map[string]interface{}) (interface{}, error) {
 	m.logger.Info(fmt.Sprintf("Executing tool %s with arguments: %v", toolName, arguments))
 
+	// 只在查找客户端时持锁，耗时的工具调用不阻塞工具列表的刷新和其他调用
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
+	var target MCPClient
 	for _, client := range m.clients {
 		if client.HasTool(toolName) {
-			return client.CallTool(ctx, toolName, arguments)
+			target = client
+			break
 		}
 	}
+	m.mu.RUnlock()
+	if target == nil {
+		for _, client := range m.shared.Clients() {
+			if client.HasTool(toolName) {
+				target = client
+				break
+			}
+		}
+	}
+	if target != nil {
+		return target.CallTool(ctx, toolName, arguments)
+	}
 
 	return nil, fmt.Errorf("Tool %s not found in any MCP server", toolName)
 }
 
-// CleanupAll 依次关闭所有MCPClient
+// CleanupAll 依次关闭连接相关的MCPClient，共享的外部MCP客户端由 SharedClients 管理
 func (m *Manager) CleanupAll(ctx context.Context) {
 	m.mu.Lock()
 	clients := make(map[string]MCPClient, len(m.clients))
@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// SharedClients 进程内共享的外部MCP客户端。
+// .mcp_server_settings.json 中的外部MCP服务在整个进程中只启动一次，资源池中的 Manager
+// 只保存连接相关的部分（小智设备MCP、函数注册），工具调用转发到这里的共享客户端
+type SharedClients struct {
+	startMu sync.Mutex
+	started bool
+
+	mu         sync.RWMutex
+	logger     *utils.Logger
+	configPath string
+	clients    map[string]*Client
+	listeners  map[int]func() // 工具列表变化的订阅者
+	nextID     int
+}
+
+var defaultShared = &SharedClients{
+	clients:   make(map[string]*Client),
+	listeners: make(map[int]func()),
+}
+
+// Shared 返回全局共享的外部MCP客户端
+func Shared() *SharedClients {
+	return defaultShared
+}
+
+// Start 按 .mcp_server_settings.json 启动外部MCP客户端，已启动时直接返回
+func (s *SharedClients) Start(logger *utils.Logger) {
+	s.startMu.Lock()
+	defer s.startMu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	configPath := filepath.Join(utils.GetProjectDir(), ".mcp_server_settings.json")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		configPath = ""
+	}
+	s.mu.Lock()
+	s.logger = logger
+	s.configPath = configPath
+	s.mu.Unlock()
+
+	config := s.LoadConfig()
+	if config == nil {
+		logger.Info("未找到有效的外部MCP服务配置")
+		return
+	}
+
+	for name, srvConfig := range config {
+		srvConfigMap, ok := srvConfig.(map[string]interface{})
+		if !ok {
+			logger.Warn("Invalid configuration format for server %s", name)
+			continue
+		}
+
+		clientConfig, err := convertConfig(srvConfigMap)
+		if err != nil {
+			logger.Error("Failed to convert config for server %s: %v", name, err)
+			continue
+		}
+
+		client, err := NewClient(clientConfig, logger)
+		if err != nil {
+			logger.Error("Failed to create MCP client for server %s: %v", name, err)
+			continue
+		}
+
+		client.OnToolsChanged(s.notifyToolsChanged)
+		if err := client.Start(context.Background()); err != nil {
+			logger.Error("Failed to start MCP client %s: %v", name, err)
+			if client.transport == TransportStdio {
+				continue
+			}
+			// 远程MCP服务暂时不可用时保留客户端，后台重连成功后通知各连接注册工具
+			go client.checkConnection()
+		}
+
+		s.mu.Lock()
+		s.clients[name] = client
+		s.mu.Unlock()
+	}
+	logger.Info("共享的外部MCP服务已启动，数量：%d", len(s.Clients()))
+}
+
+// LoadConfig 加载外部MCP服务配置
+func (s *SharedClients) LoadConfig() map[string]interface{} {
+	s.mu.RLock()
+	configPath, logger := s.configPath, s.logger
+	s.mu.RUnlock()
+	if configPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logger.Error("Error loading MCP config from %s: %v", configPath, err)
+		return nil
+	}
+
+	var config struct {
+		MCPServers map[string]interface{} `json:"mcpServers"`
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		logger.Error("Error parsing MCP config: %v", err)
+		return nil
+	}
+
+	return config.MCPServers
+}
+
+// Clients 返回按名称排序的共享客户端
+func (s *SharedClients) Clients() []*Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.clients))
+	for name := range s.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	clients := make([]*Client, 0, len(names))
+	for _, name := range names {
+		clients = append(clients, s.clients[name])
+	}
+	return clients
+}
+
+// Subscribe 订阅外部MCP服务的工具列表变化（服务端通知或重启后），返回用于取消订阅的ID
+func (s *SharedClients) Subscribe(handler func()) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.listeners[s.nextID] = handler
+	return s.nextID
+}
+
+// Unsubscribe 取消订阅
+func (s *SharedClients) Unsubscribe(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, id)
+}
+
+func (s *SharedClients) notifyToolsChanged() {
+	s.mu.RLock()
+	listeners := make([]func(), 0, len(s.listeners))
+	for _, handler := range s.listeners {
+		listeners = append(listeners, handler)
+	}
+	s.mu.RUnlock()
+
+	for _, handler := range listeners {
+		handler()
+	}
+}
+
+// Stop 关闭所有共享客户端并结束子进程，之后可以重新 Start
+func (s *SharedClients) Stop() {
+	s.startMu.Lock()
+	defer s.startMu.Unlock()
+
+	s.mu.Lock()
+	clients := s.clients
+	s.clients = make(map[string]*Client)
+	logger := s.logger
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for name, client := range clients {
+		wg.Add(1)
+		go func(name string, client *Client) {
+			defer wg.Done()
+			done := make(chan struct{})
+			go func() {
+				client.Stop()
+				close(done)
+			}()
+			select {
+			case <-done:
+				logger.Info(fmt.Sprintf("MCP client closed: %s", name))
+			case <-time.After(20 * time.Second):
+				logger.Error(fmt.Sprintf("Timeout closing MCP client %s", name))
+			}
+		}(name, client)
+	}
+	wg.Wait()
+	s.started = false
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import "sort"
+
+// 对话链路指标
+var (
+	// ASRLatency 语音结束到ASR返回最终识别结果的耗时
+	ASRLatency = NewHistogram("xiaozhi_asr_latency_seconds",
+		"语音结束到ASR返回最终识别结果的耗时（秒）", nil, "provider")
+	// LLMFirstSentenceLatency LLM请求开始到生成第一句话的耗时
+	LLMFirstSentenceLatency = NewHistogram("xiaozhi_llm_first_sentence_seconds",
+		"LLM请求开始到生成第一句话的耗时（秒）", nil, "provider")
+	// LLMResponseLatency LLM完整回复的耗时
+	LLMResponseLatency = NewHistogram("xiaozhi_llm_response_seconds",
+		"LLM完整回复的耗时（秒）", nil, "provider")
+	// TTSLatency 单句语音合成耗时
+	TTSLatency = NewHistogram("xiaozhi_tts_latency_seconds",
+		"单句语音合成耗时（秒）", nil, "provider")
+	// RoundFirstAudioLatency 轮次开始到下发第一句语音的耗时
+	RoundFirstAudioLatency = NewHistogram("xiaozhi_round_first_audio_seconds",
+		"对话轮次开始到下发第一句语音的耗时（秒）", nil)
+
+	// ToolCalls 工具调用次数
+	ToolCalls = NewCounter("xiaozhi_tool_calls_total", "工具调用次数", "tool")
+	// ToolCallErrors 工具调用失败次数
+	ToolCallErrors = NewCounter("xiaozhi_tool_call_errors_total", "工具调用失败次数", "tool")
+)
+
+// 图片处理指标
+var (
+	// ImageProcessed 处理的图片数量，source 为 url 或 base64
+	ImageProcessed = NewCounter("xiaozhi_image_processed_total", "处理的图片数量", "source")
+	// ImageValidationFailures 图片校验失败次数
+	ImageValidationFailures = NewCounter("xiaozhi_image_validation_failures_total", "图片校验失败次数")
+	// ImageSecurityIncidents 图片安全事件次数
+	ImageSecurityIncidents = NewCounter("xiaozhi_image_security_incidents_total", "图片安全事件次数")
+)
+
+// ServerStats 服务运行状态来源，由 WebSocket 服务实现
+type ServerStats interface {
+	GetActiveConnectionsCount() int
+	GetPoolStats() map[string]map[string]int
+	GetTaskQueueDepth() (queued, scheduled int)
+}
+
+// RegisterServerStats 注册连接数、资源池和任务队列的仪表盘
+func RegisterServerStats(stats ServerStats) {
+	NewGaugeFunc("xiaozhi_connections_active", "当前活跃的WebSocket连接数", func() []Sample {
+		return []Sample{{Value: float64(stats.GetActiveConnectionsCount())}}
+	})
+	NewGaugeFunc("xiaozhi_pool_available", "资源池中可用的资源数量", func() []Sample {
+		return poolSamples(stats.GetPoolStats(), "available")
+	}, "type")
+	NewGaugeFunc("xiaozhi_pool_total", "资源池中的资源总数", func() []Sample {
+		return poolSamples(stats.GetPoolStats(), "total")
+	}, "type")
+	NewGaugeFunc("xiaozhi_pool_max", "资源池最大容量", func() []Sample {
+		return poolSamples(stats.GetPoolStats(), "max")
+	}, "type")
+	NewGaugeFunc("xiaozhi_task_queue_depth", "任务管理器中等待执行的任务数", func() []Sample {
+		queued, scheduled := stats.GetTaskQueueDepth()
+		return []Sample{
+			{LabelValues: []string{"queued"}, Value: float64(queued)},
+			{LabelValues: []string{"scheduled"}, Value: float64(scheduled)},
+		}
+	}, "state")
+}
+
+// poolSamples 从资源池统计中提取指定字段，按池类型排序
+func poolSamples(stats map[string]map[string]int, field string) []Sample {
+	types := make([]string, 0, len(stats))
+	for typ := range stats {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+
+	samples := make([]Sample, 0, len(types))
+	for _, typ := range types {
+		samples = append(samples, Sample{LabelValues: []string{typ}, Value: float64(stats[typ][field])})
+	}
+	return samples
+}
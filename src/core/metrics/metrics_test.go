@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape 请求默认注册表的 HTTP 处理器，返回全部输出行
+func scrape(t *testing.T) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q，期望 text exposition 格式", ct)
+	}
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("读取指标失败: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+}
+
+func TestHandlerExposition(t *testing.T) {
+	hist := NewHistogram("test_latency_seconds", "测试耗时", []float64{1, 0.5, 2}, "provider")
+	hist.Observe(0.2, "edge")
+	hist.Observe(0.5, "edge")
+	hist.Observe(1.5, "edge")
+	hist.Observe(3, "edge")
+	counter := NewCounter("test_requests_total", "测试请求\n次数", "path")
+	counter.Inc(`C:\tmp`)
+	counter.Add(2, `say "hi"`+"\n")
+	NewGaugeFunc("test_connections", "测试连接数", func() []Sample {
+		return []Sample{{Value: 3}}
+	})
+
+	lines := scrape(t)
+	has := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		has[line] = true
+	}
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "直方图类型", line: "# TYPE test_latency_seconds histogram"},
+		{name: "计数器类型", line: "# TYPE test_requests_total counter"},
+		{name: "仪表盘类型", line: "# TYPE test_connections gauge"},
+		{name: "帮助文本换行转义", line: `# HELP test_requests_total 测试请求\n次数`},
+		{name: "分桶按上限排序且累计计数", line: `test_latency_seconds_bucket{provider="edge",le="0.5"} 2`},
+		{name: "等于上限计入该分桶", line: `test_latency_seconds_bucket{provider="edge",le="1"} 2`},
+		{name: "较大分桶包含较小分桶", line: `test_latency_seconds_bucket{provider="edge",le="2"} 3`},
+		{name: "+Inf分桶等于总数", line: `test_latency_seconds_bucket{provider="edge",le="+Inf"} 4`},
+		{name: "总和", line: `test_latency_seconds_sum{provider="edge"} 5.2`},
+		{name: "总数", line: `test_latency_seconds_count{provider="edge"} 4`},
+		{name: "标签值反斜杠转义", line: `test_requests_total{path="C:\\tmp"} 1`},
+		{name: "标签值引号和换行转义", line: `test_requests_total{path="say \"hi\"\n"} 2`},
+		{name: "无标签仪表盘", line: "test_connections 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !has[tt.line] {
+				t.Errorf("输出中缺少 %q\n%s", tt.line, strings.Join(lines, "\n"))
+			}
+		})
+	}
+
+	// 每个样本之前都应有对应指标的 # TYPE 行，且样本行不能被未转义的换行截断
+	typed := make(map[string]bool)
+	for _, line := range lines {
+		if strings.HasPrefix(line, "# TYPE ") {
+			typed[strings.Fields(line)[2]] = true
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		name := line
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name = line[:i]
+		}
+		base := name
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			if trimmed := strings.TrimSuffix(name, suffix); trimmed != name && typed[trimmed] {
+				base = trimmed
+				break
+			}
+		}
+		if !typed[base] {
+			t.Errorf("样本 %q 之前缺少 # TYPE 行", line)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+/*
+* 提供者熔断器，同一提供者的所有连接共享一个熔断器。
+* 关闭状态下统计最近若干次调用的失败率，超过阈值后打开，打开期间直接拒绝调用；
+* 打开时间到期后进入半开状态，使用健康检查的测试数据探测提供者，探测成功则关闭，失败则延长打开时间。
+* 上游返回 HTTP 429 时立即打开，打开时间优先使用 Retry-After。
+ */
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常放行
+	BreakerOpen                         // 拒绝调用，等待探测
+	BreakerHalfOpen                     // 正在探测
+)
+
+// String 返回状态名称
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerConfig 熔断器配置
+type BreakerConfig struct {
+	WindowSize      int           // 统计失败率的最近调用次数
+	MinRequests     int           // 窗口内至少多少次调用才计算失败率
+	FailureRate     float64       // 打开熔断的失败率阈值
+	OpenDuration    time.Duration // 首次打开的时长
+	MaxOpenDuration time.Duration // 探测连续失败时打开时长的上限
+	ProbeTimeout    time.Duration // 单次探测的超时时间
+}
+
+// DefaultBreakerConfig 默认熔断器配置
+var DefaultBreakerConfig = BreakerConfig{
+	WindowSize:      20,
+	MinRequests:     5,
+	FailureRate:     0.5,
+	OpenDuration:    30 * time.Second,
+	MaxOpenDuration: 5 * time.Minute,
+	ProbeTimeout:    30 * time.Second,
+}
+
+// BreakerOpenError 熔断器打开时拒绝调用返回的错误
+type BreakerOpenError struct {
+	Module     string
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *BreakerOpenError) Error() string {
+	return fmt.Sprintf("%s提供者 %s 已熔断，%v 后重试", e.Module, e.Name, e.RetryAfter.Round(time.Second))
+}
+
+// ProbeFunc 半开状态下探测提供者是否恢复
+type ProbeFunc func(ctx context.Context) error
+
+// CircuitBreaker 提供者熔断器
+type CircuitBreaker struct {
+	module string
+	name   string
+	config BreakerConfig
+	probe  ProbeFunc
+	logger *utils.Logger
+
+	mu           sync.Mutex
+	state        BreakerState
+	results      []bool // 最近调用结果的环形缓冲，true表示失败
+	next         int
+	count        int
+	failures     int
+	openUntil    time.Time
+	openDuration time.Duration // 下次探测失败后的打开时长
+	trips        int           // 累计打开次数
+	timer        *time.Timer
+	stopped      bool
+}
+
+// NewCircuitBreaker 创建熔断器，probe 为nil时打开时间到期后直接恢复
+func NewCircuitBreaker(module, name string, config BreakerConfig, probe ProbeFunc, logger *utils.Logger) *CircuitBreaker {
+	return &CircuitBreaker{
+		module:       module,
+		name:         name,
+		config:       config,
+		probe:        probe,
+		logger:       logger,
+		results:      make([]bool, config.WindowSize),
+		openDuration: config.OpenDuration,
+	}
+}
+
+// Allow 检查是否允许调用提供者，熔断器为nil时始终允许
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerClosed {
+		return nil
+	}
+	retryAfter := time.Until(b.openUntil)
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	return &BreakerOpenError{Module: b.module, Name: b.name, RetryAfter: retryAfter}
+}
+
+// Record 记录一次调用结果，err为nil表示成功
+func (b *CircuitBreaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	if err == nil {
+		b.record(false, "")
+		return
+	}
+	b.record(true, err.Error())
+}
+
+// RecordText 记录以文本形式返回的失败，如LLM响应中的"服务响应异常"内容
+func (b *CircuitBreaker) RecordText(failure string) {
+	if b == nil {
+		return
+	}
+	b.record(failure != "", failure)
+}
+
+func (b *CircuitBreaker) record(failed bool, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerClosed || b.stopped {
+		// 打开期间仍在进行的调用结果不再统计，由探测决定何时恢复
+		return
+	}
+
+	if failed {
+		if limited, retryAfter := providers.ParseRateLimit(reason); limited {
+			if retryAfter <= 0 {
+				retryAfter = b.config.OpenDuration
+			}
+			b.tripLocked(retryAfter, "上游限流: "+reason)
+			return
+		}
+	}
+
+	if b.count == len(b.results) {
+		if b.results[b.next] {
+			b.failures--
+		}
+	} else {
+		b.count++
+	}
+	b.results[b.next] = failed
+	b.next = (b.next + 1) % len(b.results)
+	if failed {
+		b.failures++
+	}
+
+	if failed && b.count >= b.config.MinRequests &&
+		float64(b.failures)/float64(b.count) >= b.config.FailureRate {
+		b.tripLocked(b.openDuration, fmt.Sprintf("最近 %d 次调用失败 %d 次，最后一次错误: %s", b.count, b.failures, reason))
+	}
+}
+
+// tripLocked 打开熔断器，到期后进入半开状态探测
+func (b *CircuitBreaker) tripLocked(d time.Duration, reason string) {
+	b.state = BreakerOpen
+	b.openUntil = time.Now().Add(d)
+	b.trips++
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timer = time.AfterFunc(d, b.halfOpen)
+	if b.logger != nil {
+		b.logger.Warn("%s提供者 %s 熔断器打开，%v 后探测: %s", b.module, b.name, d.Round(time.Second), reason)
+	}
+}
+
+// halfOpen 打开时间到期，探测提供者是否恢复
+func (b *CircuitBreaker) halfOpen() {
+	b.mu.Lock()
+	if b.stopped || b.state != BreakerOpen {
+		b.mu.Unlock()
+		return
+	}
+	b.state = BreakerHalfOpen
+	b.mu.Unlock()
+
+	var err error
+	if b.probe != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), b.config.ProbeTimeout)
+		err = b.probe(ctx)
+		cancel()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return
+	}
+	if err != nil {
+		if limited, retryAfter := providers.ParseRateLimit(err.Error()); limited && retryAfter > 0 {
+			b.tripLocked(retryAfter, "探测被限流: "+err.Error())
+			return
+		}
+		b.openDuration *= 2
+		if b.openDuration > b.config.MaxOpenDuration {
+			b.openDuration = b.config.MaxOpenDuration
+		}
+		b.tripLocked(b.openDuration, "探测失败: "+err.Error())
+		return
+	}
+
+	b.state = BreakerClosed
+	b.openDuration = b.config.OpenDuration
+	b.count, b.next, b.failures = 0, 0, 0
+	if b.logger != nil {
+		b.logger.Info("%s提供者 %s 探测成功，熔断器关闭", b.module, b.name)
+	}
+}
+
+// State 返回熔断器当前状态
+func (b *CircuitBreaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Stats 返回熔断器统计，合并到资源池的详细统计中
+func (b *CircuitBreaker) Stats() map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	retryAfter := 0
+	if b.state != BreakerClosed {
+		if d := time.Until(b.openUntil); d > 0 {
+			retryAfter = int(d.Round(time.Second).Seconds())
+		}
+	}
+	return map[string]int{
+		"breaker_state":       int(b.state),
+		"breaker_requests":    b.count,
+		"breaker_failures":    b.failures,
+		"breaker_trips":       b.trips,
+		"breaker_retry_after": retryAfter,
+	}
+}
+
+// Stop 停止熔断器的探测定时器
+func (b *CircuitBreaker) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// breakerRegistry 按 模块:提供者名称 管理共享的熔断器
+type breakerRegistry struct {
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+	newProbe func(module, name string) ProbeFunc
+	logger   *utils.Logger
+}
+
+func newBreakerRegistry(newProbe func(module, name string) ProbeFunc, logger *utils.Logger) *breakerRegistry {
+	return &breakerRegistry{
+		breakers: make(map[string]*CircuitBreaker),
+		newProbe: newProbe,
+		logger:   logger,
+	}
+}
+
+// get 获取提供者的熔断器，不存在时创建；只有ASR/LLM/TTS使用熔断器
+func (r *breakerRegistry) get(module, name string) *CircuitBreaker {
+	if r == nil || name == "" || (module != "ASR" && module != "LLM" && module != "TTS") {
+		return nil
+	}
+	key := module + ":" + name
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.breakers[key]; ok {
+		return b
+	}
+	b := NewCircuitBreaker(module, name, DefaultBreakerConfig, r.newProbe(module, name), r.logger)
+	r.breakers[key] = b
+	return b
+}
+
+// lookup 获取已存在的熔断器，不创建
+func (r *breakerRegistry) lookup(module, name string) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.breakers[module+":"+name]
+}
+
+// reset 丢弃提供者的熔断器，提供者配置变更后重新统计
+func (r *breakerRegistry) reset(module, name string) {
+	r.mu.Lock()
+	b := r.breakers[module+":"+name]
+	delete(r.breakers, module+":"+name)
+	r.mu.Unlock()
+	if b != nil {
+		b.Stop()
+	}
+}
+
+// stopAll 停止全部熔断器
+func (r *breakerRegistry) stopAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, b := range r.breakers {
+		b.Stop()
+		delete(r.breakers, key)
+	}
+}
@@ -0,0 +1,125 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+)
+
+// testBreakerConfig 打开时长足够长，测试期间定时器不会触发，由测试直接调用 halfOpen
+var testBreakerConfig = BreakerConfig{
+	WindowSize:      4,
+	MinRequests:     3,
+	FailureRate:     0.5,
+	OpenDuration:    time.Hour,
+	MaxOpenDuration: 4 * time.Hour,
+	ProbeTimeout:    time.Second,
+}
+
+func TestBreakerWindow(t *testing.T) {
+	errFailed := errors.New("调用失败")
+	tests := []struct {
+		name    string
+		results []error
+		want    BreakerState
+	}{
+		{name: "全部成功", results: []error{nil, nil, nil, nil, nil}, want: BreakerClosed},
+		{name: "未达到最少调用次数", results: []error{errFailed, errFailed}, want: BreakerClosed},
+		{name: "达到最少调用次数且失败率超过阈值", results: []error{nil, errFailed, errFailed}, want: BreakerOpen},
+		{name: "失败率低于阈值", results: []error{nil, nil, errFailed}, want: BreakerClosed},
+		{name: "刚好达到阈值", results: []error{nil, nil, errFailed, errFailed}, want: BreakerOpen},
+		{name: "旧失败移出窗口", results: []error{errFailed, nil, nil, nil, nil, nil, errFailed}, want: BreakerClosed},
+		{name: "窗口内失败累积", results: []error{nil, nil, nil, nil, errFailed, nil, errFailed}, want: BreakerOpen},
+		{name: "交替失败", results: []error{errFailed, nil, errFailed, nil}, want: BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker("LLM", "test", testBreakerConfig, nil, nil)
+			defer b.Stop()
+			for _, err := range tt.results {
+				b.Record(err)
+			}
+			if got := b.State(); got != tt.want {
+				t.Errorf("记录 %v 后状态 = %s，期望 %s", tt.results, got, tt.want)
+			}
+			if err := b.Allow(); (err != nil) != (tt.want != BreakerClosed) {
+				t.Errorf("Allow() = %v，状态 %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerRateLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantRetry time.Duration
+	}{
+		{name: "带Retry-After", err: &providers.RateLimitError{RetryAfter: 90 * time.Second}, wantRetry: 90 * time.Second},
+		{name: "无Retry-After使用默认时长", err: &providers.RateLimitError{}, wantRetry: testBreakerConfig.OpenDuration},
+		{name: "文本中的429状态码", err: errors.New("status code: 429"), wantRetry: testBreakerConfig.OpenDuration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker("TTS", "test", testBreakerConfig, nil, nil)
+			defer b.Stop()
+			// 限流不受最少调用次数限制，第一次调用即打开
+			b.Record(tt.err)
+			var openErr *BreakerOpenError
+			if err := b.Allow(); !errors.As(err, &openErr) {
+				t.Fatalf("限流后 Allow() = %v，期望熔断错误", err)
+			}
+			if openErr.RetryAfter > tt.wantRetry || openErr.RetryAfter < tt.wantRetry-time.Second {
+				t.Errorf("RetryAfter = %v，期望约 %v", openErr.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestBreakerProbeBackoff(t *testing.T) {
+	probeErr := errors.New("探测失败")
+	var results []error
+	probe := func(ctx context.Context) error {
+		err := results[0]
+		results = results[1:]
+		return err
+	}
+	b := NewCircuitBreaker("ASR", "test", testBreakerConfig, probe, nil)
+	defer b.Stop()
+
+	b.Record(&providers.RateLimitError{})
+	results = []error{probeErr, probeErr, probeErr, &providers.RateLimitError{RetryAfter: time.Minute}, nil}
+
+	// 探测连续失败时打开时长翻倍，不超过上限；探测被限流时使用 Retry-After 且不翻倍
+	wantDurations := []time.Duration{2 * time.Hour, 4 * time.Hour, 4 * time.Hour, 4 * time.Hour}
+	for i, want := range wantDurations {
+		b.halfOpen()
+		if b.State() != BreakerOpen {
+			t.Fatalf("第 %d 次探测失败后状态 = %s，期望 open", i+1, b.State())
+		}
+		b.mu.Lock()
+		got := b.openDuration
+		b.mu.Unlock()
+		if got != want {
+			t.Errorf("第 %d 次探测失败后打开时长 = %v，期望 %v", i+1, got, want)
+		}
+	}
+
+	b.halfOpen()
+	if b.State() != BreakerClosed {
+		t.Fatalf("探测成功后状态 = %s，期望 closed", b.State())
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openDuration != testBreakerConfig.OpenDuration || b.count != 0 || b.failures != 0 {
+		t.Errorf("探测成功后未重置统计: 打开时长=%v, 调用=%d, 失败=%d", b.openDuration, b.count, b.failures)
+	}
+	if b.trips != 5 {
+		t.Errorf("打开次数 = %d，期望 5", b.trips)
+	}
+}
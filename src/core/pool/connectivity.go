@@ -42,8 +42,15 @@ type ConnectivityConfig struct {
 	RetryAttempts int           `yaml:"retry_attempts"`
 	RetryDelay    time.Duration `yaml:"retry_delay"`
 	TestModes     TestModes     `yaml:"test_modes"`
+
+	Interval           time.Duration `yaml:"interval"`            // 后台基础连通性检查间隔，0表示不检查
+	FunctionalInterval time.Duration `yaml:"functional_interval"` // 后台功能性检查间隔，0表示不检查
+	FallbackText       string        `yaml:"fallback_text"`       // 必需的提供者不可用时播放的提示语
 }
 
+// DefaultFallbackText 未配置时提供者不可用的提示语
+const DefaultFallbackText = "抱歉，服务暂时不可用，请稍后再试"
+
 // TestModes 测试模式配置
 type TestModes struct {
 	ASRTestAudio  string `yaml:"asr_test_audio"`
@@ -79,6 +86,25 @@ func ConfigFromYAML(yamlConfig *configs.ConnectivityCheckConfig) (*ConnectivityC
 		retryAttempts = yamlConfig.RetryAttempts
 	}
 
+	// 解析周期检查间隔，未配置时使用默认值
+	defaults := DefaultConnectivityConfig()
+	interval := defaults.Interval
+	if yamlConfig.Interval != "" {
+		if t, err := time.ParseDuration(yamlConfig.Interval); err == nil && t >= 0 {
+			interval = t
+		}
+	}
+	functionalInterval := defaults.FunctionalInterval
+	if yamlConfig.FunctionalInterval != "" {
+		if t, err := time.ParseDuration(yamlConfig.FunctionalInterval); err == nil && t >= 0 {
+			functionalInterval = t
+		}
+	}
+	fallbackText := defaults.FallbackText
+	if yamlConfig.FallbackText != "" {
+		fallbackText = yamlConfig.FallbackText
+	}
+
 	return &ConnectivityConfig{
 		Enabled:       yamlConfig.Enabled,
 		Timeout:       timeout,
@@ -89,6 +115,9 @@ func ConfigFromYAML(yamlConfig *configs.ConnectivityCheckConfig) (*ConnectivityC
 			LLMTestPrompt: yamlConfig.TestModes.LLMTestPrompt,
 			TTSTestText:   yamlConfig.TestModes.TTSTestText,
 		},
+		Interval:           interval,
+		FunctionalInterval: functionalInterval,
+		FallbackText:       fallbackText,
 	}, nil
 }
 
@@ -104,6 +133,9 @@ func DefaultConnectivityConfig() *ConnectivityConfig {
 			LLMTestPrompt: "Hello",
 			TTSTestText:   "测试",
 		},
+		Interval:           time.Minute,
+		FunctionalInterval: 10 * time.Minute,
+		FallbackText:       DefaultFallbackText,
 	}
 }
 
@@ -188,6 +220,22 @@ func (hc *HealthChecker) CheckAllProviders(ctx context.Context, mode CheckMode)
 	return nil
 }
 
+// CheckProvider 检查单个模块(ASR/LLM/TTS/VLLLM)的指定提供者
+func (hc *HealthChecker) CheckProvider(ctx context.Context, module, name string, mode CheckMode) error {
+	switch module {
+	case "ASR":
+		return hc.checkASRProvider(ctx, name, mode)
+	case "LLM":
+		return hc.checkLLMProvider(ctx, name, mode)
+	case "TTS":
+		return hc.checkTTSProvider(ctx, name, mode)
+	case "VLLLM":
+		return hc.checkVLLLMProvider(ctx, name, mode)
+	default:
+		return fmt.Errorf("不支持的模块: %s", module)
+	}
+}
+
 // checkASRProvider 检查ASR提供者
 func (hc *HealthChecker) checkASRProvider(ctx context.Context, asrType string, mode CheckMode) error {
 	hc.logger.Info("检查ASR提供者: %s", asrType)
@@ -361,7 +409,7 @@ func (hc *HealthChecker) checkLLMProvider(ctx context.Context, llmType string, m
 		// 验证响应
 		if !hc.testGenerator.ValidateLLMResponse(responseText) {
 			result.Success = false
-			result.Error = fmt.Errorf("LLM响应验证失败: 响应内容不合理")
+			result.Error = fmt.Errorf("LLM响应验证失败: 响应内容不合理: %s", responseText)
 			result.Duration = time.Since(start)
 			hc.results["LLM"] = result
 			return result.Error
@@ -433,7 +481,7 @@ func (hc *HealthChecker) checkTTSProvider(ctx context.Context, ttsType string, m
 
 		// 执行实际的TTS测试
 		testText := hc.testGenerator.GetTestTTSText()
-		audioPath, err := ttsProvider.ToTTS(testText)
+		audioPath, err := ttsProvider.ToTTS(ctx, testText)
 		if err != nil {
 			result.Success = false
 			result.Error = fmt.Errorf("TTS合成测试失败: %v", err)
@@ -7,7 +7,9 @@ import (
 	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/asr"
 	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/providers/llm/chain"
 	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/providers/vad"
 	"xiaozhi-server-go/src/core/providers/vlllm"
 	"xiaozhi-server-go/src/core/utils"
 )
@@ -15,7 +17,7 @@ import (
 /*
 * 工厂类，用于创建不同类型的资源池工厂。
 * 通过配置文件和提供者类型，动态创建资源池工厂。
-* 支持ASR、LLM、TTS和VLLLM等多种提供者类型。
+* 支持ASR、LLM、TTS、VAD和VLLLM等多种提供者类型。
 * 每个工厂实现了ResourceFactory接口，提供Create和Destroy方法。
  */
 
@@ -56,11 +58,26 @@ func (f *ProviderFactory) createProvider() (interface{}, error) {
 	case "llm":
 		cfg := f.config.(*llm.Config)
 		return llm.Create(cfg.Type, cfg)
+	case "llm_chain":
+		cfg := f.config.(*llmChainConfig)
+		members := make([]chain.Member, 0, len(cfg.members))
+		for i, memberCfg := range cfg.members {
+			provider, err := llm.Create(memberCfg.Type, memberCfg)
+			if err != nil {
+				f.logger.Warn("组合LLM成员 %s 创建失败，已跳过: %v", cfg.names[i], err)
+				continue
+			}
+			members = append(members, chain.Member{Name: cfg.names[i], Provider: provider})
+		}
+		return chain.NewProvider(cfg.chain, members, f.logger)
 	case "tts":
 		cfg := f.config.(*tts.Config)
 		params := f.params
 		delete_audio, _ := params["delete_audio"].(bool)
 		return tts.Create(cfg.Type, cfg, delete_audio)
+	case "vad":
+		cfg := f.config.(*vad.Config)
+		return vad.Create(cfg.Type, cfg, f.logger)
 	case "vlllm":
 		cfg := f.config.(*configs.VLLMConfig)
 		return vlllm.Create(cfg.Type, cfg, f.logger)
@@ -94,24 +111,63 @@ func NewASRFactory(asrType string, config *configs.Config, logger *utils.Logger)
 
 func NewLLMFactory(llmType string, config *configs.Config, logger *utils.Logger) ResourceFactory {
 	if llmCfg, ok := config.LLM[llmType]; ok {
+		if llmCfg.Type == chain.TypeName {
+			return newLLMChainFactory(llmType, llmCfg, config, logger)
+		}
 		return &ProviderFactory{
 			providerType: "llm",
-			config: &llm.Config{
-				Type:        llmCfg.Type,
-				ModelName:   llmCfg.ModelName,
-				BaseURL:     llmCfg.BaseURL,
-				APIKey:      llmCfg.APIKey,
-				Temperature: llmCfg.Temperature,
-				MaxTokens:   llmCfg.MaxTokens,
-				TopP:        llmCfg.TopP,
-				Extra:       llmCfg.Extra,
-			},
-			logger: logger,
+			config:       toLLMConfig(llmCfg),
+			logger:       logger,
 		}
 	}
 	return nil
 }
 
+// llmChainConfig 组合LLM及其成员的配置
+type llmChainConfig struct {
+	chain   *chain.Config
+	names   []string
+	members []*llm.Config
+}
+
+// newLLMChainFactory 创建组合LLM工厂，成员必须是已配置的非组合LLM
+func newLLMChainFactory(llmType string, llmCfg configs.LLMConfig, config *configs.Config, logger *utils.Logger) ResourceFactory {
+	chainCfg, err := chain.ParseConfig(llmCfg.Extra)
+	if err != nil {
+		logger.Error("组合LLM %s 配置无效: %v", llmType, err)
+		return nil
+	}
+	cfg := &llmChainConfig{chain: chainCfg}
+	for _, name := range chainCfg.Providers {
+		memberCfg, ok := config.LLM[name]
+		if !ok || memberCfg.Type == chain.TypeName {
+			logger.Error("组合LLM %s 的成员 %s 不存在或为组合LLM", llmType, name)
+			return nil
+		}
+		cfg.names = append(cfg.names, name)
+		cfg.members = append(cfg.members, toLLMConfig(memberCfg))
+	}
+	return &ProviderFactory{
+		providerType: "llm_chain",
+		config:       cfg,
+		logger:       logger,
+	}
+}
+
+// toLLMConfig 转换为LLM提供者配置
+func toLLMConfig(llmCfg configs.LLMConfig) *llm.Config {
+	return &llm.Config{
+		Type:        llmCfg.Type,
+		ModelName:   llmCfg.ModelName,
+		BaseURL:     llmCfg.BaseURL,
+		APIKey:      llmCfg.APIKey,
+		Temperature: llmCfg.Temperature,
+		MaxTokens:   llmCfg.MaxTokens,
+		TopP:        llmCfg.TopP,
+		Extra:       llmCfg.Extra,
+	}
+}
+
 func NewTTSFactory(ttsType string, config *configs.Config, logger *utils.Logger) ResourceFactory {
 	if ttsCfg, ok := config.TTS[ttsType]; ok {
 		return &ProviderFactory{
@@ -136,6 +192,23 @@ func NewTTSFactory(ttsType string, config *configs.Config, logger *utils.Logger)
 	return nil
 }
 
+func NewVADFactory(vadType string, config *configs.Config, logger *utils.Logger) ResourceFactory {
+	if vadCfg, ok := config.VAD[vadType]; ok {
+		return &ProviderFactory{
+			providerType: "vad",
+			config: &vad.Config{
+				Type:               vadCfg.Type,
+				ModelDir:           vadCfg.ModelDir,
+				Threshold:          vadCfg.Threshold,
+				MinSilenceDuration: vadCfg.MinSilenceDuration,
+				Data:               vadCfg.Extra,
+			},
+			logger: logger,
+		}
+	}
+	return nil
+}
+
 func NewVLLLMFactory(vlllmType string, config *configs.Config, logger *utils.Logger) ResourceFactory {
 	if vlllmCfg, ok := config.VLLLM[vlllmType]; ok {
 		return &ProviderFactory{
@@ -0,0 +1,248 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+/*
+* 后台健康检查，启动后按配置的间隔周期性执行基础连通性检查和功能性检查，
+* 记录各模块提供者的健康状态供 /api/health 和连接处理使用。
+* 提供者由健康变为异常时销毁资源池中的空闲资源，之后按需重新创建。
+ */
+
+// requiredModules 任一不可用时服务无法正常对话的模块
+var requiredModules = []string{"ASR", "LLM", "TTS"}
+
+// monitoredModules 后台检查的模块，VLLLM为可选模块
+var monitoredModules = []string{"ASR", "LLM", "TTS", "VLLLM"}
+
+// ProviderHealth 模块提供者的健康状态
+type ProviderHealth struct {
+	Module              string    `json:"module"`
+	Provider            string    `json:"provider"`
+	Required            bool      `json:"required"`
+	Healthy             bool      `json:"healthy"`
+	CheckMode           string    `json:"check_mode"`
+	Error               string    `json:"error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastCheck           time.Time `json:"last_check"`
+	DurationMs          int64     `json:"duration_ms"`
+}
+
+// HealthReport 服务健康报告，live 表示进程存活，ready 表示必需的提供者均可用
+type HealthReport struct {
+	Status    string                    `json:"status"` // ok、degraded(可选模块异常)、unavailable(必需模块异常)
+	Live      bool                      `json:"live"`
+	Ready     bool                      `json:"ready"`
+	Providers map[string]ProviderHealth `json:"providers"`
+}
+
+// HealthMonitor 后台健康检查调度器
+type HealthMonitor struct {
+	pm     *PoolManager
+	logger *utils.Logger
+
+	mu      sync.RWMutex
+	status  map[string]*ProviderHealth // 键为模块名
+	started bool
+}
+
+func newHealthMonitor(pm *PoolManager, logger *utils.Logger) *HealthMonitor {
+	return &HealthMonitor{
+		pm:     pm,
+		logger: logger,
+		status: make(map[string]*ProviderHealth),
+	}
+}
+
+// Start 启动周期检查，ctx 结束时停止；未启用连通性检查或间隔均为0时不启动
+func (m *HealthMonitor) Start(ctx context.Context) {
+	connConfig := m.connConfig()
+	if !connConfig.Enabled || (connConfig.Interval <= 0 && connConfig.FunctionalInterval <= 0) {
+		m.logger.Info("后台健康检查未启用")
+		return
+	}
+
+	m.mu.Lock()
+	if m.started {
+		m.mu.Unlock()
+		return
+	}
+	m.started = true
+	m.mu.Unlock()
+
+	m.logger.Info("启动后台健康检查，基础检查间隔: %v，功能性检查间隔: %v", connConfig.Interval, connConfig.FunctionalInterval)
+	go m.run(ctx, connConfig.Interval, connConfig.FunctionalInterval)
+}
+
+func (m *HealthMonitor) run(ctx context.Context, interval, functionalInterval time.Duration) {
+	var basicC, functionalC <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		basicC = ticker.C
+	}
+	if functionalInterval > 0 {
+		ticker := time.NewTicker(functionalInterval)
+		defer ticker.Stop()
+		functionalC = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-basicC:
+			m.CheckAll(ctx, BasicCheck)
+		case <-functionalC:
+			m.CheckAll(ctx, FunctionalCheck)
+		}
+	}
+}
+
+// CheckAll 检查全部已选择的模块提供者并更新健康状态
+func (m *HealthMonitor) CheckAll(ctx context.Context, mode CheckMode) {
+	connConfig := m.connConfig()
+	connConfig.RetryAttempts = 1 // 周期检查不重试，下个周期会再次检查
+
+	for _, module := range monitoredModules {
+		name := m.pm.selectedProvider(module)
+		if name == "" {
+			continue
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, connConfig.Timeout)
+		start := time.Now()
+		err := NewHealthChecker(m.pm.currentConfig(), connConfig, m.logger).CheckProvider(checkCtx, module, name, mode)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		m.update(module, name, mode, err, time.Since(start))
+	}
+}
+
+// seed 记录启动时连通性检查的结果
+func (m *HealthMonitor) seed(results map[string]*CheckResult) {
+	for module, result := range results {
+		name := m.pm.selectedProvider(module)
+		if name == "" {
+			continue
+		}
+		m.update(module, name, result.CheckMode, result.Error, result.Duration)
+	}
+}
+
+// update 更新模块健康状态，由健康变为异常时清理资源池中的空闲资源
+func (m *HealthMonitor) update(module, name string, mode CheckMode, err error, duration time.Duration) {
+	m.mu.Lock()
+	prev, known := m.status[module]
+	wasHealthy := !known || prev.Provider != name || prev.Healthy
+	health := &ProviderHealth{
+		Module:     module,
+		Provider:   name,
+		Required:   isRequiredModule(module),
+		Healthy:    err == nil,
+		CheckMode:  checkModeName(mode),
+		LastCheck:  time.Now(),
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		health.Error = err.Error()
+		health.ConsecutiveFailures = 1
+		if known && prev.Provider == name {
+			health.ConsecutiveFailures = prev.ConsecutiveFailures + 1
+		}
+	}
+	m.status[module] = health
+	m.mu.Unlock()
+
+	switch {
+	case err != nil && wasHealthy:
+		m.logger.Error("%s提供者 %s 健康检查失败，标记为不可用: %v", module, name, err)
+		if n := m.pm.evictIdle(module); n > 0 {
+			m.logger.Warn("已销毁%s资源池中 %d 个空闲资源", module, n)
+		}
+	case err == nil && !wasHealthy:
+		m.logger.Info("%s提供者 %s 已恢复", module, name)
+	}
+}
+
+// forget 清除模块的健康状态，模块更换提供者后等待下次检查
+func (m *HealthMonitor) forget(module string) {
+	m.mu.Lock()
+	delete(m.status, module)
+	m.mu.Unlock()
+}
+
+// Healthy 判断模块提供者是否可用，尚未检查或不在检查范围内的提供者视为可用
+func (m *HealthMonitor) Healthy(module, name string) bool {
+	if m == nil {
+		return true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	health, ok := m.status[module]
+	if !ok || health.Provider != name {
+		return true
+	}
+	return health.Healthy
+}
+
+// Report 生成健康报告
+func (m *HealthMonitor) Report() HealthReport {
+	report := HealthReport{
+		Status:    "ok",
+		Live:      true,
+		Ready:     true,
+		Providers: make(map[string]ProviderHealth),
+	}
+	for _, module := range requiredModules {
+		if m.pm.selectedProvider(module) == "" {
+			report.Ready = false
+			report.Status = "unavailable"
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for module, health := range m.status {
+		report.Providers[module] = *health
+		if health.Healthy {
+			continue
+		}
+		if health.Required {
+			report.Ready = false
+			report.Status = "unavailable"
+		} else if report.Status == "ok" {
+			report.Status = "degraded"
+		}
+	}
+	return report
+}
+
+func (m *HealthMonitor) connConfig() *ConnectivityConfig {
+	connConfig, err := ConfigFromYAML(&m.pm.currentConfig().ConnectivityCheck)
+	if err != nil {
+		connConfig = DefaultConnectivityConfig()
+	}
+	return connConfig
+}
+
+func isRequiredModule(module string) bool {
+	for _, m := range requiredModules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+func checkModeName(mode CheckMode) string {
+	if mode == FunctionalCheck {
+		return "functional"
+	}
+	return "basic"
+}
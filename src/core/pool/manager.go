@@ -3,6 +3,8 @@ package pool
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 	"xiaozhi-server-go/src/configs"
 	"xiaozhi-server-go/src/core/mcp"
@@ -16,9 +18,27 @@ type PoolManager struct {
 	asrPool   *ResourcePool
 	llmPool   *ResourcePool
 	ttsPool   *ResourcePool
+	vadPool   *ResourcePool
 	vlllmPool *ResourcePool
 	mcpPool   *ResourcePool
 	logger    *utils.Logger
+
+	poolConfig PoolConfig   // ASR/LLM/TTS/VAD/VLLLM池配置，重建资源池时复用
+	mu         sync.RWMutex // 保护资源池指针，运行时重建资源池时加写锁
+
+	config    *configs.Config          // 创建时的配置，运行时读取配置使用 currentConfig
+	selected  map[string]string        // 各模块默认资源池对应的提供者名称
+	lazyPools map[string]*ResourcePool // 按需创建的非默认提供者资源池，键为 模块:提供者名称
+	breakers  *breakerRegistry         // ASR/LLM/TTS提供者熔断器，所有连接共享
+	health    *HealthMonitor           // 后台健康检查
+}
+
+// lazyPoolConfig 按需创建的提供者资源池配置，仅个别用户使用，保持较小规模
+var lazyPoolConfig = PoolConfig{
+	MinSize:       1,
+	MaxSize:       10,
+	RefillSize:    1,
+	CheckInterval: 30 * time.Second,
 }
 
 // ProviderSet 提供者集合
@@ -26,14 +46,35 @@ type ProviderSet struct {
 	ASR   providers.ASRProvider
 	LLM   providers.LLMProvider
 	TTS   providers.TTSProvider
+	VAD   providers.VADProvider
 	VLLLM *vlllm.Provider
 	MCP   *mcp.Manager
+
+	// 提供者来源的资源池，资源池被重建后旧资源归还到原池并随之销毁
+	asrPool   *ResourcePool
+	llmPool   *ResourcePool
+	ttsPool   *ResourcePool
+	vadPool   *ResourcePool
+	vlllmPool *ResourcePool
+	mcpPool   *ResourcePool
+
+	names    map[string]string // 各模块提供者名称
+	breakers *breakerRegistry
+	health   *HealthMonitor
 }
 
 // NewPoolManager 创建资源池管理器
 func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager, error) {
 	pm := &PoolManager{
-		logger: logger,
+		logger:    logger,
+		config:    config,
+		selected:  make(map[string]string),
+		lazyPools: make(map[string]*ResourcePool),
+	}
+	pm.breakers = newBreakerRegistry(pm.newProbe, logger)
+	pm.health = newHealthMonitor(pm, logger)
+	for module, name := range config.SelectedModule {
+		pm.selected[module] = name
 	}
 
 	// 执行连通性检查
@@ -47,6 +88,7 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		RefillSize:    3,
 		CheckInterval: 30 * time.Second,
 	}
+	pm.poolConfig = poolConfig
 
 	// 检查配置是否包含所需的模块
 	selectedModule := config.SelectedModule
@@ -96,6 +138,24 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		logger.Info("TTS资源池初始化成功，类型: %s, 数量：%d", ttsType, cnt)
 	}
 
+	// 初始化VAD池（可选，未配置时沿用ASR自身的静音检测）
+	if vadType, ok := selectedModule["VAD"]; ok && vadType != "" {
+		if err := checkVADSupport(pm.asrPool, selectedModule["ASR"], vadType); err != nil {
+			return nil, err
+		}
+		vadFactory := NewVADFactory(vadType, config, logger)
+		if vadFactory == nil {
+			return nil, fmt.Errorf("创建VAD工厂失败: 找不到配置 %s", vadType)
+		}
+		vadPool, err := NewResourcePool(vadFactory, poolConfig, logger)
+		if err != nil {
+			return nil, fmt.Errorf("初始化VAD资源池失败: %v", err)
+		}
+		pm.vadPool = vadPool
+		_, cnt := vadPool.GetStats()
+		logger.Info("VAD资源池初始化成功，类型: %s, 数量：%d", vadType, cnt)
+	}
+
 	// 初始化VLLLM池（可选）
 	if vlllmType, ok := selectedModule["VLLLM"]; ok && vlllmType != "" {
 		vlllmFactory := NewVLLLMFactory(vlllmType, config, logger)
@@ -144,7 +204,23 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 
 // GetProviderSet 获取一套提供者
 func (pm *PoolManager) GetProviderSet() (*ProviderSet, error) {
-	set := &ProviderSet{}
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	set := &ProviderSet{
+		asrPool:   pm.asrPool,
+		llmPool:   pm.llmPool,
+		ttsPool:   pm.ttsPool,
+		vadPool:   pm.vadPool,
+		vlllmPool: pm.vlllmPool,
+		mcpPool:   pm.mcpPool,
+		names:     make(map[string]string, len(pm.selected)),
+		breakers:  pm.breakers,
+		health:    pm.health,
+	}
+	for module, name := range pm.selected {
+		set.names[module] = name
+	}
 
 	if pm.asrPool != nil {
 		asr, err := pm.asrPool.Get()
@@ -170,6 +246,14 @@ func (pm *PoolManager) GetProviderSet() (*ProviderSet, error) {
 		set.TTS = tts.(providers.TTSProvider)
 	}
 
+	if pm.vadPool != nil {
+		vad, err := pm.vadPool.Get()
+		if err != nil {
+			return nil, fmt.Errorf("获取VAD提供者失败: %v", err)
+		}
+		set.VAD = vad.(providers.VADProvider)
+	}
+
 	if pm.vlllmPool != nil {
 		vlllmProvider, err := pm.vlllmPool.Get()
 		if err == nil {
@@ -191,6 +275,9 @@ func (pm *PoolManager) GetProviderSet() (*ProviderSet, error) {
 
 // Close 关闭所有资源池
 func (pm *PoolManager) Close() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
 	if pm.asrPool != nil {
 		pm.asrPool.Close()
 	}
@@ -200,12 +287,22 @@ func (pm *PoolManager) Close() {
 	if pm.ttsPool != nil {
 		pm.ttsPool.Close()
 	}
+	if pm.vadPool != nil {
+		pm.vadPool.Close()
+	}
 	if pm.vlllmPool != nil {
 		pm.vlllmPool.Close()
 	}
 	if pm.mcpPool != nil {
 		pm.mcpPool.Close()
 	}
+	// 关闭进程内共享的外部MCP服务
+	mcp.Shared().Stop()
+	for key, p := range pm.lazyPools {
+		p.Close()
+		delete(pm.lazyPools, key)
+	}
+	pm.breakers.stopAll()
 }
 
 // ReturnProviderSet 归还提供者集合到池中
@@ -217,13 +314,13 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	var errs []error
 
 	// 归还ASR提供者
-	if set.ASR != nil && pm.asrPool != nil {
+	if set.ASR != nil && set.asrPool != nil {
 		// 重置资源状态
-		if err := pm.asrPool.Reset(set.ASR); err != nil {
+		if err := set.asrPool.Reset(set.ASR); err != nil {
 			pm.logger.Warn("重置ASR资源状态失败: %v", err)
 		}
 		// 归还到池中
-		if err := pm.asrPool.Put(set.ASR); err != nil {
+		if err := set.asrPool.Put(set.ASR); err != nil {
 			errs = append(errs, fmt.Errorf("归还ASR提供者失败: %v", err))
 			pm.logger.Error("归还ASR提供者失败: %v", err)
 		} else {
@@ -232,11 +329,11 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	// 归还LLM提供者
-	if set.LLM != nil && pm.llmPool != nil {
-		if err := pm.llmPool.Reset(set.LLM); err != nil {
+	if set.LLM != nil && set.llmPool != nil {
+		if err := set.llmPool.Reset(set.LLM); err != nil {
 			pm.logger.Warn("重置LLM资源状态失败: %v", err)
 		}
-		if err := pm.llmPool.Put(set.LLM); err != nil {
+		if err := set.llmPool.Put(set.LLM); err != nil {
 			errs = append(errs, fmt.Errorf("归还LLM提供者失败: %v", err))
 			pm.logger.Error("归还LLM提供者失败: %v", err)
 		} else {
@@ -245,11 +342,11 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	// 归还TTS提供者
-	if set.TTS != nil && pm.ttsPool != nil {
-		if err := pm.ttsPool.Reset(set.TTS); err != nil {
+	if set.TTS != nil && set.ttsPool != nil {
+		if err := set.ttsPool.Reset(set.TTS); err != nil {
 			pm.logger.Warn("重置TTS资源状态失败: %v", err)
 		}
-		if err := pm.ttsPool.Put(set.TTS); err != nil {
+		if err := set.ttsPool.Put(set.TTS); err != nil {
 			errs = append(errs, fmt.Errorf("归还TTS提供者失败: %v", err))
 			pm.logger.Error("归还TTS提供者失败: %v", err)
 		} else {
@@ -257,12 +354,25 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 		}
 	}
 
+	// 归还VAD提供者
+	if set.VAD != nil && set.vadPool != nil {
+		if err := set.vadPool.Reset(set.VAD); err != nil {
+			pm.logger.Warn("重置VAD资源状态失败: %v", err)
+		}
+		if err := set.vadPool.Put(set.VAD); err != nil {
+			errs = append(errs, fmt.Errorf("归还VAD提供者失败: %v", err))
+			pm.logger.Error("归还VAD提供者失败: %v", err)
+		} else {
+			pm.logger.Debug("VAD提供者已成功归还到池中")
+		}
+	}
+
 	// 归还VLLLM提供者
-	if set.VLLLM != nil && pm.vlllmPool != nil {
-		if err := pm.vlllmPool.Reset(set.VLLLM); err != nil {
+	if set.VLLLM != nil && set.vlllmPool != nil {
+		if err := set.vlllmPool.Reset(set.VLLLM); err != nil {
 			pm.logger.Warn("重置VLLLM资源状态失败: %v", err)
 		}
-		if err := pm.vlllmPool.Put(set.VLLLM); err != nil {
+		if err := set.vlllmPool.Put(set.VLLLM); err != nil {
 			errs = append(errs, fmt.Errorf("归还VLLLM提供者失败: %v", err))
 			pm.logger.Error("归还VLLLM提供者失败: %v", err)
 		} else {
@@ -271,11 +381,11 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	// 归还MCP提供者
-	if set.MCP != nil && pm.mcpPool != nil {
-		if err := pm.mcpPool.Reset(set.MCP); err != nil {
+	if set.MCP != nil && set.mcpPool != nil {
+		if err := set.mcpPool.Reset(set.MCP); err != nil {
 			pm.logger.Warn("重置MCP资源状态失败: %v", err)
 		}
-		if err := pm.mcpPool.Put(set.MCP); err != nil {
+		if err := set.mcpPool.Put(set.MCP); err != nil {
 			errs = append(errs, fmt.Errorf("归还MCP提供者失败: %v", err))
 			pm.logger.Error("归还MCP提供者失败: %v", err)
 		} else {
@@ -293,6 +403,9 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 
 // GetStats 获取所有池的统计信息
 func (pm *PoolManager) GetStats() map[string]map[string]int {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
 	stats := make(map[string]map[string]int)
 
 	if pm.asrPool != nil {
@@ -310,6 +423,11 @@ func (pm *PoolManager) GetStats() map[string]map[string]int {
 		stats["tts"] = map[string]int{"available": available, "total": total}
 	}
 
+	if pm.vadPool != nil {
+		available, total := pm.vadPool.GetStats()
+		stats["vad"] = map[string]int{"available": available, "total": total}
+	}
+
 	if pm.vlllmPool != nil {
 		available, total := pm.vlllmPool.GetStats()
 		stats["vlllm"] = map[string]int{"available": available, "total": total}
@@ -320,6 +438,11 @@ func (pm *PoolManager) GetStats() map[string]map[string]int {
 		stats["mcp"] = map[string]int{"available": available, "total": total}
 	}
 
+	for key, p := range pm.lazyPools {
+		available, total := p.GetStats()
+		stats[strings.ToLower(key)] = map[string]int{"available": available, "total": total}
+	}
+
 	return stats
 }
 
@@ -343,33 +466,398 @@ func (pm *PoolManager) performConnectivityCheck(config *configs.Config, logger *
 
 	// 打印检查报告
 	healthChecker.PrintReport()
+	pm.health.seed(healthChecker.GetResults())
 
 	return err
 }
 
 // GetDetailedStats 获取所有池的详细统计信息
 func (pm *PoolManager) GetDetailedStats() map[string]map[string]int {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
 	stats := make(map[string]map[string]int)
 
 	if pm.asrPool != nil {
-		stats["asr"] = pm.asrPool.GetDetailedStats()
+		stats["asr"] = pm.withBreakerStats(pm.asrPool.GetDetailedStats(), "ASR", pm.selected["ASR"])
 	}
 
 	if pm.llmPool != nil {
-		stats["llm"] = pm.llmPool.GetDetailedStats()
+		stats["llm"] = pm.withBreakerStats(pm.llmPool.GetDetailedStats(), "LLM", pm.selected["LLM"])
 	}
 
 	if pm.ttsPool != nil {
-		stats["tts"] = pm.ttsPool.GetDetailedStats()
+		stats["tts"] = pm.withBreakerStats(pm.ttsPool.GetDetailedStats(), "TTS", pm.selected["TTS"])
+	}
+
+	if pm.vadPool != nil {
+		stats["vad"] = pm.vadPool.GetDetailedStats()
 	}
 
 	if pm.vlllmPool != nil {
-		stats["vlllm"] = pm.vlllmPool.GetDetailedStats()
+		stats["vlllm"] = pm.withBreakerStats(pm.vlllmPool.GetDetailedStats(), "VLLLM", pm.selected["VLLLM"])
 	}
 
 	if pm.mcpPool != nil {
 		stats["mcp"] = pm.mcpPool.GetDetailedStats()
 	}
 
+	for key, p := range pm.lazyPools {
+		module, name, _ := strings.Cut(key, ":")
+		stats[strings.ToLower(key)] = pm.withBreakerStats(p.GetDetailedStats(), module, name)
+	}
+
 	return stats
 }
+
+// withBreakerStats 将提供者熔断器状态合并到资源池统计中，
+// breaker_state 为 0 关闭、1 打开、2 半开，breaker_retry_after 为距下次探测的秒数
+func (pm *PoolManager) withBreakerStats(stats map[string]int, module, name string) map[string]int {
+	if b := pm.breakers.lookup(module, name); b != nil {
+		for k, v := range b.Stats() {
+			stats[k] = v
+		}
+	}
+	stats["healthy"] = 0
+	if pm.health.Healthy(module, name) {
+		stats["healthy"] = 1
+	}
+	return stats
+}
+
+// currentConfig 返回当前生效的配置快照，未设置时使用创建时的配置
+func (pm *PoolManager) currentConfig() *configs.Config {
+	if config := configs.Current(); config != nil {
+		return config
+	}
+	return pm.config
+}
+
+// newProbe 创建熔断器的探测函数，使用健康检查的测试数据对提供者做一次功能性检查
+func (pm *PoolManager) newProbe(module, name string) ProbeFunc {
+	return func(ctx context.Context) error {
+		config := pm.currentConfig()
+		connConfig, err := ConfigFromYAML(&config.ConnectivityCheck)
+		if err != nil {
+			connConfig = DefaultConnectivityConfig()
+		}
+		probeConfig := *connConfig
+		probeConfig.Enabled = true
+		probeConfig.RetryAttempts = 1
+		if deadline, ok := ctx.Deadline(); ok {
+			probeConfig.Timeout = time.Until(deadline)
+		}
+		return NewHealthChecker(config, &probeConfig, pm.logger).CheckProvider(ctx, module, name, FunctionalCheck)
+	}
+}
+
+// newModuleFactory 创建指定模块和提供者名称的资源工厂
+func (pm *PoolManager) newModuleFactory(module, name string, config *configs.Config) (ResourceFactory, error) {
+	var factory ResourceFactory
+	switch module {
+	case "ASR":
+		factory = NewASRFactory(name, config, pm.logger)
+	case "LLM":
+		factory = NewLLMFactory(name, config, pm.logger)
+	case "TTS":
+		factory = NewTTSFactory(name, config, pm.logger)
+	case "VAD":
+		factory = NewVADFactory(name, config, pm.logger)
+	case "VLLLM":
+		factory = NewVLLLMFactory(name, config, pm.logger)
+	default:
+		return nil, fmt.Errorf("不支持的模块: %s", module)
+	}
+	if factory == nil {
+		return nil, fmt.Errorf("创建%s工厂失败: 找不到配置 %s", module, name)
+	}
+	return factory, nil
+}
+
+// RebuildPool 按新配置重建指定模块(ASR/LLM/TTS/VAD/VLLLM)的资源池
+// 新池创建成功后才替换旧池，使用中的旧资源归还时随旧池销毁
+func (pm *PoolManager) RebuildPool(module string, config *configs.Config) error {
+	name := config.SelectedModule[module]
+
+	var newPool *ResourcePool
+	if name != "" {
+		factory, err := pm.newModuleFactory(module, name, config)
+		if err != nil {
+			return err
+		}
+		newPool, err = NewResourcePool(factory, pm.poolConfig, pm.logger)
+		if err != nil {
+			return fmt.Errorf("初始化%s资源池失败: %v", module, err)
+		}
+	} else if module != "VAD" && module != "VLLLM" {
+		return fmt.Errorf("%s模块必须选择提供者", module)
+	}
+
+	// 切换ASR或启用VAD后，VAD仍需ASR支持主动结束语句
+	var vadErr error
+	switch module {
+	case "ASR":
+		vadErr = checkVADSupport(newPool, name, pm.selectedProvider("VAD"))
+	case "VAD":
+		pm.mu.RLock()
+		asrPool := pm.asrPool
+		pm.mu.RUnlock()
+		vadErr = checkVADSupport(asrPool, pm.selectedProvider("ASR"), name)
+	}
+	if vadErr != nil {
+		if newPool != nil {
+			newPool.Close()
+		}
+		return vadErr
+	}
+
+	pm.mu.Lock()
+	var oldPool *ResourcePool
+	switch module {
+	case "ASR":
+		oldPool, pm.asrPool = pm.asrPool, newPool
+	case "LLM":
+		oldPool, pm.llmPool = pm.llmPool, newPool
+	case "TTS":
+		oldPool, pm.ttsPool = pm.ttsPool, newPool
+	case "VAD":
+		oldPool, pm.vadPool = pm.vadPool, newPool
+	case "VLLLM":
+		oldPool, pm.vlllmPool = pm.vlllmPool, newPool
+	}
+	pm.selected[module] = name
+	lazyPool := pm.lazyPools[module+":"+name]
+	delete(pm.lazyPools, module+":"+name)
+	pm.mu.Unlock()
+
+	pm.breakers.reset(module, name)
+	pm.health.forget(module)
+
+	if oldPool != nil {
+		oldPool.Close()
+	}
+	if lazyPool != nil {
+		lazyPool.Close()
+	}
+	pm.logger.Info("%s资源池已重建，类型: %s", module, name)
+	return nil
+}
+
+// checkVADSupport 服务端VAD检测到语句结束后需要通知ASR给出最终结果，ASR不支持主动结束语句时拒绝启用VAD
+func checkVADSupport(asrPool *ResourcePool, asrName, vadName string) error {
+	if vadName == "" {
+		return nil
+	}
+	if asrPool == nil {
+		return fmt.Errorf("VAD %s 需要同时配置ASR", vadName)
+	}
+	resource, err := asrPool.Get()
+	if err != nil {
+		return fmt.Errorf("检查ASR %s 是否支持VAD失败: %v", asrName, err)
+	}
+	defer asrPool.Put(resource)
+	if _, ok := resource.(providers.ASRFinalizer); !ok {
+		return fmt.Errorf("VAD %s 需要支持主动结束语句的ASR，当前ASR %s 不支持，请移除 selected_module.VAD 或更换ASR", vadName, asrName)
+	}
+	return nil
+}
+
+// StartHealthMonitor 启动后台健康检查，ctx 结束时停止
+func (pm *PoolManager) StartHealthMonitor(ctx context.Context) {
+	pm.health.Start(ctx)
+}
+
+// HealthReport 返回各模块提供者的健康报告
+func (pm *PoolManager) HealthReport() HealthReport {
+	return pm.health.Report()
+}
+
+// selectedProvider 返回模块默认资源池对应的提供者名称
+func (pm *PoolManager) selectedProvider(module string) string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.selected[module]
+}
+
+// evictIdle 销毁模块默认资源池中的空闲资源
+func (pm *PoolManager) evictIdle(module string) int {
+	pm.mu.RLock()
+	var p *ResourcePool
+	switch module {
+	case "ASR":
+		p = pm.asrPool
+	case "LLM":
+		p = pm.llmPool
+	case "TTS":
+		p = pm.ttsPool
+	case "VLLLM":
+		p = pm.vlllmPool
+	}
+	pm.mu.RUnlock()
+	if p == nil {
+		return 0
+	}
+	return p.Evict()
+}
+
+// ResetProviderPool 关闭指定提供者按需创建的资源池，提供者配置变更后下次使用时按新配置重建
+func (pm *PoolManager) ResetProviderPool(module, name string) {
+	pm.mu.Lock()
+	lazyPool := pm.lazyPools[module+":"+name]
+	delete(pm.lazyPools, module+":"+name)
+	pm.mu.Unlock()
+	pm.breakers.reset(module, name)
+
+	if lazyPool != nil {
+		lazyPool.Close()
+	}
+}
+
+// providerPool 获取指定提供者的资源池，非默认提供者按需创建
+func (pm *PoolManager) providerPool(module, name string) (*ResourcePool, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if pm.selected[module] == name {
+		var p *ResourcePool
+		switch module {
+		case "ASR":
+			p = pm.asrPool
+		case "LLM":
+			p = pm.llmPool
+		case "TTS":
+			p = pm.ttsPool
+		case "VAD":
+			p = pm.vadPool
+		case "VLLLM":
+			p = pm.vlllmPool
+		}
+		if p == nil {
+			return nil, fmt.Errorf("%s资源池未初始化", module)
+		}
+		return p, nil
+	}
+
+	key := module + ":" + name
+	if p, ok := pm.lazyPools[key]; ok {
+		return p, nil
+	}
+	// 使用当前配置快照，运行时修改的提供者配置在资源池重置后生效
+	factory, err := pm.newModuleFactory(module, name, pm.currentConfig())
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewResourcePool(factory, lazyPoolConfig, pm.logger)
+	if err != nil {
+		return nil, fmt.Errorf("初始化%s资源池失败: %v", key, err)
+	}
+	pm.lazyPools[key] = p
+	pm.logger.Info("按需创建资源池: %s", key)
+	return p, nil
+}
+
+// SwapProvider 将提供者集合中指定模块替换为指定名称的提供者，原提供者归还到来源资源池
+func (pm *PoolManager) SwapProvider(set *ProviderSet, module, name string) error {
+	if set == nil {
+		return fmt.Errorf("提供者集合为空")
+	}
+	if name == "" || set.names[module] == name {
+		return nil
+	}
+
+	p, err := pm.providerPool(module, name)
+	if err != nil {
+		return err
+	}
+	resource, err := p.Get()
+	if err != nil {
+		return fmt.Errorf("获取%s提供者 %s 失败: %v", module, name, err)
+	}
+
+	var old interface{}
+	var oldPool *ResourcePool
+	switch module {
+	case "ASR":
+		provider, ok := resource.(providers.ASRProvider)
+		if !ok {
+			p.Put(resource)
+			return fmt.Errorf("%s提供者 %s 类型错误", module, name)
+		}
+		if set.ASR != nil {
+			old, oldPool = set.ASR, set.asrPool
+		}
+		set.ASR, set.asrPool = provider, p
+	case "LLM":
+		provider, ok := resource.(providers.LLMProvider)
+		if !ok {
+			p.Put(resource)
+			return fmt.Errorf("%s提供者 %s 类型错误", module, name)
+		}
+		if set.LLM != nil {
+			old, oldPool = set.LLM, set.llmPool
+		}
+		set.LLM, set.llmPool = provider, p
+	case "TTS":
+		provider, ok := resource.(providers.TTSProvider)
+		if !ok {
+			p.Put(resource)
+			return fmt.Errorf("%s提供者 %s 类型错误", module, name)
+		}
+		if set.TTS != nil {
+			old, oldPool = set.TTS, set.ttsPool
+		}
+		set.TTS, set.ttsPool = provider, p
+	case "VAD":
+		provider, ok := resource.(providers.VADProvider)
+		if !ok {
+			p.Put(resource)
+			return fmt.Errorf("%s提供者 %s 类型错误", module, name)
+		}
+		if set.VAD != nil {
+			old, oldPool = set.VAD, set.vadPool
+		}
+		set.VAD, set.vadPool = provider, p
+	case "VLLLM":
+		provider, ok := resource.(*vlllm.Provider)
+		if !ok {
+			p.Put(resource)
+			return fmt.Errorf("%s提供者 %s 类型错误", module, name)
+		}
+		if set.VLLLM != nil {
+			old, oldPool = set.VLLLM, set.vlllmPool
+		}
+		set.VLLLM, set.vlllmPool = provider, p
+	default:
+		p.Put(resource)
+		return fmt.Errorf("不支持的模块: %s", module)
+	}
+	if set.names == nil {
+		set.names = make(map[string]string)
+	}
+	set.names[module] = name
+
+	if old != nil && oldPool != nil {
+		if err := oldPool.Reset(old); err != nil {
+			pm.logger.Warn("重置%s资源状态失败: %v", module, err)
+		}
+		if err := oldPool.Put(old); err != nil {
+			pm.logger.Warn("归还%s提供者失败: %v", module, err)
+		}
+	}
+	return nil
+}
+
+// ProviderName 返回提供者集合中指定模块的提供者名称
+func (set *ProviderSet) ProviderName(module string) string {
+	return set.names[module]
+}
+
+// Breaker 返回提供者集合中指定模块当前提供者的熔断器，不使用熔断器的模块返回nil
+func (set *ProviderSet) Breaker(module string) *CircuitBreaker {
+	return set.breakers.get(module, set.names[module])
+}
+
+// Healthy 判断提供者集合中指定模块的提供者是否通过了最近一次健康检查
+func (set *ProviderSet) Healthy(module string) bool {
+	return set.health.Healthy(module, set.names[module])
+}
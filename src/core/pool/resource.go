@@ -34,6 +34,8 @@ type ResourcePool struct {
 	logger      *utils.Logger
 	ctx         context.Context
 	cancel      context.CancelFunc
+	closeMutex  sync.RWMutex // 保护池通道关闭，避免归还资源时向已关闭的通道写入
+	closed      bool
 }
 
 // PoolConfig 资源池配置
@@ -134,6 +136,12 @@ func (p *ResourcePool) refillPool(refillSize int) {
 				continue
 			}
 
+			p.closeMutex.RLock()
+			if p.closed {
+				p.closeMutex.RUnlock()
+				p.factory.Destroy(resource)
+				return
+			}
 			select {
 			case p.pool <- resource:
 				p.mutex.Lock()
@@ -143,14 +151,22 @@ func (p *ResourcePool) refillPool(refillSize int) {
 				// 池满了，销毁资源
 				p.factory.Destroy(resource)
 			}
+			p.closeMutex.RUnlock()
 		}
 	}
 }
 
 // Close 关闭资源池
 func (p *ResourcePool) Close() {
+	p.closeMutex.Lock()
+	if p.closed {
+		p.closeMutex.Unlock()
+		return
+	}
+	p.closed = true
 	p.cancel()
 	close(p.pool)
+	p.closeMutex.Unlock()
 
 	// 销毁剩余资源
 	for resource := range p.pool {
@@ -165,10 +181,10 @@ func (p *ResourcePool) Put(resource interface{}) error {
 	}
 
 	// 检查池是否已关闭
-	select {
-	case <-p.ctx.Done():
+	p.closeMutex.RLock()
+	defer p.closeMutex.RUnlock()
+	if p.closed {
 		return p.factory.Destroy(resource)
-	default:
 	}
 
 	// 设置归还超时
@@ -220,3 +236,31 @@ func (p *ResourcePool) GetDetailedStats() map[string]int {
 		"in_use":    p.currentSize - len(p.pool),
 	}
 }
+
+// Evict 销毁池中全部空闲资源，返回销毁的数量；提供者异常时丢弃可能已失效的资源，之后按需重新创建
+func (p *ResourcePool) Evict() int {
+	p.closeMutex.RLock()
+	defer p.closeMutex.RUnlock()
+	if p.closed {
+		return 0
+	}
+
+	evicted := 0
+	for {
+		select {
+		case resource := <-p.pool:
+			p.mutex.Lock()
+			p.currentSize--
+			p.mutex.Unlock()
+			if err := p.Reset(resource); err != nil {
+				p.logger.Warn("重置资源状态失败: %v", err)
+			}
+			if err := p.factory.Destroy(resource); err != nil {
+				p.logger.Warn("销毁资源失败: %v", err)
+			}
+			evicted++
+		default:
+			return evicted
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // TestDataGenerator 测试数据生成器
@@ -115,7 +116,7 @@ func (tdg *TestDataGenerator) ValidateLLMResponse(response string) bool {
 	}
 
 	// LLM应该能正常回复，不应该返回错误信息
-	return true
+	return !strings.Contains(response, "服务响应异常")
 }
 
 // ValidateTTSResponse 验证TTS响应是否合理
@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/asr"
 	"xiaozhi-server-go/src/core/utils"
 
@@ -76,6 +77,7 @@ type Provider struct {
 	result      string
 	err         error
 	connMutex   sync.Mutex // 添加互斥锁保护连接状态
+	finalized   bool       // 当前语句是否已发送结束包，等待最终结果
 
 	sendDataCnt int // 计数器，用于跟踪发送的音频数据包数量
 }
@@ -342,6 +344,14 @@ func (p *Provider) AddAudioWithContext(ctx context.Context, data []byte) error {
 		}
 	}
 
+	// 已发送结束包的语句不再追加音频，等待服务端返回最终结果
+	p.connMutex.Lock()
+	finalized := p.finalized
+	p.connMutex.Unlock()
+	if finalized {
+		return nil
+	}
+
 	// 检查是否有实际数据需要发送
 	if len(data) > 0 && p.isStreaming {
 		// 直接发送音频数据
@@ -374,6 +384,7 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 	p.InitAudioProcessing()
 	p.result = ""
 	p.err = nil
+	p.finalized = false
 
 	// 确保旧连接已关闭
 	if p.conn != nil {
@@ -402,6 +413,10 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 		if err == nil {
 			break
 		}
+		// 被限流时立即返回，重试只会加重限流
+		if rateErr := providers.NewRateLimitError(resp); rateErr != nil {
+			return fmt.Errorf("WebSocket连接失败: %v", rateErr)
+		}
 
 		if i < maxRetries {
 			backoffTime := time.Duration(500*(i+1)) * time.Millisecond
@@ -643,6 +658,18 @@ func (p *Provider) sendAudioData(data []byte, isLast bool) error {
 	return nil
 }
 
+// Finalize 发送结束包，通知服务端当前语句已结束并返回最终结果
+func (p *Provider) Finalize() error {
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+
+	if !p.isStreaming || p.finalized {
+		return nil
+	}
+	p.finalized = true
+	return p.sendAudioData(nil, true)
+}
+
 // Reset 重置ASR状态
 func (p *Provider) Reset() error {
 	// 使用锁保护状态变更
@@ -650,6 +677,7 @@ func (p *Provider) Reset() error {
 	defer p.connMutex.Unlock()
 
 	p.isStreaming = false
+	p.finalized = false
 	p.closeConnection()
 
 	p.reqID = ""
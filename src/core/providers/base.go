@@ -33,16 +33,43 @@ type ASRProvider interface {
 	ResetStartListenTime()
 }
 
+// ASRFinalizer 支持主动结束当前语句的ASR提供者（可选实现）
+type ASRFinalizer interface {
+	// 通知ASR当前语句已结束，尽快返回最终识别结果
+	Finalize() error
+}
+
+// VADProvider 语音活动检测提供者接口
+type VADProvider interface {
+	Provider
+	// 处理一段16bit单声道PCM音频，返回该段是否包含人声
+	ProcessAudio(pcm []byte) (bool, error)
+	// 当前语句是否已检测到人声
+	HasVoice() bool
+	// 检测到人声后，静音时长是否已达到语句结束阈值
+	IsSpeechEnd() bool
+	// 复位VAD状态
+	Reset() error
+}
+
 // TTSProvider 语音合成提供者接口
 type TTSProvider interface {
 	Provider
 
-	// 合成音频并返回文件路径
-	ToTTS(text string) (string, error)
+	// 合成音频并返回文件路径，ctx 取消时中止合成
+	ToTTS(ctx context.Context, text string) (string, error)
 
 	SetVoice(voice string) error
 }
 
+// StreamingTTSProvider 支持流式合成的TTS提供者（可选实现）
+type StreamingTTSProvider interface {
+	TTSProvider
+
+	// 流式合成音频，边合成边将音频帧(opus或pcm，单声道60ms一帧)写入frames，合成结束后返回
+	ToTTSStream(ctx context.Context, text string, format string, sampleRate int, frames chan<- []byte) error
+}
+
 // LLMProvider 大语言模型提供者接口
 type LLMProvider interface {
 	types.LLMProvider
@@ -0,0 +1,329 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+* 多LLM组合提供者，按配置顺序依次尝试各个LLM，
+* 在首个有效内容返回前遇到连接错误、超时或"服务响应异常"标记时切换到下一个，
+* 并可按路由规则为不同请求调整尝试顺序（如带工具的请求优先使用OpenAI，闲聊使用Ollama）。
+ */
+
+// TypeName 组合LLM在配置中的类型名
+const TypeName = "chain"
+
+// defaultFirstTokenTimeout 未配置时等待首个有效内容的超时时间
+const defaultFirstTokenTimeout = 10 * time.Second
+
+// errorMarker 各LLM提供者在响应内容中表示服务异常的标记
+const errorMarker = "服务响应异常"
+
+// Rule 路由规则，所有已配置的条件都满足时优先使用指定的LLM
+type Rule struct {
+	Provider   string   `yaml:"provider"`    // 优先使用的LLM名称
+	WithTools  *bool    `yaml:"with_tools"`  // 请求是否携带工具定义
+	ToolResult *bool    `yaml:"tool_result"` // 最后一条消息是否为工具调用结果
+	Keywords   []string `yaml:"keywords"`    // 最后一条用户消息包含任一关键词
+}
+
+// Config 组合LLM配置，来自LLM配置项中 type 为 chain 的扩展字段
+type Config struct {
+	Providers         []string `yaml:"providers"`           // 按优先级排列的LLM名称
+	FirstTokenTimeout string   `yaml:"first_token_timeout"` // 等待首个有效内容的超时时间，如 10s
+	Routes            []Rule   `yaml:"routes"`
+}
+
+// ParseConfig 从LLM配置的扩展字段解析组合LLM配置
+func ParseConfig(extra map[string]interface{}) (*Config, error) {
+	data, err := yaml.Marshal(extra)
+	if err != nil {
+		return nil, fmt.Errorf("解析组合LLM配置失败: %v", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("解析组合LLM配置失败: %v", err)
+	}
+	if len(cfg.Providers) == 0 {
+		return nil, fmt.Errorf("组合LLM未配置 providers")
+	}
+	if cfg.FirstTokenTimeout != "" {
+		if _, err := time.ParseDuration(cfg.FirstTokenTimeout); err != nil {
+			return nil, fmt.Errorf("first_token_timeout 格式错误: %v", err)
+		}
+	}
+	return &cfg, nil
+}
+
+// Member 组合中的一个LLM
+type Member struct {
+	Name     string
+	Provider llm.Provider
+}
+
+// Provider 组合LLM提供者
+type Provider struct {
+	members           []Member
+	routes            []Rule
+	firstTokenTimeout time.Duration
+	logger            *utils.Logger
+}
+
+// NewProvider 创建组合LLM提供者，members 按配置的优先级排列
+func NewProvider(cfg *Config, members []Member, logger *utils.Logger) (*Provider, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("组合LLM没有可用的成员")
+	}
+	timeout := defaultFirstTokenTimeout
+	if cfg.FirstTokenTimeout != "" {
+		if d, err := time.ParseDuration(cfg.FirstTokenTimeout); err == nil && d > 0 {
+			timeout = d
+		}
+	}
+	return &Provider{
+		members:           members,
+		routes:            cfg.Routes,
+		firstTokenTimeout: timeout,
+		logger:            logger,
+	}, nil
+}
+
+// Initialize 初始化提供者，成员在创建时已初始化
+func (p *Provider) Initialize() error {
+	return nil
+}
+
+// Cleanup 清理全部成员
+func (p *Provider) Cleanup() error {
+	var errs []string
+	for _, m := range p.members {
+		if err := m.Provider.Cleanup(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", m.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("清理组合LLM失败: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	out := make(chan string, 10)
+	go func() {
+		defer close(out)
+		var reasons []string
+		for _, m := range p.order(messages, false) {
+			attemptCtx, cancel := context.WithCancel(ctx)
+			ch, err := m.Provider.Response(attemptCtx, sessionID, messages)
+			if err != nil {
+				cancel()
+				reasons = append(reasons, p.failover(m.Name, err.Error()))
+				continue
+			}
+			buffered, reason := awaitFirst(ctx, ch, p.firstTokenTimeout, func(content string) (bool, string) {
+				if strings.Contains(content, errorMarker) {
+					return false, content
+				}
+				return content != "", ""
+			})
+			if reason != "" {
+				cancel()
+				drain(ch)
+				if ctx.Err() != nil {
+					return
+				}
+				reasons = append(reasons, p.failover(m.Name, reason))
+				continue
+			}
+
+			forward(ctx, out, buffered, ch, cancel)
+			return
+		}
+		out <- fmt.Sprintf("【组合LLM服务响应异常: %s】", strings.Join(reasons, "; "))
+	}()
+	return out, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	out := make(chan types.Response, 10)
+	go func() {
+		defer close(out)
+		var reasons []string
+		for _, m := range p.order(messages, len(tools) > 0) {
+			attemptCtx, cancel := context.WithCancel(ctx)
+			ch, err := m.Provider.ResponseWithFunctions(attemptCtx, sessionID, messages, tools)
+			if err != nil {
+				cancel()
+				reasons = append(reasons, p.failover(m.Name, err.Error()))
+				continue
+			}
+			buffered, reason := awaitFirst(ctx, ch, p.firstTokenTimeout, func(resp types.Response) (bool, string) {
+				if resp.Error != "" {
+					return false, resp.Error
+				}
+				if strings.Contains(resp.Content, errorMarker) {
+					return false, resp.Content
+				}
+				return resp.Content != "" || len(resp.ToolCalls) > 0, ""
+			})
+			if reason != "" {
+				cancel()
+				drain(ch)
+				if ctx.Err() != nil {
+					return
+				}
+				reasons = append(reasons, p.failover(m.Name, reason))
+				continue
+			}
+
+			forward(ctx, out, buffered, ch, cancel)
+			return
+		}
+		reason := strings.Join(reasons, "; ")
+		out <- types.Response{
+			Content: fmt.Sprintf("【组合LLM服务响应异常: %s】", reason),
+			Error:   reason,
+		}
+	}()
+	return out, nil
+}
+
+// order 按路由规则确定本次请求尝试LLM的顺序，命中规则的LLM排在最前，其余按配置顺序兜底
+func (p *Provider) order(messages []types.Message, withTools bool) []Member {
+	preferred := ""
+	for _, rule := range p.routes {
+		if rule.matches(messages, withTools) {
+			preferred = rule.Provider
+			break
+		}
+	}
+	if preferred == "" {
+		return p.members
+	}
+
+	ordered := make([]Member, 0, len(p.members))
+	for _, m := range p.members {
+		if m.Name == preferred {
+			ordered = append(ordered, m)
+		}
+	}
+	for _, m := range p.members {
+		if m.Name != preferred {
+			ordered = append(ordered, m)
+		}
+	}
+	return ordered
+}
+
+// failover 记录切换原因并返回用于汇总的描述
+func (p *Provider) failover(name, reason string) string {
+	if p.logger != nil {
+		p.logger.Warn("LLM %s 不可用，尝试下一个: %s", name, reason)
+	}
+	return fmt.Sprintf("%s: %s", name, reason)
+}
+
+// matches 判断请求是否满足规则的全部条件，未配置条件的规则匹配所有请求
+func (r Rule) matches(messages []types.Message, withTools bool) bool {
+	if r.WithTools != nil && *r.WithTools != withTools {
+		return false
+	}
+	if r.ToolResult != nil {
+		isToolResult := len(messages) > 0 && messages[len(messages)-1].Role == "tool"
+		if *r.ToolResult != isToolResult {
+			return false
+		}
+	}
+	if len(r.Keywords) > 0 {
+		text := lastUserMessage(messages)
+		for _, keyword := range r.Keywords {
+			if keyword != "" && strings.Contains(text, keyword) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func lastUserMessage(messages []types.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// awaitFirst 等待首个有效内容，期间的空内容缓存后随首个有效内容一起返回。
+// reason 不为空表示需要切换到下一个LLM：响应异常、超时或未返回任何有效内容
+func awaitFirst[T any](ctx context.Context, ch <-chan T, timeout time.Duration, check func(T) (usable bool, failure string)) ([]T, string) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var buffered []T
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return nil, "未返回有效内容"
+			}
+			usable, failure := check(v)
+			if failure != "" {
+				return nil, failure
+			}
+			buffered = append(buffered, v)
+			if usable {
+				return buffered, ""
+			}
+		case <-timer.C:
+			return nil, fmt.Sprintf("%v 内未返回首个有效内容", timeout)
+		case <-ctx.Done():
+			return nil, ctx.Err().Error()
+		}
+	}
+}
+
+// forward 将选中成员的响应转发给调用方，调用方放弃读取时取消成员请求并丢弃剩余响应
+func forward[T any](ctx context.Context, out chan<- T, buffered []T, ch <-chan T, cancel context.CancelFunc) {
+	defer cancel()
+	send := func(v T) bool {
+		select {
+		case out <- v:
+			return true
+		case <-ctx.Done():
+			drain(ch)
+			return false
+		}
+	}
+	for _, v := range buffered {
+		if !send(v) {
+			return
+		}
+	}
+	for v := range ch {
+		if !send(v) {
+			return
+		}
+	}
+}
+
+// drain 丢弃被放弃的响应，避免成员的发送协程阻塞
+func drain[T any](ch <-chan T) {
+	go func() {
+		for range ch {
+		}
+	}()
+}
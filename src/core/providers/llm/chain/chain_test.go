@@ -0,0 +1,232 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeLLM 按预设内容依次返回流式响应的LLM，可在发送前等待
+type fakeLLM struct {
+	contents  []string
+	delay     time.Duration
+	err       error
+	ignoreCtx bool          // 发送内容时不检查上下文，模拟阻塞在发送上的提供者
+	done      chan struct{} // 发送协程退出后关闭
+}
+
+func (f *fakeLLM) Initialize() error { return nil }
+func (f *fakeLLM) Cleanup() error    { return nil }
+
+func (f *fakeLLM) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		if f.done != nil {
+			defer close(f.done)
+		}
+		if f.delay > 0 {
+			select {
+			case <-time.After(f.delay):
+			case <-ctx.Done():
+				return
+			}
+		}
+		for _, content := range f.contents {
+			if f.ignoreCtx {
+				ch <- content
+				continue
+			}
+			select {
+			case ch <- content:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (f *fakeLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	contents, err := f.Response(ctx, sessionID, messages)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan types.Response)
+	go func() {
+		defer close(ch)
+		for content := range contents {
+			ch <- types.Response{Content: content}
+		}
+	}()
+	return ch, nil
+}
+
+func boolPtr(v bool) *bool { return &v }
+
+func TestRuleMatches(t *testing.T) {
+	chat := []types.Message{{Role: "system", Content: "你是小智"}, {Role: "user", Content: "帮我查一下天气"}}
+	toolResult := append(chat, types.Message{Role: "tool", Content: "晴"})
+
+	tests := []struct {
+		name      string
+		rule      Rule
+		messages  []types.Message
+		withTools bool
+		want      bool
+	}{
+		{name: "无条件匹配所有请求", rule: Rule{}, messages: chat, want: true},
+		{name: "需要工具且携带工具", rule: Rule{WithTools: boolPtr(true)}, messages: chat, withTools: true, want: true},
+		{name: "需要工具但未携带", rule: Rule{WithTools: boolPtr(true)}, messages: chat, want: false},
+		{name: "不需要工具", rule: Rule{WithTools: boolPtr(false)}, messages: chat, want: true},
+		{name: "工具结果", rule: Rule{ToolResult: boolPtr(true)}, messages: toolResult, want: true},
+		{name: "非工具结果", rule: Rule{ToolResult: boolPtr(true)}, messages: chat, want: false},
+		{name: "空消息不是工具结果", rule: Rule{ToolResult: boolPtr(false)}, messages: nil, want: true},
+		{name: "关键词命中", rule: Rule{Keywords: []string{"新闻", "天气"}}, messages: chat, want: true},
+		{name: "关键词未命中", rule: Rule{Keywords: []string{"新闻"}}, messages: chat, want: false},
+		{name: "关键词匹配最后一条用户消息", rule: Rule{Keywords: []string{"天气"}}, messages: toolResult, want: true},
+		{name: "空关键词不匹配", rule: Rule{Keywords: []string{""}}, messages: chat, want: false},
+		{name: "全部条件满足", rule: Rule{WithTools: boolPtr(true), Keywords: []string{"天气"}}, messages: chat, withTools: true, want: true},
+		{name: "部分条件不满足", rule: Rule{WithTools: boolPtr(false), Keywords: []string{"天气"}}, messages: chat, withTools: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(tt.messages, tt.withTools); got != tt.want {
+				t.Errorf("matches() = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	p := &Provider{
+		members: []Member{{Name: "openai"}, {Name: "ollama"}, {Name: "doubao"}},
+		routes: []Rule{
+			{Provider: "doubao", Keywords: []string{"新闻"}},
+			{Provider: "ollama", WithTools: boolPtr(false)},
+			{Provider: "missing", Keywords: []string{"不存在"}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		withTools bool
+		want      []string
+	}{
+		{name: "未命中规则按配置顺序", text: "你好", withTools: true, want: []string{"openai", "ollama", "doubao"}},
+		{name: "命中第一条规则", text: "播报新闻", withTools: true, want: []string{"doubao", "openai", "ollama"}},
+		{name: "命中第二条规则", text: "你好", want: []string{"ollama", "openai", "doubao"}},
+		{name: "规则按顺序优先", text: "播报新闻", want: []string{"doubao", "openai", "ollama"}},
+		{name: "规则指定的LLM不存在", text: "不存在", withTools: true, want: []string{"openai", "ollama", "doubao"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.order([]types.Message{{Role: "user", Content: tt.text}}, tt.withTools)
+			if len(got) != len(tt.want) {
+				t.Fatalf("order() 返回 %d 个成员，期望 %d 个", len(got), len(tt.want))
+			}
+			for i, m := range got {
+				if m.Name != tt.want[i] {
+					t.Fatalf("order() 第 %d 个为 %s，期望 %v", i, m.Name, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAwaitFirst(t *testing.T) {
+	check := func(content string) (bool, string) {
+		if content == errorMarker {
+			return false, content
+		}
+		return content != "", ""
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		contents     []string
+		delay        time.Duration
+		wantBuffered []string
+		wantFailover bool
+	}{
+		{name: "首个内容有效", ctx: context.Background(), contents: []string{"你好", "世界"}, wantBuffered: []string{"你好"}},
+		{name: "空内容随首个有效内容返回", ctx: context.Background(), contents: []string{"", "", "你好"}, wantBuffered: []string{"", "", "你好"}},
+		{name: "响应异常", ctx: context.Background(), contents: []string{"", errorMarker}, wantFailover: true},
+		{name: "未返回有效内容", ctx: context.Background(), contents: []string{"", ""}, wantFailover: true},
+		{name: "首个内容超时", ctx: context.Background(), contents: []string{"你好"}, delay: time.Second, wantFailover: true},
+		{name: "调用方已取消", ctx: cancelled, contents: []string{"你好"}, delay: time.Second, wantFailover: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ch, _ := (&fakeLLM{contents: tt.contents, delay: tt.delay}).Response(ctx, "test", nil)
+			buffered, reason := awaitFirst(tt.ctx, ch, 50*time.Millisecond, check)
+			if (reason != "") != tt.wantFailover {
+				t.Fatalf("awaitFirst() reason = %q，期望切换 %v", reason, tt.wantFailover)
+			}
+			if len(buffered) != len(tt.wantBuffered) {
+				t.Fatalf("awaitFirst() 缓存 %q，期望 %q", buffered, tt.wantBuffered)
+			}
+			for i := range buffered {
+				if buffered[i] != tt.wantBuffered[i] {
+					t.Fatalf("awaitFirst() 缓存 %q，期望 %q", buffered, tt.wantBuffered)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseFailover(t *testing.T) {
+	p, _ := NewProvider(&Config{FirstTokenTimeout: "50ms"}, []Member{
+		{Name: "broken", Provider: &fakeLLM{err: errors.New("连接失败")}},
+		{Name: "slow", Provider: &fakeLLM{contents: []string{"太慢"}, delay: time.Second}},
+		{Name: "marker", Provider: &fakeLLM{contents: []string{"【" + errorMarker + "】"}}},
+		{Name: "ok", Provider: &fakeLLM{contents: []string{"", "你好", "世界"}}},
+	}, nil)
+
+	ch, _ := p.Response(context.Background(), "test", nil)
+	var got string
+	for content := range ch {
+		got += content
+	}
+	if got != "你好世界" {
+		t.Errorf("Response() = %q，期望切换到可用的LLM并返回 %q", got, "你好世界")
+	}
+}
+
+// TestResponseAbandoned 调用方取消并放弃读取后，转发协程应退出并丢弃成员的剩余响应
+func TestResponseAbandoned(t *testing.T) {
+	member := &fakeLLM{contents: make([]string, 100), ignoreCtx: true, done: make(chan struct{})}
+	for i := range member.contents {
+		member.contents[i] = "内容"
+	}
+	p, _ := NewProvider(&Config{}, []Member{{Name: "ok", Provider: member}}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, _ := p.Response(ctx, "test", nil)
+	<-ch
+	cancel()
+
+	// 不再读取输出通道，成员的发送协程仍应结束
+	select {
+	case <-member.done:
+	case <-time.After(time.Second):
+		t.Fatal("调用方放弃读取后成员的响应未被丢弃")
+	}
+}
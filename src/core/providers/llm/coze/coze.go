@@ -9,6 +9,7 @@ import (
 	"github.com/sashabaranov/go-openai"
 	"io"
 	"sync"
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/llm"
 	"xiaozhi-server-go/src/core/types"
 )
@@ -95,7 +96,7 @@ func (p *Provider) Initialize() error {
 		// 个人测试
 		authCli = coze.NewTokenAuth(p.accessToken)
 	}
-	p.client = coze.NewCozeAPI(authCli, coze.WithBaseURL(baseURL))
+	p.client = coze.NewCozeAPI(authCli, coze.WithBaseURL(baseURL), coze.WithHttpClient(providers.NewRateLimitHTTPClient()))
 	return nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/llm"
 	"xiaozhi-server-go/src/core/types"
 
@@ -60,6 +61,7 @@ func (p *Provider) Initialize() error {
 	clientConfig := openai.DefaultConfig("ollama")
 	clientConfig.BaseURL = baseURL
 
+	clientConfig.HTTPClient = providers.NewRateLimitHTTPClient()
 	p.client = openai.NewClientWithConfig(clientConfig)
 	return nil
 }
@@ -221,6 +223,10 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 								Arguments: tc.Function.Arguments,
 							},
 						}
+						// 并行工具调用的参数分多段返回，按序号归并
+						if tc.Index != nil {
+							toolCalls[i].Index = *tc.Index
+						}
 					}
 					responseChan <- types.Response{
 						ToolCalls: toolCalls,
@@ -3,6 +3,7 @@ package openai
 import (
 	"context"
 	"fmt"
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/llm"
 	"xiaozhi-server-go/src/core/types"
 
@@ -47,6 +48,7 @@ func (p *Provider) Initialize() error {
 		clientConfig.BaseURL = config.BaseURL
 	}
 
+	clientConfig.HTTPClient = providers.NewRateLimitHTTPClient()
 	p.client = openai.NewClientWithConfig(clientConfig)
 	return nil
 }
@@ -190,6 +192,10 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 								Arguments: tc.Function.Arguments,
 							},
 						}
+						// 并行工具调用的参数分多段返回，按序号归并
+						if tc.Index != nil {
+							toolCalls[i].Index = *tc.Index
+						}
 					}
 					chunk.ToolCalls = toolCalls
 				}
@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitError 上游服务返回 HTTP 429 时的错误，携带服务端建议的等待时间
+type RateLimitError struct {
+	RetryAfter time.Duration // 服务端 Retry-After 建议的等待时间，未提供时为0
+}
+
+// Error 错误文本中包含 retry-after 字段，错误被各提供者转换为文本后仍可被熔断器识别
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("上游服务限流(HTTP 429)，retry-after=%ds", int(e.RetryAfter.Seconds()))
+}
+
+// NewRateLimitError 响应为 HTTP 429 时返回限流错误，否则返回nil
+func NewRateLimitError(resp *http.Response) error {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	return &RateLimitError{RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"))}
+}
+
+// ParseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式，无法解析时返回0
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+var (
+	retryAfterPattern = regexp.MustCompile(`retry-after=(\d+)s`)
+	statusCodePattern = regexp.MustCompile(`(?i)(status code|状态码)[:：]?\s*429\b`)
+)
+
+// ParseRateLimit 从错误文本中识别限流，返回是否限流及建议的等待时间
+func ParseRateLimit(text string) (bool, time.Duration) {
+	if m := retryAfterPattern.FindStringSubmatch(text); m != nil {
+		seconds, _ := strconv.Atoi(m[1])
+		return true, time.Duration(seconds) * time.Second
+	}
+	if statusCodePattern.MatchString(text) || strings.Contains(text, "Too Many Requests") {
+		return true, 0
+	}
+	return false, 0
+}
+
+// rateLimitTransport 将 HTTP 429 响应转换为 RateLimitError，保留 Retry-After 信息
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip http.RoundTripper接口实现
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if rateErr := NewRateLimitError(resp); rateErr != nil {
+		resp.Body.Close()
+		return nil, rateErr
+	}
+	return resp, nil
+}
+
+// NewRateLimitHTTPClient 创建能识别 HTTP 429 的客户端，供基于HTTP的SDK使用
+func NewRateLimitHTTPClient() *http.Client {
+	return &http.Client{Transport: &rateLimitTransport{base: http.DefaultTransport}}
+}
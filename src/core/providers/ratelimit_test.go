@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{name: "空值", value: "", min: 0, max: 0},
+		{name: "秒数", value: "30", min: 30 * time.Second, max: 30 * time.Second},
+		{name: "带空白的秒数", value: " 5 ", min: 5 * time.Second, max: 5 * time.Second},
+		{name: "负数", value: "-1", min: 0, max: 0},
+		{name: "无法解析", value: "soon", min: 0, max: 0},
+		{name: "HTTP日期", value: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+		{name: "过去的HTTP日期", value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), min: 0, max: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseRetryAfter(tt.value)
+			if got < tt.min || got > tt.max {
+				t.Errorf("ParseRetryAfter(%q) = %v，期望在 [%v, %v] 之间", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		wantLimited bool
+		wantRetry   time.Duration
+	}{
+		{name: "RateLimitError文本", text: (&RateLimitError{RetryAfter: 12 * time.Second}).Error(), wantLimited: true, wantRetry: 12 * time.Second},
+		{name: "包装后的RateLimitError", text: errors.Join(errors.New("LLM调用失败"), &RateLimitError{}).Error(), wantLimited: true, wantRetry: 0},
+		{name: "英文状态码", text: "error, status code: 429, message: rate limited", wantLimited: true},
+		{name: "中文状态码", text: "请求失败，状态码：429", wantLimited: true},
+		{name: "Too Many Requests", text: "429 Too Many Requests", wantLimited: true},
+		{name: "其他状态码", text: "status code: 500", wantLimited: false},
+		{name: "状态码前缀不匹配", text: "status code: 4290", wantLimited: false},
+		{name: "普通错误", text: "connection refused", wantLimited: false},
+		{name: "空文本", text: "", wantLimited: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limited, retryAfter := ParseRateLimit(tt.text)
+			if limited != tt.wantLimited || retryAfter != tt.wantRetry {
+				t.Errorf("ParseRateLimit(%q) = (%v, %v)，期望 (%v, %v)", tt.text, limited, retryAfter, tt.wantLimited, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestNewRateLimitError(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"7"}}}
+	var rateErr *RateLimitError
+	if err := NewRateLimitError(resp); !errors.As(err, &rateErr) || rateErr.RetryAfter != 7*time.Second {
+		t.Errorf("NewRateLimitError(429) = %v，期望 retry-after=7s", err)
+	}
+	if err := NewRateLimitError(&http.Response{StatusCode: http.StatusOK}); err != nil {
+		t.Errorf("NewRateLimitError(200) = %v，期望 nil", err)
+	}
+	if err := NewRateLimitError(nil); err != nil {
+		t.Errorf("NewRateLimitError(nil) = %v，期望 nil", err)
+	}
+}
@@ -3,6 +3,7 @@ package doubao
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -13,7 +14,9 @@ import (
 	"path/filepath"
 	"time"
 
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -43,6 +46,9 @@ var (
 // reserved data: 0x00 (1 byte)
 var defaultHeader = []byte{0x11, 0x10, 0x11, 0x00}
 
+// Ensure Provider implements providers.StreamingTTSProvider interface
+var _ providers.StreamingTTSProvider = (*Provider)(nil)
+
 type synResp struct {
 	Audio  []byte
 	IsLast bool
@@ -66,15 +72,121 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 }
 
 // ToTTS 实现文本到语音的转换
-func (p *Provider) ToTTS(text string) (string, error) {
-	// 创建WebSocket连接
-	header := http.Header{"Authorization": []string{fmt.Sprintf("Bearer;%s", p.Config().Token)}}
-	conn, _, err := websocket.DefaultDialer.Dial(p.baseURL, header)
+func (p *Provider) ToTTS(ctx context.Context, text string) (string, error) {
+	conn, err := p.submit(ctx, text, nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// 上下文取消时关闭连接，以打断阻塞中的读取
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// 创建临时文件
+	outputDir := p.Config().OutputDir
+	if outputDir == "" {
+		outputDir = "tmp"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	tempFile := filepath.Join(outputDir, fmt.Sprintf("doubao_tts_%d.mp3", time.Now().UnixNano()))
+	var audioData []byte
+
+	// 接收音频数据
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			return "", fmt.Errorf("接收响应失败: %v", err)
+		}
+
+		resp, err := p.parseResponse(message)
+		if err != nil {
+			return "", fmt.Errorf("解析响应失败: %v", err)
+		}
+
+		audioData = append(audioData, resp.Audio...)
+		if resp.IsLast {
+			break
+		}
+	}
+
+	// 写入音频文件
+	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+
+	return tempFile, nil
+}
+
+// ToTTSStream 流式合成音频，请求PCM编码的音频，分片到达后立即切帧输出
+func (p *Provider) ToTTSStream(ctx context.Context, text string, format string, sampleRate int, frames chan<- []byte) error {
+	frameStream, err := utils.NewAudioFrameStream(ctx, format, sampleRate, frames)
+	if err != nil {
+		return err
+	}
+	defer frameStream.Close()
+
+	conn, err := p.submit(ctx, text, map[string]interface{}{"encoding": "pcm", "rate": sampleRate})
 	if err != nil {
-		return "", fmt.Errorf("连接WebSocket服务器失败: %v", err)
+		return err
 	}
 	defer conn.Close()
 
+	// 上下文取消时关闭连接，以打断阻塞中的读取
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("接收响应失败: %v", err)
+		}
+
+		resp, err := p.parseResponse(message)
+		if err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+
+		if err := frameStream.Write(resp.Audio); err != nil {
+			return err
+		}
+		if resp.IsLast {
+			return frameStream.Flush()
+		}
+	}
+}
+
+// submit 建立WebSocket连接并提交合成请求，audioParams会覆盖默认的音频参数
+func (p *Provider) submit(ctx context.Context, text string, audioParams map[string]interface{}) (*websocket.Conn, error) {
+	header := http.Header{"Authorization": []string{fmt.Sprintf("Bearer;%s", p.Config().Token)}}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, p.baseURL, header)
+	if err != nil {
+		if rateErr := providers.NewRateLimitError(resp); rateErr != nil {
+			err = rateErr
+		}
+		return nil, fmt.Errorf("连接WebSocket服务器失败: %v", err)
+	}
+
+	audio := map[string]interface{}{
+		"voice_type":   p.Config().Voice,
+		"encoding":     "mp3",
+		"speed_ratio":  1.0,
+		"volume_ratio": 1.0,
+		"pitch_ratio":  1.0,
+	}
+	for k, v := range audioParams {
+		audio[k] = v
+	}
+
 	// 准备请求参数
 	reqParams := map[string]map[string]interface{}{
 		"app": {
@@ -85,13 +197,7 @@ func (p *Provider) ToTTS(text string) (string, error) {
 		"user": {
 			"uid": "uid",
 		},
-		"audio": {
-			"voice_type":   p.Config().Voice,
-			"encoding":     "mp3",
-			"speed_ratio":  1.0,
-			"volume_ratio": 1.0,
-			"pitch_ratio":  1.0,
-		},
+		"audio": audio,
 		"request": {
 			"reqid":     uuid.New().String(),
 			"text":      text,
@@ -103,13 +209,15 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// 序列化并压缩请求参数
 	jsonData, err := json.Marshal(reqParams)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求参数失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
 	}
 
 	var b bytes.Buffer
 	w := gzip.NewWriter(&b)
 	if _, err := w.Write(jsonData); err != nil {
-		return "", fmt.Errorf("压缩请求数据失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("压缩请求数据失败: %v", err)
 	}
 	w.Close()
 	compressed := b.Bytes()
@@ -124,45 +232,11 @@ func (p *Provider) ToTTS(text string) (string, error) {
 
 	// 发送请求
 	if err := conn.WriteMessage(websocket.BinaryMessage, request); err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
-	}
-
-	// 创建临时文件
-	outputDir := p.Config().OutputDir
-	if outputDir == "" {
-		outputDir = "tmp"
-	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", fmt.Errorf("创建输出目录失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("发送请求失败: %v", err)
 	}
 
-	tempFile := filepath.Join(outputDir, fmt.Sprintf("doubao_tts_%d.mp3", time.Now().UnixNano()))
-	var audioData []byte
-
-	// 接收音频数据
-	for {
-		_, message, err := conn.ReadMessage()
-		if err != nil {
-			return "", fmt.Errorf("接收响应失败: %v", err)
-		}
-
-		resp, err := p.parseResponse(message)
-		if err != nil {
-			return "", fmt.Errorf("解析响应失败: %v", err)
-		}
-
-		audioData = append(audioData, resp.Audio...)
-		if resp.IsLast {
-			break
-		}
-	}
-
-	// 写入音频文件
-	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
-		return "", fmt.Errorf("写入音频文件失败: %v", err)
-	}
-
-	return tempFile, nil
+	return conn, nil
 }
 
 // parseResponse 解析服务器响应
@@ -1,15 +1,35 @@
 package edge
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/wujunwei928/edge-tts-go/edge_tts"
 )
 
+const (
+	defaultVoice  = "zh-CN-XiaoxiaoNeural"
+	outputFormat  = "audio-24khz-48kbitrate-mono-mp3"
+	streamTimeout = 10 * time.Second // 流式合成单条消息的读取超时
+)
+
+// Ensure Provider implements providers.StreamingTTSProvider interface
+var _ providers.StreamingTTSProvider = (*Provider)(nil)
+
 // Provider Edge TTS提供者实现
 type Provider struct {
 	*tts.BaseProvider
@@ -25,13 +45,10 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 
 // ToTTS 将文本转换为音频文件，并返回文件路径
 // 使用的edge库是github.com/wujunwei928/edge-tts-go，默认使用24k采样率
-func (p *Provider) ToTTS(text string) (string, error) {
+func (p *Provider) ToTTS(ctx context.Context, text string) (string, error) {
 	// 获取配置的声音，如果未配置则使用默认值
 	edgeTTSStartTime := time.Now()
-	voice := p.BaseProvider.Config().Voice
-	if voice == "" {
-		voice = "zh-CN-XiaoxiaoNeural" // 默认声音
-	}
+	voice := p.voice()
 
 	// 创建临时文件路径用于保存 edgeTTS 生成的 MP3
 	outputDir := p.BaseProvider.Config().OutputDir
@@ -56,10 +73,25 @@ func (p *Provider) ToTTS(text string) (string, error) {
 		return "", fmt.Errorf("创建 edge-tts-go Communicate 失败: %v", err)
 	}
 
-	// 获取音频流数据
-	audioData, err := conn.Stream()
-	if err != nil {
-		return "", fmt.Errorf("edge-tts-go 获取音频流失败: %v", err)
+	// 获取音频流数据，edge-tts-go 不支持取消，ctx 取消时直接返回并丢弃结果
+	type streamResult struct {
+		data []byte
+		err  error
+	}
+	resultCh := make(chan streamResult, 1)
+	go func() {
+		data, err := conn.Stream()
+		resultCh <- streamResult{data, err}
+	}()
+	var audioData []byte
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case result := <-resultCh:
+		if result.err != nil {
+			return "", fmt.Errorf("edge-tts-go 获取音频流失败: %v", result.err)
+		}
+		audioData = result.data
 	}
 
 	ttsDuration := time.Since(edgeTTSStartTime)
@@ -82,6 +114,141 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	return tempFile, nil
 }
 
+// voice 获取配置的声音，未配置时使用默认值
+func (p *Provider) voice() string {
+	if voice := p.BaseProvider.Config().Voice; voice != "" {
+		return voice
+	}
+	return defaultVoice
+}
+
+// ToTTSStream 流式合成音频，MP3数据到达后立即解码并切帧输出
+// edge-tts-go 的 Stream 方法会等待整段音频合成完毕，这里直接按其协议读取websocket消息
+func (p *Provider) ToTTSStream(ctx context.Context, text string, format string, sampleRate int, frames chan<- []byte) error {
+	frameStream, err := utils.NewAudioFrameStream(ctx, format, sampleRate, frames)
+	if err != nil {
+		return err
+	}
+	defer frameStream.Close()
+
+	header := http.Header{}
+	for k, v := range edge_tts.WSS_HEADERS {
+		header.Set(k, v)
+	}
+	connectionID := strings.ReplaceAll(uuid.New().String(), "-", "")
+	reqURL := fmt.Sprintf("%s&Sec-MS-GEC=%s&Sec-MS-GEC-Version=%s&ConnectionId=%s",
+		edge_tts.WSS_URL, edge_tts.GenerateSecMSGec(), edge_tts.SEC_MS_GEC_VERSION, connectionID)
+
+	dialer := websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  streamTimeout,
+		EnableCompression: true,
+	}
+	conn, resp, err := dialer.DialContext(ctx, reqURL, header)
+	if err != nil {
+		if rateErr := providers.NewRateLimitError(resp); rateErr != nil {
+			err = rateErr
+		}
+		return fmt.Errorf("连接edge-tts服务失败: %v", err)
+	}
+	defer conn.Close()
+
+	// 上下文取消时关闭连接，以打断阻塞中的读取
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(speechConfigMessage())); err != nil {
+		return fmt.Errorf("发送edge-tts配置失败: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(ssmlMessage(text, p.voice()))); err != nil {
+		return fmt.Errorf("发送edge-tts合成请求失败: %v", err)
+	}
+
+	// 网络读取与MP3解码并行进行，通过管道衔接
+	pr, pw := io.Pipe()
+	decodeDone := make(chan error, 1)
+	go func() {
+		err := utils.DecodeMP3Stream(pr, sampleRate, frameStream.Write)
+		if err == nil {
+			err = frameStream.Flush()
+		}
+		pr.CloseWithError(err)
+		decodeDone <- err
+	}()
+
+	readErr := readAudioMessages(ctx, conn, pw)
+	pw.CloseWithError(readErr)
+	decodeErr := <-decodeDone
+	if readErr != nil {
+		return readErr
+	}
+	return decodeErr
+}
+
+// readAudioMessages 读取edge-tts的音频消息并写入w，收到turn.end时结束
+func readAudioMessages(ctx context.Context, conn *websocket.Conn, w io.Writer) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(streamTimeout))
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("读取edge-tts音频流失败: %v", err)
+		}
+
+		switch messageType {
+		case websocket.TextMessage:
+			if bytes.Contains(data, []byte("Path:turn.end")) {
+				return nil
+			}
+		case websocket.BinaryMessage:
+			if len(data) < 2 {
+				return fmt.Errorf("edge-tts音频消息缺少头部长度")
+			}
+			headerLength := int(binary.BigEndian.Uint16(data[:2]))
+			if len(data) < headerLength+2 {
+				return fmt.Errorf("edge-tts音频消息缺少音频数据")
+			}
+			if _, err := w.Write(data[2+headerLength:]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// speechConfigMessage 构造合成参数配置消息
+func speechConfigMessage() string {
+	return fmt.Sprintf("X-Timestamp:%s\r\n"+
+		"Content-Type:application/json; charset=utf-8\r\n"+
+		"Path:speech.config\r\n\r\n"+
+		`{"context":{"synthesis":{"audio":{"metadataoptions":{`+
+		`"sentenceBoundaryEnabled":"false","wordBoundaryEnabled":"false"},`+
+		`"outputFormat":"%s"}}}}`+"\r\n", timestamp(), outputFormat)
+}
+
+// ssmlMessage 构造SSML合成请求消息
+func ssmlMessage(text string, voice string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(text))
+	ssml := fmt.Sprintf("<speak version='1.0' xmlns='http://www.w3.org/2001/10/synthesis' xml:lang='en-US'>"+
+		"<voice name='%s'><prosody pitch='+0Hz' rate='+0%%' volume='+0%%'>%s</prosody></voice></speak>",
+		voice, escaped.String())
+	return fmt.Sprintf("X-RequestId:%s\r\n"+
+		"Content-Type:application/ssml+xml\r\n"+
+		"X-Timestamp:%sZ\r\n"+
+		"Path:ssml\r\n\r\n"+
+		"%s", strings.ReplaceAll(uuid.New().String(), "-", ""), timestamp(), ssml)
+}
+
+// timestamp 生成JavaScript风格的时间字符串
+func timestamp() string {
+	return time.Now().UTC().Format("Mon Jan 02 2006 15:04:05 GMT+0000 (Coordinated Universal Time)")
+}
+
 func init() {
 	// 注册Edge TTS提供者
 	tts.Register("edge", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 	"xiaozhi-server-go/src/core/providers/tts"
 
@@ -14,6 +15,7 @@ import (
 // Provider Sherpa TTS提供者实现
 type Provider struct {
 	*tts.BaseProvider
+	mu   sync.Mutex // 多个句子并行合成时串行使用同一连接
 	conn *websocket.Conn
 }
 
@@ -36,7 +38,11 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 }
 
 // ToTTS 将文本转换为音频文件，并返回文件路径
-func (p *Provider) ToTTS(text string) (string, error) {
+// 合成共用一个连接，请求发出后无法中止，只在开始前检查 ctx
+func (p *Provider) ToTTS(ctx context.Context, text string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	// 获取配置的声音，如果未配置则使用默认值
 	SherpaTTSStartTime := time.Now()
 
@@ -52,8 +58,10 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// Use a unique filename
 	tempFile := filepath.Join(outputDir, fmt.Sprintf("go_sherpa_tts_%d.wav", time.Now().UnixNano()))
 
+	p.mu.Lock()
 	p.conn.WriteMessage(websocket.TextMessage, []byte(text))
 	_, bytes, err := p.conn.ReadMessage()
+	p.mu.Unlock()
 
 	if err != nil {
 		return "", fmt.Errorf("go-sherpa-tts 获取音频流失败: %v", err)
@@ -0,0 +1,47 @@
+package energy
+
+import (
+	"xiaozhi-server-go/src/core/providers/vad"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const defaultThreshold = 0.01 // 默认能量阈值，与ASR内置静音检测保持一致
+
+// Ensure Provider implements vad.Provider interface
+var _ vad.Provider = (*Provider)(nil)
+
+// Provider 基于短时能量的VAD实现
+type Provider struct {
+	*vad.BaseProvider
+	threshold float64
+	logger    *utils.Logger
+}
+
+// NewProvider 创建能量VAD提供者
+func NewProvider(config *vad.Config, logger *utils.Logger) (*Provider, error) {
+	threshold := config.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	return &Provider{
+		BaseProvider: vad.NewBaseProvider(config),
+		threshold:    threshold,
+		logger:       logger,
+	}, nil
+}
+
+// ProcessAudio 计算整段音频的RMS能量，超过阈值即认为包含人声
+func (p *Provider) ProcessAudio(pcm []byte) (bool, error) {
+	if len(pcm) < 2 {
+		return false, nil
+	}
+	isVoice := vad.RMS(vad.PCMToSamples(pcm)) >= p.threshold
+	p.UpdateState(isVoice, p.DurationMs(pcm))
+	return isVoice, nil
+}
+
+func init() {
+	vad.Register("energy", func(config *vad.Config, logger *utils.Logger) (vad.Provider, error) {
+		return NewProvider(config, logger)
+	})
+}
@@ -0,0 +1,191 @@
+package vad
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultSampleRate         = 16000 // 客户端上行音频默认采样率
+	defaultMinSilenceDuration = 800   // 默认语句结束静音时长(ms)
+	defaultMinSpeechDuration  = 100   // 默认判定为有效人声的最短时长(ms)
+)
+
+// Config VAD配置结构
+type Config struct {
+	Type               string
+	ModelDir           string
+	Threshold          float64
+	MinSilenceDuration int // 语句结束静音时长(ms)
+	SampleRate         int
+	Data               map[string]interface{}
+}
+
+// Provider VAD提供者接口
+type Provider interface {
+	providers.VADProvider
+}
+
+// BaseProvider VAD基础实现，负责人声/静音时长统计与语句结束判断
+type BaseProvider struct {
+	config *Config
+
+	minSilenceDuration int // 语句结束静音时长(ms)
+	minSpeechDuration  int // 有效人声最短时长(ms)
+
+	mu        sync.Mutex
+	hasVoice  bool // 当前语句是否已检测到有效人声
+	voiceMs   int  // 连续人声时长(ms)
+	silenceMs int  // 检测到人声后的连续静音时长(ms)
+}
+
+// NewBaseProvider 创建VAD基础提供者
+func NewBaseProvider(config *Config) *BaseProvider {
+	if config.SampleRate <= 0 {
+		config.SampleRate = defaultSampleRate
+	}
+	if config.MinSilenceDuration <= 0 {
+		config.MinSilenceDuration = defaultMinSilenceDuration
+	}
+	return &BaseProvider{
+		config:             config,
+		minSilenceDuration: config.MinSilenceDuration,
+		minSpeechDuration:  GetIntOption(config.Data, "min_speech_duration_ms", defaultMinSpeechDuration),
+	}
+}
+
+// Config 获取配置
+func (p *BaseProvider) Config() *Config {
+	return p.config
+}
+
+// Initialize 初始化提供者
+func (p *BaseProvider) Initialize() error {
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *BaseProvider) Cleanup() error {
+	return nil
+}
+
+// UpdateState 根据一段音频的检测结果更新语句状态
+func (p *BaseProvider) UpdateState(isVoice bool, durationMs int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if isVoice {
+		p.voiceMs += durationMs
+		p.silenceMs = 0
+		if p.voiceMs >= p.minSpeechDuration {
+			p.hasVoice = true
+		}
+		return
+	}
+
+	if p.hasVoice {
+		p.silenceMs += durationMs
+	} else {
+		// 尚未形成有效人声，短促的噪声不累计
+		p.voiceMs = 0
+	}
+}
+
+// HasVoice 当前语句是否已检测到人声
+func (p *BaseProvider) HasVoice() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hasVoice
+}
+
+// IsSpeechEnd 检测到人声后静音时长是否达到阈值
+func (p *BaseProvider) IsSpeechEnd() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hasVoice && p.silenceMs >= p.minSilenceDuration
+}
+
+// Reset 复位语句状态
+func (p *BaseProvider) Reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hasVoice = false
+	p.voiceMs = 0
+	p.silenceMs = 0
+	return nil
+}
+
+// DurationMs 计算16bit单声道PCM数据的时长(ms)
+func (p *BaseProvider) DurationMs(pcm []byte) int {
+	return len(pcm) / 2 * 1000 / p.config.SampleRate
+}
+
+// PCMToSamples 将16bit小端PCM数据转换为[-1, 1]区间的采样值
+func PCMToSamples(pcm []byte) []float64 {
+	samples := make([]float64, len(pcm)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768.0
+	}
+	return samples
+}
+
+// RMS 计算采样值的均方根能量
+func RMS(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// GetIntOption 从扩展配置中读取整数选项
+func GetIntOption(data map[string]interface{}, key string, defaultValue int) int {
+	switch v := data[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return defaultValue
+}
+
+// Factory VAD工厂函数类型
+type Factory func(config *Config, logger *utils.Logger) (Provider, error)
+
+var (
+	factories = make(map[string]Factory)
+)
+
+// Register 注册VAD提供者工厂
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// Create 创建VAD提供者实例
+func Create(name string, config *Config, logger *utils.Logger) (Provider, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的VAD提供者: %s", name)
+	}
+
+	provider, err := factory(config, logger)
+	if err != nil {
+		return nil, fmt.Errorf("创建VAD提供者失败: %v", err)
+	}
+
+	if err := provider.Initialize(); err != nil {
+		return nil, fmt.Errorf("初始化VAD提供者失败: %v", err)
+	}
+
+	return provider, nil
+}
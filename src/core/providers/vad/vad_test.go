@@ -0,0 +1,175 @@
+package vad_test
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+
+	"xiaozhi-server-go/src/core/providers/vad"
+	"xiaozhi-server-go/src/core/providers/vad/energy"
+	"xiaozhi-server-go/src/core/providers/vad/webrtc"
+)
+
+const (
+	sampleRate     = 16000
+	chunkMs        = 60 // 客户端每包音频时长
+	minSilenceMs   = 400
+	speechDuration = 600
+)
+
+type segment struct {
+	voice bool
+	ms    int
+}
+
+func TestBaseProviderSpeechEnd(t *testing.T) {
+	tests := []struct {
+		name         string
+		segments     []segment
+		wantHasVoice bool
+		wantEnd      bool
+	}{
+		{name: "只有静音", segments: []segment{{false, 2000}}, wantHasVoice: false, wantEnd: false},
+		{name: "短促噪声不算人声", segments: []segment{{true, 60}, {false, 1000}}, wantHasVoice: false, wantEnd: false},
+		{name: "噪声间隔的静音会清零", segments: []segment{{true, 60}, {false, 20}, {true, 60}, {false, 1000}}, wantHasVoice: false, wantEnd: false},
+		{name: "人声后静音不足", segments: []segment{{true, 300}, {false, 300}}, wantHasVoice: true, wantEnd: false},
+		{name: "人声后静音达到阈值", segments: []segment{{true, 300}, {false, 400}}, wantHasVoice: true, wantEnd: true},
+		{name: "停顿后继续说话", segments: []segment{{true, 300}, {false, 300}, {true, 20}, {false, 300}}, wantHasVoice: true, wantEnd: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := vad.NewBaseProvider(&vad.Config{MinSilenceDuration: minSilenceMs})
+			for _, seg := range tt.segments {
+				// 按20ms分多次更新，模拟逐帧检测
+				for ms := 0; ms < seg.ms; ms += 20 {
+					p.UpdateState(seg.voice, 20)
+				}
+			}
+			if got := p.HasVoice(); got != tt.wantHasVoice {
+				t.Errorf("HasVoice() = %v，期望 %v", got, tt.wantHasVoice)
+			}
+			if got := p.IsSpeechEnd(); got != tt.wantEnd {
+				t.Errorf("IsSpeechEnd() = %v，期望 %v", got, tt.wantEnd)
+			}
+
+			p.Reset()
+			if p.HasVoice() || p.IsSpeechEnd() {
+				t.Error("Reset() 后状态未清空")
+			}
+		})
+	}
+}
+
+func TestProvidersDetectSpeechEnd(t *testing.T) {
+	newEnergy := func(t *testing.T) vad.Provider {
+		p, err := energy.NewProvider(&vad.Config{MinSilenceDuration: minSilenceMs}, nil)
+		if err != nil {
+			t.Fatalf("创建能量VAD失败: %v", err)
+		}
+		return p
+	}
+	newWebRTC := func(t *testing.T) vad.Provider {
+		p, err := webrtc.NewProvider(&vad.Config{MinSilenceDuration: minSilenceMs}, nil)
+		if err != nil {
+			t.Fatalf("创建WebRTC VAD失败: %v", err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		newVAD  func(t *testing.T) vad.Provider
+		speech  func(ms int) []byte
+		wantEnd bool
+	}{
+		{name: "能量VAD-人声", newVAD: newEnergy, speech: tone, wantEnd: true},
+		{name: "WebRTC VAD-人声", newVAD: newWebRTC, speech: tone, wantEnd: true},
+		{name: "WebRTC VAD-高频噪声", newVAD: newWebRTC, speech: noise, wantEnd: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.newVAD(t)
+			feed(t, p, silence(300))
+			if p.IsSpeechEnd() {
+				t.Fatal("只有静音时不应判定语句结束")
+			}
+			feed(t, p, tt.speech(speechDuration))
+
+			// 逐包发送静音，记录判定语句结束时的静音时长
+			endAt := -1
+			for ms := chunkMs; ms <= 2000; ms += chunkMs {
+				feed(t, p, silence(chunkMs))
+				if p.IsSpeechEnd() {
+					endAt = ms
+					break
+				}
+			}
+			if !tt.wantEnd {
+				if endAt >= 0 {
+					t.Fatalf("噪声不应触发语句结束，静音 %dms 后判定结束", endAt)
+				}
+				return
+			}
+			// 允许一包的取整误差以及WebRTC拖尾帧
+			if endAt < minSilenceMs || endAt > minSilenceMs+200 {
+				t.Fatalf("静音 %dms 后判定语句结束，期望在 %d~%dms 之间", endAt, minSilenceMs, minSilenceMs+200)
+			}
+
+			if err := p.Reset(); err != nil {
+				t.Fatalf("Reset() 失败: %v", err)
+			}
+			if p.IsSpeechEnd() {
+				t.Error("Reset() 后不应处于语句结束状态")
+			}
+		})
+	}
+}
+
+// feed 按客户端的包长分块送入音频
+func feed(t *testing.T, p vad.Provider, pcm []byte) {
+	t.Helper()
+	chunkBytes := sampleRate * chunkMs / 1000 * 2
+	for start := 0; start < len(pcm); start += chunkBytes {
+		end := start + chunkBytes
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		if _, err := p.ProcessAudio(pcm[start:end]); err != nil {
+			t.Fatalf("ProcessAudio() 失败: %v", err)
+		}
+	}
+}
+
+func silence(ms int) []byte {
+	return make([]byte, sampleRate*ms/1000*2)
+}
+
+// tone 生成300Hz正弦波模拟人声
+func tone(ms int) []byte {
+	return samplesToPCM(sampleRate*ms/1000, func(i int) float64 {
+		return 0.3 * math.Sin(2*math.Pi*300*float64(i)/sampleRate)
+	})
+}
+
+// noise 生成正负交替的高频嘶声，过零率远高于人声
+func noise(ms int) []byte {
+	r := rand.New(rand.NewSource(1))
+	return samplesToPCM(sampleRate*ms/1000, func(i int) float64 {
+		amplitude := 0.1 + 0.2*r.Float64()
+		if i%2 == 1 {
+			return -amplitude
+		}
+		return amplitude
+	})
+}
+
+func samplesToPCM(n int, sample func(i int) float64) []byte {
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(sample(i)*32767)))
+	}
+	return pcm
+}
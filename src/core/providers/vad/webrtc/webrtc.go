@@ -0,0 +1,169 @@
+package webrtc
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"xiaozhi-server-go/src/core/providers/vad"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+/*
+* WebRTC风格的帧分类器。
+* 与WebRTC VAD一样按10/20/30ms切帧，通过aggressiveness(mode 0-3)控制判定严格程度，
+* 并带有自适应噪声基底和拖尾(hangover)平滑。分类特征为对数能量与过零率，
+* 不依赖cgo或外部模型文件。
+ */
+
+const (
+	defaultFrameDuration = 20    // 默认帧长(ms)
+	defaultMode          = 2     // 默认判定严格程度
+	defaultThreshold     = 0.003 // 默认最低RMS能量，低于此值直接判为静音
+	maxVoiceZCR          = 0.45  // 过零率高于此值视为噪声
+	noiseAdaptRate       = 0.05  // 非人声帧上的噪声基底更新速率
+	voiceNoiseAdaptRate  = 0.005 // 人声帧上的噪声基底更新速率，防止基底长期偏低
+	minEnergyDB          = -80.0 // 能量下限(dB)，避免纯数字静音把噪声基底拉得过低
+)
+
+var (
+	// 各mode下人声需要高出噪声基底的分贝数
+	modeMargins = [4]float64{6, 9, 12, 15}
+	// 各mode下人声结束后的拖尾帧数
+	modeHangovers = [4]int{8, 6, 4, 3}
+)
+
+// Ensure Provider implements vad.Provider interface
+var _ vad.Provider = (*Provider)(nil)
+
+// Provider WebRTC风格VAD提供者
+type Provider struct {
+	*vad.BaseProvider
+	logger *utils.Logger
+
+	frameDuration int
+	frameBytes    int
+	mode          int
+	threshold     float64
+
+	mu         sync.Mutex
+	remainder  []byte  // 不足一帧的剩余数据
+	noiseFloor float64 // 自适应噪声基底(dB)
+	noiseReady bool    // 噪声基底是否已用首帧初始化
+	hangover   int     // 剩余拖尾帧数
+}
+
+// NewProvider 创建WebRTC风格VAD提供者
+func NewProvider(config *vad.Config, logger *utils.Logger) (*Provider, error) {
+	base := vad.NewBaseProvider(config)
+
+	frameDuration := vad.GetIntOption(config.Data, "frame_duration_ms", defaultFrameDuration)
+	if frameDuration != 10 && frameDuration != 20 && frameDuration != 30 {
+		return nil, fmt.Errorf("不支持的帧长: %dms，仅支持10/20/30ms", frameDuration)
+	}
+
+	mode := vad.GetIntOption(config.Data, "mode", defaultMode)
+	if mode < 0 || mode > 3 {
+		return nil, fmt.Errorf("不支持的mode: %d，取值范围为0-3", mode)
+	}
+
+	threshold := config.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+
+	return &Provider{
+		BaseProvider:  base,
+		logger:        logger,
+		frameDuration: frameDuration,
+		frameBytes:    config.SampleRate * frameDuration / 1000 * 2,
+		mode:          mode,
+		threshold:     threshold,
+	}, nil
+}
+
+// ProcessAudio 按帧分类音频，任一帧包含人声即返回true
+func (p *Provider) ProcessAudio(pcm []byte) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data := append(p.remainder, pcm...)
+	hasVoice := false
+	for len(data) >= p.frameBytes {
+		isVoice := p.classifyFrame(vad.PCMToSamples(data[:p.frameBytes]))
+		p.UpdateState(isVoice, p.frameDuration)
+		if isVoice {
+			hasVoice = true
+		}
+		data = data[p.frameBytes:]
+	}
+	p.remainder = append([]byte(nil), data...)
+
+	return hasVoice, nil
+}
+
+// classifyFrame 判断单帧是否为人声
+func (p *Provider) classifyFrame(samples []float64) bool {
+	rms := vad.RMS(samples)
+	energyDB := math.Max(20*math.Log10(rms+1e-10), minEnergyDB)
+
+	if !p.noiseReady {
+		p.noiseFloor = energyDB
+		p.noiseReady = true
+	}
+
+	isVoice := rms >= p.threshold &&
+		energyDB >= p.noiseFloor+modeMargins[p.mode] &&
+		zeroCrossingRate(samples) <= maxVoiceZCR
+
+	// 噪声基底：遇到更安静的帧立即下调，否则缓慢跟踪，人声帧上跟踪更慢
+	switch {
+	case energyDB < p.noiseFloor:
+		p.noiseFloor = energyDB
+	case isVoice:
+		p.noiseFloor += voiceNoiseAdaptRate * (energyDB - p.noiseFloor)
+	default:
+		p.noiseFloor += noiseAdaptRate * (energyDB - p.noiseFloor)
+	}
+
+	if isVoice {
+		p.hangover = modeHangovers[p.mode]
+		return true
+	}
+
+	if p.hangover > 0 {
+		p.hangover--
+		return true
+	}
+	return false
+}
+
+// zeroCrossingRate 计算过零率
+func zeroCrossingRate(samples []float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	crossings := 0
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] >= 0) != (samples[i] >= 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / float64(len(samples)-1)
+}
+
+// Reset 复位VAD状态
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	p.remainder = nil
+	p.noiseReady = false
+	p.hangover = 0
+	p.mu.Unlock()
+	return p.BaseProvider.Reset()
+}
+
+func init() {
+	vad.Register("webrtc", func(config *vad.Config, logger *utils.Logger) (vad.Provider, error) {
+		return NewProvider(config, logger)
+	})
+}
@@ -0,0 +1,512 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 推送内容类型
+const (
+	TypeText  = "text"  // 文本，合成语音后播放
+	TypeAudio = "audio" // 服务端音频文件
+	TypeMusic = "music" // 音乐库中的歌曲名
+)
+
+// 推送优先级
+const (
+	PriorityInterrupt = "interrupt" // 立即打断当前对话播报
+	PriorityIdle      = "idle"      // 等待设备空闲后播放
+)
+
+// 推送状态
+const (
+	StatusQueued      = "queued"      // 排队等待投递
+	StatusDelivered   = "delivered"   // 已下发到设备，正在播放
+	StatusPlayed      = "played"      // 播放完成
+	StatusInterrupted = "interrupted" // 播放被打断
+	StatusExpired     = "expired"     // 超过有效期未能投递
+	StatusFailed      = "failed"      // 投递失败
+)
+
+const (
+	defaultTTL       = 24 * time.Hour  // 未指定有效期时的默认有效期
+	dispatchInterval = 2 * time.Second // 定时检查排队消息的间隔
+	finishedRetain   = 24 * time.Hour  // 无数据库时已结束消息在内存中的保留时长
+	listLimit        = 50              // 查询设备推送记录的默认条数
+	maxContentLength = 4096            // 推送内容最大长度
+)
+
+// DefaultAudioDir 未配置时推送音频文件所在的目录
+const DefaultAudioDir = "./audio"
+
+var (
+	// ErrInterrupted 推送播放被打断
+	ErrInterrupted = errors.New("推送播放被打断")
+	// ErrDisconnected 推送播放过程中连接断开
+	ErrDisconnected = errors.New("推送播放过程中连接断开")
+	// ErrMessageNotFound 推送消息不存在
+	ErrMessageNotFound = errors.New("推送消息不存在")
+)
+
+// Receiver 推送接收方，由设备的WebSocket连接处理器实现
+type Receiver interface {
+	// IsBusy 设备是否正在对话或播报
+	IsBusy() bool
+	// PlayPush 播放推送消息，interrupt 为 true 时打断当前播报。
+	// 返回nil时播放结束后必须调用一次done，返回错误时不会调用done
+	PlayPush(msg models.PushMessage, interrupt bool, done func(err error)) error
+}
+
+// Request 推送请求
+type Request struct {
+	DeviceID string
+	Type     string
+	Content  string
+	Priority string
+	TTL      time.Duration // 有效期，为0时使用默认有效期
+}
+
+// Manager 推送管理器，按设备维护带优先级的推送队列，
+// 设备在线且空闲（或消息要求打断）时投递，离线消息持久化后在设备下次连接时投递
+type Manager struct {
+	mu        sync.Mutex
+	queues    map[string][]*models.PushMessage // 设备待投递队列
+	inflight  map[string]*models.PushMessage   // 设备正在播放的消息
+	messages  map[string]*models.PushMessage   // 未结束及内存中保留的消息
+	receivers map[string]Receiver
+	db        *gorm.DB
+	logger    *utils.Logger
+}
+
+// NewManager 创建推送管理器，db为nil时仅在内存中排队
+func NewManager(db *gorm.DB, logger *utils.Logger) *Manager {
+	return &Manager{
+		queues:    make(map[string][]*models.PushMessage),
+		inflight:  make(map[string]*models.PushMessage),
+		messages:  make(map[string]*models.PushMessage),
+		receivers: make(map[string]Receiver),
+		db:        db,
+		logger:    logger,
+	}
+}
+
+var defaultManager = NewManager(nil, nil)
+
+// Default 返回全局推送管理器
+func Default() *Manager {
+	return defaultManager
+}
+
+// Init 设置持久化数据库并加载未投递的消息，已下发但未收到播放结果的消息重新排队
+func (m *Manager) Init(db *gorm.DB, logger *utils.Logger) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.db = db
+	m.logger = logger
+	if db == nil {
+		return nil
+	}
+
+	var pending []*models.PushMessage
+	err := db.Where("status IN ?", []string{StatusQueued, StatusDelivered}).Order("id").Find(&pending).Error
+	if err != nil {
+		return fmt.Errorf("加载待投递推送失败: %v", err)
+	}
+	for _, msg := range pending {
+		if msg.Status == StatusDelivered {
+			msg.Status = StatusQueued
+			msg.DeliveredAt = nil
+			if err := db.Save(msg).Error; err != nil {
+				return fmt.Errorf("重置推送 %s 状态失败: %v", msg.MessageID, err)
+			}
+		}
+		m.enqueueLocked(msg)
+	}
+	if len(pending) > 0 && logger != nil {
+		logger.Info("已加载 %d 条待投递推送", len(pending))
+	}
+	return nil
+}
+
+// Start 启动定时投递，处理过期消息并在设备空闲后投递排队消息
+func (m *Manager) Start(ctx context.Context) {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.expireAll()
+			m.mu.Lock()
+			devices := make([]string, 0, len(m.receivers))
+			for deviceID := range m.receivers {
+				devices = append(devices, deviceID)
+			}
+			m.mu.Unlock()
+			for _, deviceID := range devices {
+				m.dispatch(deviceID)
+			}
+		}
+	}
+}
+
+// Enqueue 新增推送消息，设备在线时立即尝试投递
+func (m *Manager) Enqueue(req Request) (*models.PushMessage, error) {
+	if req.DeviceID == "" {
+		return nil, fmt.Errorf("设备ID不能为空")
+	}
+	switch req.Type {
+	case "":
+		req.Type = TypeText
+	case TypeText, TypeAudio, TypeMusic:
+	default:
+		return nil, fmt.Errorf("不支持的推送类型: %s", req.Type)
+	}
+	switch req.Priority {
+	case "":
+		req.Priority = PriorityIdle
+	case PriorityInterrupt, PriorityIdle:
+	default:
+		return nil, fmt.Errorf("不支持的推送优先级: %s", req.Priority)
+	}
+	if req.Content == "" {
+		return nil, fmt.Errorf("推送内容不能为空")
+	}
+	if len(req.Content) > maxContentLength {
+		return nil, fmt.Errorf("推送内容过长，最多 %d 字节", maxContentLength)
+	}
+	if req.Type == TypeAudio && !filepath.IsLocal(req.Content) {
+		return nil, fmt.Errorf("音频路径必须是音频目录下的相对路径: %s", req.Content)
+	}
+	if req.TTL <= 0 {
+		req.TTL = defaultTTL
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(req.TTL)
+	msg := &models.PushMessage{
+		MessageID: uuid.New().String(),
+		DeviceID:  req.DeviceID,
+		Type:      req.Type,
+		Content:   req.Content,
+		Priority:  req.Priority,
+		Status:    StatusQueued,
+		ExpiresAt: &expiresAt,
+		CreatedAt: now,
+	}
+
+	m.mu.Lock()
+	if m.db != nil {
+		if err := m.db.Create(msg).Error; err != nil {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("保存推送消息失败: %v", err)
+		}
+	}
+	m.enqueueLocked(msg)
+	result := *msg
+	m.mu.Unlock()
+
+	go m.dispatch(req.DeviceID)
+	return &result, nil
+}
+
+// Get 查询推送消息状态
+func (m *Manager) Get(messageID string) (*models.PushMessage, error) {
+	m.mu.Lock()
+	if msg, ok := m.messages[messageID]; ok {
+		result := *msg
+		m.mu.Unlock()
+		return &result, nil
+	}
+	db := m.db
+	m.mu.Unlock()
+
+	if db == nil {
+		return nil, ErrMessageNotFound
+	}
+	var msg models.PushMessage
+	err := db.Where("message_id = ?", messageID).First(&msg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询推送消息失败: %v", err)
+	}
+	return &msg, nil
+}
+
+// List 查询设备最近的推送消息，按创建时间倒序
+func (m *Manager) List(deviceID string) ([]models.PushMessage, error) {
+	m.mu.Lock()
+	db := m.db
+	if db == nil {
+		var messages []models.PushMessage
+		for _, msg := range m.messages {
+			if msg.DeviceID == deviceID {
+				messages = append(messages, *msg)
+			}
+		}
+		m.mu.Unlock()
+		sort.Slice(messages, func(i, j int) bool {
+			return messages[i].CreatedAt.After(messages[j].CreatedAt)
+		})
+		if len(messages) > listLimit {
+			messages = messages[:listLimit]
+		}
+		return messages, nil
+	}
+	m.mu.Unlock()
+
+	var messages []models.PushMessage
+	err := db.Where("device_id = ?", deviceID).Order("id DESC").Limit(listLimit).Find(&messages).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询推送消息失败: %v", err)
+	}
+	return messages, nil
+}
+
+// Attach 设备上线后登记接收方，并投递排队中的消息
+func (m *Manager) Attach(deviceID string, receiver Receiver) {
+	if deviceID == "" || receiver == nil {
+		return
+	}
+	m.mu.Lock()
+	m.receivers[deviceID] = receiver
+	m.mu.Unlock()
+	go m.dispatch(deviceID)
+}
+
+// Detach 设备下线时注销接收方，已被新连接替换时忽略
+func (m *Manager) Detach(deviceID string, receiver Receiver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.receivers[deviceID] == receiver {
+		delete(m.receivers, deviceID)
+	}
+}
+
+// Notify 设备空闲时通知投递下一条消息
+func (m *Manager) Notify(deviceID string) {
+	if deviceID == "" {
+		return
+	}
+	m.mu.Lock()
+	pending := len(m.queues[deviceID]) > 0
+	m.mu.Unlock()
+	if pending {
+		go m.dispatch(deviceID)
+	}
+}
+
+// dispatch 尝试向设备投递队首消息
+func (m *Manager) dispatch(deviceID string) {
+	m.mu.Lock()
+	receiver := m.receivers[deviceID]
+	if receiver == nil {
+		m.mu.Unlock()
+		return
+	}
+	expired := m.expireLocked(deviceID, time.Now())
+	queue := m.queues[deviceID]
+	if len(queue) == 0 {
+		m.mu.Unlock()
+		m.saveAll(expired)
+		return
+	}
+	next := queue[0]
+	interrupt := next.Priority == PriorityInterrupt
+	// 同一设备同时只播放一条推送，打断消息只打断对话播报
+	if m.inflight[deviceID] != nil || (!interrupt && receiver.IsBusy()) {
+		m.mu.Unlock()
+		m.saveAll(expired)
+		return
+	}
+
+	m.queues[deviceID] = queue[1:]
+	now := time.Now()
+	next.Status = StatusDelivered
+	next.DeliveredAt = &now
+	m.inflight[deviceID] = next
+	delivered := *next
+	m.mu.Unlock()
+
+	m.saveAll(expired)
+	m.save(delivered)
+	m.logInfo("向设备 %s 投递推送 %s (%s, %s)", deviceID, next.MessageID, next.Type, next.Priority)
+
+	var once sync.Once
+	done := func(err error) {
+		once.Do(func() { m.finish(deviceID, next, err) })
+	}
+	if err := receiver.PlayPush(delivered, interrupt, done); err != nil {
+		done(err)
+	}
+}
+
+// finish 记录推送播放结果，连接断开时重新排队等待下次投递
+func (m *Manager) finish(deviceID string, msg *models.PushMessage, err error) {
+	m.mu.Lock()
+	if m.inflight[deviceID] == msg {
+		delete(m.inflight, deviceID)
+	}
+	now := time.Now()
+	switch {
+	case err == nil:
+		msg.Status = StatusPlayed
+		msg.FinishedAt = &now
+	case errors.Is(err, ErrDisconnected):
+		msg.Status = StatusQueued
+		msg.DeliveredAt = nil
+		m.queues[deviceID] = append([]*models.PushMessage{msg}, m.queues[deviceID]...)
+	case errors.Is(err, ErrInterrupted):
+		msg.Status = StatusInterrupted
+		msg.FinishedAt = &now
+	default:
+		msg.Status = StatusFailed
+		msg.Error = err.Error()
+		msg.FinishedAt = &now
+	}
+	m.releaseLocked(msg)
+	result := *msg
+	m.mu.Unlock()
+
+	m.save(result)
+	if err != nil {
+		m.logInfo("推送 %s 结束，状态: %s, 原因: %v", msg.MessageID, result.Status, err)
+	} else {
+		m.logInfo("推送 %s 播放完成", msg.MessageID)
+	}
+	go m.dispatch(deviceID)
+}
+
+// enqueueLocked 按优先级插入队列，打断消息排在等待空闲的消息之前，同优先级先进先出
+func (m *Manager) enqueueLocked(msg *models.PushMessage) {
+	queue := m.queues[msg.DeviceID]
+	pos := len(queue)
+	if msg.Priority == PriorityInterrupt {
+		pos = 0
+		for pos < len(queue) && queue[pos].Priority == PriorityInterrupt {
+			pos++
+		}
+	}
+	queue = append(queue, nil)
+	copy(queue[pos+1:], queue[pos:])
+	queue[pos] = msg
+	m.queues[msg.DeviceID] = queue
+	m.messages[msg.MessageID] = msg
+}
+
+// expireLocked 移除设备队列中已过期的消息
+func (m *Manager) expireLocked(deviceID string, now time.Time) []models.PushMessage {
+	var expired []models.PushMessage
+	queue := m.queues[deviceID][:0]
+	for _, msg := range m.queues[deviceID] {
+		if msg.ExpiresAt != nil && now.After(*msg.ExpiresAt) {
+			msg.Status = StatusExpired
+			msg.FinishedAt = &now
+			m.releaseLocked(msg)
+			expired = append(expired, *msg)
+			continue
+		}
+		queue = append(queue, msg)
+	}
+	if len(queue) == 0 {
+		delete(m.queues, deviceID)
+	} else {
+		m.queues[deviceID] = queue
+	}
+	return expired
+}
+
+// expireAll 处理所有设备（含离线设备）队列中的过期消息，并清理内存中保留过久的已结束消息
+func (m *Manager) expireAll() {
+	now := time.Now()
+	m.mu.Lock()
+	var expired []models.PushMessage
+	for deviceID := range m.queues {
+		expired = append(expired, m.expireLocked(deviceID, now)...)
+	}
+	for id, msg := range m.messages {
+		if msg.FinishedAt != nil && now.Sub(*msg.FinishedAt) > finishedRetain {
+			delete(m.messages, id)
+		}
+	}
+	m.mu.Unlock()
+	m.saveAll(expired)
+}
+
+// releaseLocked 消息结束后，有数据库时从内存中移除
+func (m *Manager) releaseLocked(msg *models.PushMessage) {
+	if msg.FinishedAt != nil && m.db != nil {
+		delete(m.messages, msg.MessageID)
+	}
+}
+
+// save 持久化消息状态
+func (m *Manager) save(msg models.PushMessage) {
+	m.mu.Lock()
+	db := m.db
+	m.mu.Unlock()
+	if db == nil {
+		return
+	}
+	if err := db.Save(&msg).Error; err != nil {
+		m.logError("保存推送 %s 状态失败: %v", msg.MessageID, err)
+	}
+}
+
+func (m *Manager) saveAll(messages []models.PushMessage) {
+	for _, msg := range messages {
+		m.save(msg)
+	}
+}
+
+func (m *Manager) logInfo(format string, args ...interface{}) {
+	m.mu.Lock()
+	logger := m.logger
+	m.mu.Unlock()
+	if logger != nil {
+		logger.Info(format, args...)
+	}
+}
+
+func (m *Manager) logError(format string, args ...interface{}) {
+	m.mu.Lock()
+	logger := m.logger
+	m.mu.Unlock()
+	if logger != nil {
+		logger.Error(format, args...)
+	}
+}
+
+// ResolveAudioPath 将推送的音频路径解析为音频目录下的文件，拒绝绝对路径和跳出目录的路径
+func ResolveAudioPath(dir, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("音频路径必须是音频目录下的相对路径: %s", name)
+	}
+	if dir == "" {
+		dir = DefaultAudioDir
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// IsAudioFile 判断文件是否位于推送音频目录下
+func IsAudioFile(dir, path string) bool {
+	if dir == "" {
+		dir = DefaultAudioDir
+	}
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && filepath.IsLocal(rel)
+}
@@ -0,0 +1,139 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{name: "字段不足", expr: "* * * *"},
+		{name: "字段过多", expr: "* * * * * *"},
+		{name: "分钟超出范围", expr: "60 * * * *"},
+		{name: "日期为0", expr: "* * 0 * *"},
+		{name: "月份超出范围", expr: "* * * 13 *"},
+		{name: "星期超出范围", expr: "* * * * 8"},
+		{name: "步长为0", expr: "*/0 * * * *"},
+		{name: "步长无效", expr: "*/x * * * *"},
+		{name: "范围颠倒", expr: "30-10 * * * *"},
+		{name: "非数字", expr: "a * * * *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCron(tt.expr); err == nil {
+				t.Errorf("ParseCron(%q) 未返回错误", tt.expr)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2026-01-01 为周四
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{name: "每分钟", expr: "* * * * *", after: date(2026, 1, 1, 10, 7), want: date(2026, 1, 1, 10, 8)},
+		{name: "*/n", expr: "*/15 * * * *", after: date(2026, 1, 1, 10, 7), want: date(2026, 1, 1, 10, 15)},
+		{name: "*/n跨小时", expr: "*/15 * * * *", after: date(2026, 1, 1, 10, 45), want: date(2026, 1, 1, 11, 0)},
+		{name: "小时*/n", expr: "0 */6 * * *", after: date(2026, 1, 1, 7, 0), want: date(2026, 1, 1, 12, 0)},
+		{name: "a-b/n", expr: "10-40/15 * * * *", after: date(2026, 1, 1, 10, 26), want: date(2026, 1, 1, 10, 40)},
+		{name: "a-b/n超出范围后进入下一小时", expr: "10-40/15 * * * *", after: date(2026, 1, 1, 10, 41), want: date(2026, 1, 1, 11, 10)},
+		{name: "a/n到最大值", expr: "50/5 * * * *", after: date(2026, 1, 1, 10, 51), want: date(2026, 1, 1, 10, 55)},
+		{name: "a/n下一小时从a开始", expr: "50/5 * * * *", after: date(2026, 1, 1, 10, 56), want: date(2026, 1, 1, 11, 50)},
+		{name: "a/1到最大值", expr: "0 22/1 * * *", after: date(2026, 1, 1, 22, 30), want: date(2026, 1, 1, 23, 0)},
+		{name: "列表", expr: "0 8,20 * * *", after: date(2026, 1, 1, 8, 0), want: date(2026, 1, 1, 20, 0)},
+		{name: "7表示周日", expr: "0 9 * * 7", after: date(2026, 1, 1, 10, 0), want: date(2026, 1, 4, 9, 0)},
+		{name: "0表示周日", expr: "0 9 * * 0", after: date(2026, 1, 1, 10, 0), want: date(2026, 1, 4, 9, 0)},
+		{name: "范围包含7", expr: "0 9 * * 6-7", after: date(2026, 1, 3, 10, 0), want: date(2026, 1, 4, 9, 0)},
+		{name: "工作日", expr: "30 7 * * 1-5", after: date(2026, 1, 2, 8, 0), want: date(2026, 1, 5, 7, 30)},
+		{name: "日期和星期都限制时满足星期", expr: "0 9 13 * 5", after: date(2026, 1, 1, 10, 0), want: date(2026, 1, 2, 9, 0)},
+		{name: "日期和星期都限制时满足日期", expr: "0 9 13 * 5", after: date(2026, 1, 10, 10, 0), want: date(2026, 1, 13, 9, 0)},
+		{name: "只限制日期", expr: "0 9 13 * *", after: date(2026, 1, 2, 10, 0), want: date(2026, 1, 13, 9, 0)},
+		{name: "跨月", expr: "0 0 1 * *", after: date(2026, 1, 31, 23, 59), want: date(2026, 2, 1, 0, 0)},
+		{name: "跳过没有31日的月份", expr: "0 0 31 * *", after: date(2026, 2, 1, 0, 0), want: date(2026, 3, 31, 0, 0)},
+		{name: "跨年", expr: "0 0 1 1 *", after: date(2026, 12, 31, 23, 59), want: date(2027, 1, 1, 0, 0)},
+		{name: "闰年2月29日", expr: "0 0 29 2 *", after: date(2026, 3, 1, 0, 0), want: date(2028, 2, 29, 0, 0)},
+		{name: "不存在的日期", expr: "0 0 31 2 *", after: date(2026, 1, 1, 0, 0), want: time.Time{}},
+		{name: "秒数被截断", expr: "* * * * *", after: date(2026, 1, 1, 10, 7).Add(30 * time.Second), want: date(2026, 1, 1, 10, 8)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 失败: %v", tt.expr, err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v，期望 %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronScheduleMatchDay(t *testing.T) {
+	// 2026-01-02 为周五，2026-01-13 为周二，2026-01-14 为周三
+	tests := []struct {
+		name string
+		expr string
+		day  time.Time
+		want bool
+	}{
+		{name: "都不限制", expr: "0 0 * * *", day: date(2026, 1, 14, 0, 0), want: true},
+		{name: "只限制星期-匹配", expr: "0 0 * * 5", day: date(2026, 1, 2, 0, 0), want: true},
+		{name: "只限制星期-不匹配", expr: "0 0 * * 5", day: date(2026, 1, 13, 0, 0), want: false},
+		{name: "只限制日期-不匹配", expr: "0 0 13 * *", day: date(2026, 1, 2, 0, 0), want: false},
+		{name: "都限制-满足日期", expr: "0 0 13 * 5", day: date(2026, 1, 13, 0, 0), want: true},
+		{name: "都限制-满足星期", expr: "0 0 13 * 5", day: date(2026, 1, 2, 0, 0), want: true},
+		{name: "都限制-都不满足", expr: "0 0 13 * 5", day: date(2026, 1, 14, 0, 0), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 失败: %v", tt.expr, err)
+			}
+			if got := schedule.matchDay(tt.day); got != tt.want {
+				t.Errorf("matchDay(%v) = %v，期望 %v", tt.day, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountFires(t *testing.T) {
+	from := date(2026, 1, 1, 0, 0)
+	tests := []struct {
+		name string
+		expr string
+		want int
+	}{
+		{name: "每天一次", expr: "0 9 * * *", want: 1},
+		{name: "每15分钟恰好达到上限", expr: "*/15 * * * *", want: maxFiresPerDay},
+		{name: "每10分钟超过上限", expr: "*/10 * * * *", want: maxFiresPerDay + 1},
+		{name: "每分钟超过上限后停止计数", expr: "* * * * *", want: maxFiresPerDay + 1},
+		{name: "不存在的日期", expr: "0 0 31 2 *", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 失败: %v", tt.expr, err)
+			}
+			if got := countFires(schedule, from, from.Add(24*time.Hour)); got != tt.want {
+				t.Errorf("countFires(%q) = %d，期望 %d", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
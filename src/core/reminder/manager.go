@@ -0,0 +1,415 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/push"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/task"
+
+	"gorm.io/gorm"
+)
+
+// TaskTypeReminder 提醒到点触发的定时任务类型
+const TaskTypeReminder task.TaskType = "reminder"
+
+// 提醒状态
+const (
+	StatusActive    = "active"    // 等待触发
+	StatusDone      = "done"      // 一次性提醒已触发
+	StatusCancelled = "cancelled" // 已取消
+)
+
+const (
+	maxActivePerDevice = 20             // 每个设备最多同时存在的提醒数
+	maxContentLength   = 512            // 提醒内容最大长度
+	maxFiresPerDay     = 96             // 周期闹钟每天最多触发次数，需小于任务管理器的每日配额
+	missedGrace        = time.Hour      // 服务停止期间错过的一次性提醒，超过该时长不再补发
+	pushTTL            = 12 * time.Hour // 设备离线时提醒在推送队列中的有效期
+)
+
+var (
+	// ErrNotStarted 提醒服务未启动
+	ErrNotStarted = errors.New("提醒服务未启动")
+	// ErrReminderNotFound 提醒不存在
+	ErrReminderNotFound = errors.New("提醒不存在")
+)
+
+// Spec 新建提醒的参数，FireAt 和 Cron 二选一
+type Spec struct {
+	Content string
+	Music   string
+	FireAt  time.Time // 一次性提醒的触发时间
+	Cron    string    // 周期闹钟的cron表达式
+}
+
+// Manager 提醒管理器，提醒保存在数据库中，
+// 通过 task.TaskManager 的定时任务触发，到点后交给推送队列播报，设备离线时等待重新连接
+type Manager struct {
+	mu      sync.Mutex
+	db      *gorm.DB
+	logger  *utils.Logger
+	ctx     context.Context
+	taskMgr *task.TaskManager
+	tasks   map[uint]string // 提醒ID -> 定时任务ID
+}
+
+// NewManager 创建提醒管理器
+func NewManager() *Manager {
+	return &Manager{tasks: make(map[uint]string)}
+}
+
+var defaultManager = NewManager()
+
+// Default 返回全局提醒管理器
+func Default() *Manager {
+	return defaultManager
+}
+
+// Init 设置持久化数据库
+func (m *Manager) Init(db *gorm.DB, logger *utils.Logger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.db = db
+	m.logger = logger
+}
+
+func init() {
+	task.RegisterTaskExecutor(TaskTypeReminder, func(t *task.Task) error {
+		return Default().execute(t)
+	})
+	task.RegisterRetryPolicy(TaskTypeReminder, task.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Minute,
+	})
+}
+
+// Start 为数据库中未触发的提醒安排定时任务，任务管理器已从持久化存储恢复的任务直接沿用
+func (m *Manager) Start(ctx context.Context, taskMgr *task.TaskManager) error {
+	if taskMgr == nil {
+		return fmt.Errorf("任务管理器未初始化")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ctx = ctx
+	m.taskMgr = taskMgr
+	if m.db == nil {
+		return nil
+	}
+
+	var reminders []models.Reminder
+	if err := m.db.Where("status = ?", StatusActive).Find(&reminders).Error; err != nil {
+		return fmt.Errorf("加载提醒失败: %v", err)
+	}
+	now := time.Now()
+	for i := range reminders {
+		r := &reminders[i]
+		changed := false
+		if r.FireAt.Before(now) {
+			if r.Cron != "" {
+				// 周期闹钟跳过停机期间错过的触发
+				r.FireAt = nextFire(r.Cron, now)
+				changed = true
+			} else if now.Sub(r.FireAt) > missedGrace {
+				r.Status = StatusDone
+				changed = true
+				m.logInfo("提醒 %d 已错过触发时间 %s，不再补发", r.ID, r.FireAt.Format("2006-01-02 15:04"))
+			}
+			if r.FireAt.IsZero() {
+				r.Status = StatusDone
+			}
+		}
+		if !changed && r.TaskID != "" && taskMgr.IsScheduled(r.TaskID) {
+			m.tasks[r.ID] = r.TaskID
+			continue
+		}
+		if r.TaskID != "" {
+			// 丢弃恢复的旧任务，按新的触发时间重新安排
+			taskMgr.CancelTask(clientID(r.ID), r.TaskID)
+		}
+		if changed {
+			if err := m.db.Save(r).Error; err != nil {
+				return fmt.Errorf("更新提醒 %d 失败: %v", r.ID, err)
+			}
+		}
+		if r.Status != StatusActive {
+			continue
+		}
+		if err := m.scheduleLocked(r); err != nil {
+			m.logError("安排提醒 %d 失败: %v", r.ID, err)
+		}
+	}
+	if len(m.tasks) > 0 {
+		m.logInfo("已加载 %d 个待触发的提醒", len(m.tasks))
+	}
+	return nil
+}
+
+// Add 为设备新增提醒或周期闹钟
+func (m *Manager) Add(deviceID string, spec Spec) (*models.Reminder, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("设备ID不能为空")
+	}
+	if spec.Content == "" {
+		return nil, fmt.Errorf("提醒内容不能为空")
+	}
+	if len(spec.Content) > maxContentLength {
+		return nil, fmt.Errorf("提醒内容过长，最多 %d 字节", maxContentLength)
+	}
+
+	now := time.Now()
+	fireAt := spec.FireAt
+	if spec.Cron != "" {
+		schedule, err := ParseCron(spec.Cron)
+		if err != nil {
+			return nil, err
+		}
+		fireAt = schedule.Next(now)
+		if fireAt.IsZero() {
+			return nil, fmt.Errorf("cron表达式没有可触发的时间: %s", spec.Cron)
+		}
+		if countFires(schedule, now, now.Add(24*time.Hour)) > maxFiresPerDay {
+			return nil, fmt.Errorf("闹钟触发过于频繁，每天最多 %d 次", maxFiresPerDay)
+		}
+	} else if !fireAt.After(now) {
+		return nil, fmt.Errorf("提醒时间必须晚于当前时间")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.db == nil || m.taskMgr == nil {
+		return nil, ErrNotStarted
+	}
+
+	var active int64
+	if err := m.db.Model(&models.Reminder{}).
+		Where("device_id = ? AND status = ?", deviceID, StatusActive).Count(&active).Error; err != nil {
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+	if active >= maxActivePerDevice {
+		return nil, fmt.Errorf("提醒数量已达上限 %d 个，请先取消不需要的提醒", maxActivePerDevice)
+	}
+
+	r := &models.Reminder{
+		DeviceID: deviceID,
+		Content:  spec.Content,
+		Music:    spec.Music,
+		Cron:     spec.Cron,
+		FireAt:   fireAt,
+		Status:   StatusActive,
+	}
+	if err := m.db.Create(r).Error; err != nil {
+		return nil, fmt.Errorf("保存提醒失败: %v", err)
+	}
+	if err := m.scheduleLocked(r); err != nil {
+		m.db.Delete(r)
+		return nil, err
+	}
+	m.logInfo("设备 %s 新增提醒 %d，触发时间: %s", deviceID, r.ID, r.FireAt.Format("2006-01-02 15:04"))
+	return r, nil
+}
+
+// List 查询设备未触发的提醒，按触发时间排序
+func (m *Manager) List(deviceID string) ([]models.Reminder, error) {
+	m.mu.Lock()
+	db := m.db
+	m.mu.Unlock()
+	if db == nil {
+		return nil, ErrNotStarted
+	}
+
+	var reminders []models.Reminder
+	err := db.Where("device_id = ? AND status = ?", deviceID, StatusActive).Order("fire_at").Find(&reminders).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+	return reminders, nil
+}
+
+// Cancel 取消设备的提醒
+func (m *Manager) Cancel(deviceID string, id uint) (*models.Reminder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.db == nil {
+		return nil, ErrNotStarted
+	}
+
+	var r models.Reminder
+	err := m.db.Where("id = ? AND device_id = ? AND status = ?", id, deviceID, StatusActive).First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReminderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+
+	r.Status = StatusCancelled
+	if err := m.db.Model(&r).Update("status", StatusCancelled).Error; err != nil {
+		return nil, fmt.Errorf("取消提醒失败: %v", err)
+	}
+	if taskID, ok := m.tasks[id]; ok {
+		m.taskMgr.CancelTask(clientID(id), taskID)
+		delete(m.tasks, id)
+	}
+	m.logInfo("设备 %s 取消提醒 %d", deviceID, id)
+	return &r, nil
+}
+
+// scheduleLocked 为提醒提交定时任务，每个提醒使用独立的任务配额
+func (m *Manager) scheduleLocked(r *models.Reminder) error {
+	t, taskID := task.NewTask(m.ctx, TaskTypeReminder, r.ID)
+	fireAt := r.FireAt
+	t.ScheduledTime = &fireAt
+	m.tasks[r.ID] = taskID
+	if err := m.taskMgr.SubmitTask(clientID(r.ID), t); err != nil {
+		delete(m.tasks, r.ID)
+		return fmt.Errorf("提交定时任务失败: %v", err)
+	}
+	r.TaskID = taskID
+	if err := m.db.Model(r).Update("task_id", taskID).Error; err != nil {
+		m.logError("保存提醒 %d 的任务ID失败: %v", r.ID, err)
+	}
+	return nil
+}
+
+// execute 定时任务执行器，播报提醒并为周期闹钟安排下一次触发，返回错误时按重试策略重试
+func (m *Manager) execute(t *task.Task) error {
+	id := reminderID(t.Params)
+	m.mu.Lock()
+	if m.tasks[id] != t.ID {
+		// 提醒已取消或已重新安排
+		m.mu.Unlock()
+		return nil
+	}
+	db := m.db
+	m.mu.Unlock()
+
+	var r models.Reminder
+	if err := db.First(&r, id).Error; err != nil {
+		return fmt.Errorf("加载提醒 %d 失败: %v", id, err)
+	}
+	if r.Status != StatusActive {
+		return nil
+	}
+
+	if err := m.deliver(&r); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"last_fired_at": now}
+	if r.Cron != "" {
+		r.FireAt = nextFire(r.Cron, now)
+	}
+	if r.Cron == "" || r.FireAt.IsZero() {
+		r.Status = StatusDone
+		updates["status"] = StatusDone
+	} else {
+		updates["fire_at"] = r.FireAt
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tasks[id] != t.ID {
+		return nil
+	}
+	delete(m.tasks, id)
+	// 只更新仍处于等待状态的提醒，避免覆盖触发期间的取消操作
+	result := db.Model(&models.Reminder{}).Where("id = ? AND status = ?", id, StatusActive).Updates(updates)
+	if result.Error != nil {
+		// 提醒已播报，不再重试，避免重复提醒
+		m.logError("更新提醒 %d 失败: %v", id, result.Error)
+	} else if result.RowsAffected == 0 {
+		return nil
+	}
+	if r.Status != StatusActive {
+		return nil
+	}
+	if err := m.scheduleLocked(&r); err != nil {
+		m.logError("安排周期闹钟 %d 的下一次触发失败: %v", id, err)
+	}
+	return nil
+}
+
+// deliver 通过推送队列播报提醒内容，设置了音乐时在播报后播放
+func (m *Manager) deliver(r *models.Reminder) error {
+	m.logInfo("提醒 %d 触发，设备: %s, 内容: %s", r.ID, r.DeviceID, r.Content)
+	_, err := push.Default().Enqueue(push.Request{
+		DeviceID: r.DeviceID,
+		Type:     push.TypeText,
+		Content:  "提醒时间到了：" + r.Content,
+		Priority: push.PriorityInterrupt,
+		TTL:      pushTTL,
+	})
+	if err != nil {
+		return fmt.Errorf("提醒 %d 推送失败: %v", r.ID, err)
+	}
+	if r.Music == "" {
+		return nil
+	}
+	_, err = push.Default().Enqueue(push.Request{
+		DeviceID: r.DeviceID,
+		Type:     push.TypeMusic,
+		Content:  r.Music,
+		Priority: push.PriorityIdle,
+		TTL:      pushTTL,
+	})
+	if err != nil {
+		m.logError("提醒 %d 音乐推送失败: %v", r.ID, err)
+	}
+	return nil
+}
+
+func (m *Manager) logInfo(format string, args ...interface{}) {
+	if m.logger != nil {
+		m.logger.Info(format, args...)
+	}
+}
+
+func (m *Manager) logError(format string, args ...interface{}) {
+	if m.logger != nil {
+		m.logger.Error(format, args...)
+	}
+}
+
+// clientID 提醒在任务管理器中的客户端ID，每个提醒使用独立的任务配额
+func clientID(id uint) string {
+	return "reminder-" + strconv.FormatUint(uint64(id), 10)
+}
+
+// reminderID 从任务参数中取出提醒ID，从持久化存储恢复的任务参数为JSON数字
+func reminderID(params interface{}) uint {
+	switch v := params.(type) {
+	case uint:
+		return v
+	case float64:
+		return uint(v)
+	}
+	return 0
+}
+
+// nextFire 计算周期闹钟晚于after的下一次触发时间，表达式无效时返回零值
+func nextFire(expr string, after time.Time) time.Time {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.Next(after)
+}
+
+// countFires 统计时间段内的触发次数，超过上限即停止计数
+func countFires(schedule *CronSchedule, from, to time.Time) int {
+	count := 0
+	for t := schedule.Next(from); !t.IsZero() && !t.After(to) && count <= maxFiresPerDay; t = schedule.Next(t) {
+		count++
+	}
+	return count
+}
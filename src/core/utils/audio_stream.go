@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+	opus "github.com/qrtc/opus-go"
+)
+
+// AudioFrameStream 将增量到达的16bit单声道PCM数据切分为60ms音频帧，
+// 按需编码为Opus后写入输出通道，供流式TTS边合成边下发
+type AudioFrameStream struct {
+	ctx           context.Context
+	format        string
+	bytesPerFrame int
+	encoder       *opus.OpusEncoder
+	pending       []byte
+	out           chan<- []byte
+}
+
+// NewAudioFrameStream 创建音频帧流，format为opus或pcm
+func NewAudioFrameStream(ctx context.Context, format string, sampleRate int, out chan<- []byte) (*AudioFrameStream, error) {
+	s := &AudioFrameStream{
+		ctx:           ctx,
+		format:        format,
+		bytesPerFrame: sampleRate * 60 / 1000 * 2, // 60ms帧
+		out:           out,
+	}
+
+	switch format {
+	case "opus":
+		encoder, err := opus.CreateOpusEncoder(&opus.OpusEncoderConfig{
+			SampleRate:    sampleRate,
+			MaxChannels:   1,
+			Application:   opus.AppVoIP,
+			FrameDuration: opus.Framesize60Ms,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("创建Opus编码器失败: %v", err)
+		}
+		s.encoder = encoder
+	case "pcm":
+	default:
+		return nil, fmt.Errorf("不支持的音频格式: %s", format)
+	}
+
+	return s, nil
+}
+
+// Write 追加PCM数据，凑满一帧即输出
+func (s *AudioFrameStream) Write(pcm []byte) error {
+	s.pending = append(s.pending, pcm...)
+	for len(s.pending) >= s.bytesPerFrame {
+		if err := s.emit(s.pending[:s.bytesPerFrame]); err != nil {
+			return err
+		}
+		s.pending = s.pending[s.bytesPerFrame:]
+	}
+	return nil
+}
+
+// Flush 将剩余不足一帧的数据补齐静音后输出
+func (s *AudioFrameStream) Flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	frame := make([]byte, s.bytesPerFrame)
+	copy(frame, s.pending)
+	s.pending = nil
+	return s.emit(frame)
+}
+
+// Close 释放编码器
+func (s *AudioFrameStream) Close() {
+	if s.encoder != nil {
+		s.encoder.Close()
+		s.encoder = nil
+	}
+}
+
+func (s *AudioFrameStream) emit(framePcm []byte) error {
+	frame := make([]byte, len(framePcm))
+	if s.encoder != nil {
+		n, err := s.encoder.Encode(framePcm, frame)
+		if err != nil {
+			return fmt.Errorf("Opus编码失败: %v", err)
+		}
+		if n == 0 {
+			return nil
+		}
+		frame = frame[:n]
+	} else {
+		copy(frame, framePcm)
+	}
+
+	select {
+	case s.out <- frame:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// DecodeMP3Stream 边读边解码MP3数据流，转换为目标采样率的16bit单声道PCM后回调
+func DecodeMP3Stream(r io.Reader, targetSampleRate int, onPCM func(pcm []byte) error) error {
+	decoder, err := mp3.NewDecoder(r)
+	if err != nil {
+		return fmt.Errorf("创建MP3解码器失败: %v", err)
+	}
+	sampleRate := decoder.SampleRate()
+
+	resampler := newStreamResampler(sampleRate, targetSampleRate)
+	buf := make([]byte, 8192)
+	var remainder []byte
+	for {
+		n, readErr := decoder.Read(buf)
+		if n > 0 {
+			// go-mp3 输出为16bit立体声，每4字节一个样本对
+			data := append(remainder, buf[:n]...)
+			numSamples := len(data) / 4
+			mono := make([]int16, numSamples)
+			for i := 0; i < numSamples; i++ {
+				left := int16(uint16(data[i*4]) | uint16(data[i*4+1])<<8)
+				right := int16(uint16(data[i*4+2]) | uint16(data[i*4+3])<<8)
+				mono[i] = int16((int32(left) + int32(right)) / 2)
+			}
+			remainder = append([]byte(nil), data[numSamples*4:]...)
+
+			if err := onPCM(int16ToBytes(resampler.Resample(mono))); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			if tail := resampler.Flush(); len(tail) > 0 {
+				return onPCM(int16ToBytes(tail))
+			}
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("解码MP3数据失败: %v", readErr)
+		}
+	}
+}
+
+// int16ToBytes 将16bit PCM样本转换为小端字节
+func int16ToBytes(samples []int16) []byte {
+	pcm := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		pcm[i*2] = byte(sample)
+		pcm[i*2+1] = byte(sample >> 8)
+	}
+	return pcm
+}
+
+// streamResampler 对分块到达的PCM数据做线性插值重采样，跨块保留插值位置和未用完的样本，
+// 避免逐块独立重采样在块边界产生的相位跳变和爆音
+type streamResampler struct {
+	ratio float64 // 输入采样率/输出采样率
+	pos   float64 // 下一个输出样本在 tail 中的位置
+	tail  []int16 // 尚未用完的输入样本
+}
+
+func newStreamResampler(inputSampleRate, outputSampleRate int) *streamResampler {
+	return &streamResampler{ratio: float64(inputSampleRate) / float64(outputSampleRate)}
+}
+
+// Resample 重采样一块输入，输出位置需要下一块样本插值时留到下一次调用
+func (r *streamResampler) Resample(input []int16) []int16 {
+	if r.ratio == 1 {
+		return input
+	}
+	buf := append(r.tail, input...)
+	output := make([]int16, 0, int(float64(len(input))/r.ratio)+1)
+	for {
+		index := int(r.pos)
+		if index+1 >= len(buf) {
+			break
+		}
+		fraction := r.pos - float64(index)
+		sample1 := float64(buf[index])
+		sample2 := float64(buf[index+1])
+		output = append(output, int16(sample1+fraction*(sample2-sample1)))
+		r.pos += r.ratio
+	}
+
+	consumed := int(r.pos)
+	if consumed > len(buf) {
+		consumed = len(buf)
+	}
+	r.tail = append(r.tail[:0:0], buf[consumed:]...)
+	r.pos -= float64(consumed)
+	return output
+}
+
+// Flush 输出流结束时剩余的样本
+func (r *streamResampler) Flush() []int16 {
+	var output []int16
+	for index := int(r.pos); index < len(r.tail); index = int(r.pos) {
+		output = append(output, r.tail[index])
+		r.pos += r.ratio
+	}
+	r.tail = nil
+	r.pos = 0
+	return output
+}
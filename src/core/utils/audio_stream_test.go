@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStreamResamplerChunked(t *testing.T) {
+	// 1kHz 正弦波
+	input := make([]int16, 24000)
+	for i := range input {
+		input[i] = int16(10000 * math.Sin(2*math.Pi*1000*float64(i)/24000))
+	}
+
+	tests := []struct {
+		name       string
+		inputRate  int
+		outputRate int
+		chunkSize  int
+	}{
+		{name: "24k转16k", inputRate: 24000, outputRate: 16000, chunkSize: 1152},
+		{name: "24k转16k奇数分块", inputRate: 24000, outputRate: 16000, chunkSize: 7},
+		{name: "22.05k转16k", inputRate: 22050, outputRate: 16000, chunkSize: 1152},
+		{name: "16k转24k", inputRate: 16000, outputRate: 24000, chunkSize: 333},
+		{name: "采样率相同", inputRate: 16000, outputRate: 16000, chunkSize: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whole := newStreamResampler(tt.inputRate, tt.outputRate)
+			want := append(whole.Resample(input), whole.Flush()...)
+
+			chunked := newStreamResampler(tt.inputRate, tt.outputRate)
+			var got []int16
+			for start := 0; start < len(input); start += tt.chunkSize {
+				end := start + tt.chunkSize
+				if end > len(input) {
+					end = len(input)
+				}
+				got = append(got, chunked.Resample(input[start:end])...)
+			}
+			got = append(got, chunked.Flush()...)
+
+			expectedLength := int(math.Ceil(float64(len(input)) * float64(tt.outputRate) / float64(tt.inputRate)))
+			if len(got) != len(want) || math.Abs(float64(len(got)-expectedLength)) > 1 {
+				t.Fatalf("分块输出 %d 个样本，整体输出 %d 个，期望约 %d 个", len(got), len(want), expectedLength)
+			}
+			for i := range want {
+				if diff := int(got[i]) - int(want[i]); diff > 1 || diff < -1 {
+					t.Fatalf("第 %d 个样本分块结果 %d 与整体结果 %d 不一致", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"xiaozhi-server-go/src/core/pool"
 	"xiaozhi-server-go/src/core/utils"
@@ -19,6 +20,9 @@ type ConnectionContext struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
 	closed      int32 // 原子操作标志，0=活跃，1=已关闭
+
+	memoryCtx context.Context // 服务的上下文，限制连接关闭后后台保存会话记忆的时间
+	memoryWG  *sync.WaitGroup // 后台保存会话记忆的协程
 }
 
 // NewConnectionContext 创建新的连接上下文
@@ -107,6 +111,11 @@ func (c *ConnectionContext) Close() error {
 		}
 	}
 
+	// 连接和资源释放后，在后台总结并保存本次会话记忆
+	if c.handler != nil && c.memoryWG != nil {
+		c.handler.saveMemory(c.memoryCtx, c.memoryWG)
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("关闭连接时发生错误: %v", errs)
 	}
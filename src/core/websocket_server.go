@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/auth"
 	"xiaozhi-server-go/src/core/pool"
 	"xiaozhi-server-go/src/core/utils"
 	"xiaozhi-server-go/src/task"
@@ -22,8 +23,11 @@ type WebSocketServer struct {
 	upgrader          Upgrader
 	logger            *utils.Logger
 	taskMgr           *task.TaskManager
-	poolManager       *pool.PoolManager // 替换providers
-	activeConnections sync.Map          // 存储 clientID -> *ConnectionContext
+	poolManager       *pool.PoolManager     // 替换providers
+	verifier          *auth.RequestVerifier // 连接认证，未启用认证时为nil
+	activeConnections sync.Map              // 存储 clientID -> *ConnectionContext
+	ctx               context.Context       // 服务运行期间的上下文，Start时设置
+	memoryWG          sync.WaitGroup        // 连接关闭后后台保存会话记忆的协程
 }
 
 // Upgrader WebSocket升级器接口
@@ -53,6 +57,9 @@ func NewWebSocketServer(config *configs.Config, logger *utils.Logger) (*WebSocke
 		return nil, fmt.Errorf("初始化资源池管理器失败: %v", err)
 	}
 	ws.poolManager = poolManager
+	if config.Server.Auth.Enabled {
+		ws.verifier = auth.NewRequestVerifier(config)
+	}
 	return ws, nil
 }
 
@@ -64,6 +71,9 @@ func (ws *WebSocketServer) Start(ctx context.Context) error {
 		return fmt.Errorf("资源池管理器未初始化")
 	}
 
+	ws.ctx = ctx
+	ws.poolManager.StartHealthMonitor(ctx)
+
 	addr := fmt.Sprintf("%s:%d", ws.config.Server.IP, ws.config.Server.Port)
 
 	mux := http.NewServeMux()
@@ -141,6 +151,9 @@ func (ws *WebSocketServer) Stop() error {
 			return true
 		})
 
+		// 服务上下文已取消，未完成的会话记忆保存会很快放弃，等待其结束后再关闭资源池
+		ws.memoryWG.Wait()
+
 		// 关闭资源池
 		if ws.poolManager != nil {
 			ws.poolManager.Close()
@@ -156,6 +169,19 @@ func (ws *WebSocketServer) Stop() error {
 
 // handleWebSocket 处理WebSocket连接
 func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// 升级前完成认证，失败时直接返回HTTP错误
+	var deviceID string
+	if ws.verifier != nil {
+		var err error
+		deviceID, err = ws.verifier.Verify(r)
+		if err != nil {
+			ws.logger.Warn("WebSocket认证失败: %v, 来源: %s", err, r.RemoteAddr)
+			http.Error(w, err.Error(), auth.StatusCode(err))
+			return
+		}
+		ws.logger.Debug("WebSocket认证通过，设备ID: %s", deviceID)
+	}
+
 	conn, err := ws.upgrader.Upgrade(w, r)
 	if err != nil {
 		ws.logger.Error(fmt.Sprintf("WebSocket升级失败: %v", err))
@@ -172,14 +198,23 @@ func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// 连接使用建立时的配置快照，运行时修改的配置对新连接生效
+	config := configs.Current()
+	if config == nil {
+		config = ws.config
+	}
+
 	connCtx, connCancel := context.WithCancel(context.Background())
 	// 创建新的连接处理器
-	handler := NewConnectionHandler(ws.config, providerSet, ws.logger, r, connCtx)
+	handler := NewConnectionHandler(config, providerSet, ws.logger, r, connCtx, deviceID)
 
 	connContext := NewConnectionContext(handler, providerSet, ws.poolManager, clientID, ws.logger, conn, connCtx, connCancel)
+	connContext.memoryCtx = ws.ctx
+	connContext.memoryWG = &ws.memoryWG
 
 	// 设置TaskManager的回调（使用安全回调）
 	handler.taskMgr = ws.taskMgr
+	handler.poolManager = ws.poolManager
 	handler.SetTaskCallback(connContext.CreateSafeCallback())
 
 	// 存储连接上下文
@@ -209,6 +244,37 @@ func (ws *WebSocketServer) GetPoolStats() map[string]map[string]int {
 	return ws.poolManager.GetDetailedStats()
 }
 
+// GetHealthReport 获取各模块提供者的健康报告
+func (ws *WebSocketServer) GetHealthReport() pool.HealthReport {
+	if ws.poolManager == nil {
+		return pool.HealthReport{Status: "unavailable", Live: true}
+	}
+	return ws.poolManager.HealthReport()
+}
+
+// RebuildPool 按新配置重建指定模块的资源池，新连接将使用新的提供者
+func (ws *WebSocketServer) RebuildPool(module string, config *configs.Config) error {
+	if ws.poolManager == nil {
+		return fmt.Errorf("资源池管理器未初始化")
+	}
+	return ws.poolManager.RebuildPool(module, config)
+}
+
+// ResetProviderPool 关闭指定提供者按需创建的资源池
+func (ws *WebSocketServer) ResetProviderPool(module, name string) {
+	if ws.poolManager != nil {
+		ws.poolManager.ResetProviderPool(module, name)
+	}
+}
+
+// GetTaskQueueDepth 获取任务管理器中排队和定时等待的任务数
+func (ws *WebSocketServer) GetTaskQueueDepth() (queued, scheduled int) {
+	if ws.taskMgr == nil {
+		return 0, 0
+	}
+	return ws.taskMgr.QueueDepth()
+}
+
 // GetActiveConnectionsCount 获取活跃连接数
 func (ws *WebSocketServer) GetActiveConnectionsCount() int {
 	count := 0
@@ -218,3 +284,8 @@ func (ws *WebSocketServer) GetActiveConnectionsCount() int {
 	})
 	return count
 }
+
+// GetTaskManager 获取异步任务管理器
+func (ws *WebSocketServer) GetTaskManager() *task.TaskManager {
+	return ws.taskMgr
+}
@@ -35,7 +35,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "设备上传信息后，返回最新固件版本和下载地址",
+                "description": "设备上传信息后，返回最新固件版本和下载地址，启用认证时同时下发WebSocket连接token",
                 "consumes": [
                     "application/json"
                 ],
@@ -76,6 +76,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/ota.ErrorResponse"
                         }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ota.ErrorResponse"
+                        }
                     }
                 }
             },
@@ -180,6 +186,10 @@ const docTemplate = `{
                 "websocket": {
                     "type": "object",
                     "properties": {
+                        "token": {
+                            "type": "string",
+                            "example": "eyJhbGciOiJIUzI1NiIs..."
+                        },
                         "url": {
                             "type": "string",
                             "example": "wss://example.com/ota"
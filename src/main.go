@@ -7,11 +7,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
-	"reflect"
 	"strconv"
 	"syscall"
 	"time"
@@ -20,9 +20,16 @@ import (
 	"xiaozhi-server-go/src/configs/database"
 	cfg "xiaozhi-server-go/src/configs/server"
 	"xiaozhi-server-go/src/core"
+	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/core/device"
+	"xiaozhi-server-go/src/core/metrics"
+	"xiaozhi-server-go/src/core/push"
+	"xiaozhi-server-go/src/core/reminder"
 	"xiaozhi-server-go/src/core/utils"
 	_ "xiaozhi-server-go/src/docs"
+	"xiaozhi-server-go/src/mcpserver"
 	"xiaozhi-server-go/src/ota"
+	"xiaozhi-server-go/src/task"
 	"xiaozhi-server-go/src/vision"
 
 	swaggerFiles "github.com/swaggo/files"
@@ -37,6 +44,8 @@ import (
 	_ "xiaozhi-server-go/src/core/providers/tts/doubao"
 	_ "xiaozhi-server-go/src/core/providers/tts/edge"
 	_ "xiaozhi-server-go/src/core/providers/tts/gosherpa"
+	_ "xiaozhi-server-go/src/core/providers/vad/energy"
+	_ "xiaozhi-server-go/src/core/providers/vad/webrtc"
 	_ "xiaozhi-server-go/src/core/providers/vlllm/ollama"
 	_ "xiaozhi-server-go/src/core/providers/vlllm/openai"
 
@@ -45,6 +54,14 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// mcpStdout 通过标准输入输出提供MCP服务时使用的原始标准输出
+var mcpStdout *os.File
+
+const (
+	bindAttemptLimit  = 5                // 每个来源IP在窗口内允许的激活码错误次数
+	bindAttemptWindow = 10 * time.Minute // 与激活码有效期一致
+)
+
 func LoadConfigAndLogger() (*configs.Config, *utils.Logger, error) {
 	// 加载配置,默认使用.config.yaml
 	config, configPath, err := configs.LoadConfig()
@@ -52,6 +69,13 @@ func LoadConfigAndLogger() (*configs.Config, *utils.Logger, error) {
 		return nil, nil, err
 	}
 
+	// 通过标准输入输出提供MCP服务时，标准输出只能写入协议消息，控制台输出改为标准错误
+	if config.MCPServer.Stdio {
+		mcpStdout = os.Stdout
+		os.Stdout = os.Stderr
+		gin.DefaultWriter = os.Stderr
+	}
+
 	// 初始化日志系统
 	logger, err := utils.NewLogger(config)
 	if err != nil {
@@ -96,7 +120,7 @@ func StartWSServer(config *configs.Config, logger *utils.Logger, g *errgroup.Gro
 	return wsServer, nil
 }
 
-func StartHttpServer(config *configs.Config, logger *utils.Logger, g *errgroup.Group, groupCtx context.Context) (*http.Server, error) {
+func StartHttpServer(config *configs.Config, logger *utils.Logger, wsServer *core.WebSocketServer, g *errgroup.Group, groupCtx context.Context) (*http.Server, error) {
 	// 初始化Gin引擎
 	if config.Log.LogLevel == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -108,8 +132,10 @@ func StartHttpServer(config *configs.Config, logger *utils.Logger, g *errgroup.G
 
 	// API路由全部挂载到/api前缀下
 	apiGroup := router.Group("/api")
+	// 管理接口需要携带管理员令牌
+	adminAuth := auth.AdminMiddleware(func() string { return config.Server.Auth.AdminToken })
 	// 启动OTA服务
-	otaService := ota.NewDefaultOTAService(config.Web.Websocket)
+	otaService := ota.NewDefaultOTAService(config)
 	if err := otaService.Start(groupCtx, router, apiGroup); err != nil {
 		logger.Error("OTA 服务启动失败", err)
 		return nil, err
@@ -131,110 +157,317 @@ func StartHttpServer(config *configs.Config, logger *utils.Logger, g *errgroup.G
 		logger.Error("配置服务初始化失败 %v", err)
 		return nil, err
 	}
+	cfgServer.SetPoolRebuilder(wsServer)
 	if err := cfgServer.Start(groupCtx, router, apiGroup); err != nil {
 		logger.Error("配置服务启动失败", err)
 		return nil, err
 	}
 
-	// 注册 /api/push 路由
-	apiGroup.POST("/push", func(c *gin.Context) {
+	// 启动MCP服务端
+	if config.MCPServer.Enabled || config.MCPServer.Stdio {
+		mcpService, err := mcpserver.NewDefaultMCPService(config, logger)
+		if err != nil {
+			logger.Error("MCP 服务初始化失败 %v", err)
+			return nil, err
+		}
+		if config.MCPServer.Enabled {
+			if err := mcpService.Start(groupCtx, router, apiGroup); err != nil {
+				logger.Error("MCP 服务启动失败: %v", err)
+				return nil, err
+			}
+		}
+		if config.MCPServer.Stdio && mcpStdout != nil {
+			g.Go(func() error {
+				if err := mcpService.ServeStdio(groupCtx, os.Stdin, mcpStdout); err != nil {
+					logger.Error("MCP 标准输入输出服务运行失败: %v", err)
+				}
+				return nil
+			})
+		}
+	}
+
+	// 注册 /api/push 路由：按设备排队推送，设备离线时在下次连接后投递
+	// audio_path 为推送音频目录(push.audio_dir)下的相对路径
+	apiGroup.POST("/push", adminAuth, func(c *gin.Context) {
 		var req struct {
-			SessionID string `json:"session_id"`
-			ID        string `json:"id"`
-			Text      string `json:"text"`
+			DeviceID   string `json:"device_id"`
+			SessionID  string `json:"session_id"`
+			ID         string `json:"id"`
+			Type       string `json:"type"` // text/audio/music，默认text
+			Text       string `json:"text"`
+			AudioPath  string `json:"audio_path"`
+			Music      string `json:"music"`
+			Priority   string `json:"priority"` // interrupt/idle，默认idle
+			TTLSeconds int    `json:"ttl_seconds"`
 		}
 		if err := c.BindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "bad request"})
 			return
 		}
-		fmt.Println("当前MacSessionMap keys:", reflect.ValueOf(core.MacSessionMap).MapKeys())
-		fmt.Println("收到推送请求，id:", req.ID)
-		sessionID := req.SessionID
-		if sessionID == "" && req.ID != "" {
-			core.MacSessionMapLock.RLock()
-			sessionID = core.MacSessionMap[req.ID]
-			core.MacSessionMapLock.RUnlock()
-			if sessionID == "" {
-				c.JSON(404, gin.H{"error": "id not found"})
-				return
-			}
+		deviceID := resolvePushDevice(req.DeviceID, req.SessionID, req.ID)
+		if deviceID == "" {
+			c.JSON(404, gin.H{"error": "device not found"})
+			return
 		}
-		core.WsConnMapLock.RLock()
-		handler, ok := core.WsConnMap[sessionID]
-		core.WsConnMapLock.RUnlock()
-		if !ok {
-			c.JSON(404, gin.H{"error": "session not found"})
+
+		pushType, content := req.Type, req.Text
+		switch {
+		case req.AudioPath != "" && (pushType == "" || pushType == push.TypeAudio):
+			pushType, content = push.TypeAudio, req.AudioPath
+		case req.Music != "" && (pushType == "" || pushType == push.TypeMusic):
+			pushType, content = push.TypeMusic, req.Music
+		}
+		msg, err := push.Default().Enqueue(push.Request{
+			DeviceID: deviceID,
+			Type:     pushType,
+			Content:  content,
+			Priority: req.Priority,
+			TTL:      time.Duration(req.TTLSeconds) * time.Second,
+		})
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
-		handler.SpeakAndPlay(req.Text, 1, handler.GetTalkRound())
-		c.JSON(200, gin.H{"status": "ok"})
+		c.JSON(200, gin.H{"status": "ok", "message_id": msg.MessageID, "state": msg.Status})
+	})
+
+	// 查询推送状态
+	apiGroup.GET("/push/:message_id", adminAuth, func(c *gin.Context) {
+		msg, err := push.Default().Get(c.Param("message_id"))
+		if errors.Is(err, push.ErrMessageNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, msg)
+	})
+
+	// 查询设备最近的推送记录
+	apiGroup.GET("/devices/:device_id/push", adminAuth, func(c *gin.Context) {
+		messages, err := push.Default().List(c.Param("device_id"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"messages": messages})
+	})
+
+	// 查询客户端最近的异步任务
+	apiGroup.GET("/clients/:client_id/tasks", adminAuth, func(c *gin.Context) {
+		tasks, err := wsServer.GetTaskManager().ListTasks(c.Param("client_id"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"tasks": tasks})
+	})
+
+	// 取消客户端等待执行的任务
+	apiGroup.DELETE("/clients/:client_id/tasks/:task_id", adminAuth, func(c *gin.Context) {
+		err := wsServer.GetTaskManager().CancelTask(c.Param("client_id"), c.Param("task_id"))
+		switch {
+		case errors.Is(err, task.ErrTaskNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, task.ErrTaskNotCancellable):
+			c.JSON(409, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(500, gin.H{"error": err.Error()})
+		default:
+			c.JSON(200, gin.H{"status": "ok", "message": "任务已取消"})
+		}
 	})
 
 	// 注册设备管理 API
-	// 获取设备列表
-	apiGroup.GET("/devices", func(c *gin.Context) {
-		core.WsConnMapLock.RLock()
-		devices := make([]map[string]interface{}, 0)
-		for sessionID, handler := range core.WsConnMap {
-			device := map[string]interface{}{
-				"session_id":  sessionID,
-				"device_id":   handler.GetDeviceID(),
-				"client_id":   handler.GetClientID(),
-				"device_name": handler.GetHeaders()["Device-Name"],
-				"status":      "online",
-				"last_seen":   time.Now().Format("2006-01-02 15:04:05"),
-			}
-			devices = append(devices, device)
+	// 获取设备列表，status=online|offline 按在线状态过滤
+	apiGroup.GET("/devices", adminAuth, func(c *gin.Context) {
+		var online *bool
+		switch c.Query("status") {
+		case "online":
+			online = new(bool)
+			*online = true
+		case "offline":
+			online = new(bool)
+		case "":
+		default:
+			c.JSON(400, gin.H{"error": "status 仅支持 online 或 offline"})
+			return
+		}
+		devices, err := device.Default().List(online)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
 		}
-		core.WsConnMapLock.RUnlock()
 		c.JSON(200, gin.H{"devices": devices})
 	})
 
-	// 绑定设备
-	apiGroup.POST("/devices/bind", func(c *gin.Context) {
+	// 获取离线设备列表
+	apiGroup.GET("/devices/offline", adminAuth, func(c *gin.Context) {
+		devices, err := device.Default().List(new(bool))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"devices": devices})
+	})
+
+	// 绑定设备：管理员输入设备播报的激活码确认绑定，按来源IP限制激活码错误次数
+	bindLimiter := auth.NewAttemptLimiter(bindAttemptLimit, bindAttemptWindow)
+	apiGroup.POST("/devices/bind", adminAuth, func(c *gin.Context) {
+		if allowed, retryAfter := bindLimiter.Allow(c.ClientIP()); !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.JSON(429, gin.H{"error": "激活码错误次数过多，请稍后再试"})
+			return
+		}
 		var req struct {
+			Code       string `json:"code"`
 			DeviceID   string `json:"device_id"`
-			ClientID   string `json:"client_id"`
 			DeviceName string `json:"device_name"`
-			Token      string `json:"token"`
+			Owner      string `json:"owner"`
 		}
-		if err := c.BindJSON(&req); err != nil {
+		if err := c.BindJSON(&req); err != nil || req.Code == "" {
 			c.JSON(400, gin.H{"error": "bad request"})
 			return
 		}
-		// 这里可以添加设备绑定逻辑，比如保存到数据库或配置文件
-		c.JSON(200, gin.H{"status": "ok", "message": "设备绑定成功"})
+		if database.DB == nil {
+			c.JSON(503, gin.H{"error": "数据库未初始化"})
+			return
+		}
+
+		store := auth.NewBindingStore(database.DB)
+		if req.DeviceID != "" {
+			binding, err := store.Get(req.DeviceID)
+			if err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			if binding == nil || binding.ActivationCode != req.Code {
+				bindLimiter.Fail(c.ClientIP())
+				c.JSON(400, gin.H{"error": auth.ErrInvalidActivationCode.Error()})
+				return
+			}
+		}
+		binding, err := store.Confirm(req.Code, req.Owner, req.DeviceName)
+		if errors.Is(err, auth.ErrInvalidActivationCode) {
+			bindLimiter.Fail(c.ClientIP())
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		bindLimiter.Reset(c.ClientIP())
+
+		setOnlineDeviceVerified(binding.DeviceID, true)
+		logger.Info("设备 %s 已绑定，所有者: %s", binding.DeviceID, binding.Owner)
+		c.JSON(200, gin.H{"status": "ok", "message": "设备绑定成功", "binding": binding})
 	})
 
 	// 解绑设备
-	apiGroup.DELETE("/devices/unbind/:device_id", func(c *gin.Context) {
-		// 这里可以添加设备解绑逻辑
+	apiGroup.DELETE("/devices/unbind/:device_id", adminAuth, func(c *gin.Context) {
+		deviceID := c.Param("device_id")
+		if database.DB == nil {
+			c.JSON(503, gin.H{"error": "数据库未初始化"})
+			return
+		}
+		err := auth.NewBindingStore(database.DB).Unbind(deviceID)
+		if errors.Is(err, auth.ErrBindingNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		setOnlineDeviceVerified(deviceID, false)
+		logger.Info("设备 %s 已解绑", deviceID)
 		c.JSON(200, gin.H{"status": "ok", "message": "设备解绑成功"})
 	})
 
+	// 获取设备绑定列表
+	apiGroup.GET("/devices/bindings", adminAuth, func(c *gin.Context) {
+		if database.DB == nil {
+			c.JSON(503, gin.H{"error": "数据库未初始化"})
+			return
+		}
+		bindings, err := auth.NewBindingStore(database.DB).List()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"bindings": bindings})
+	})
+
 	// 获取设备状态
-	apiGroup.GET("/devices/:device_id/status", func(c *gin.Context) {
+	apiGroup.GET("/devices/:device_id/status", adminAuth, func(c *gin.Context) {
+		status, err := device.Default().Get(c.Param("device_id"))
+		if err != nil {
+			c.JSON(deviceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, status)
+	})
+
+	// 获取设备连接历史
+	apiGroup.GET("/devices/:device_id/history", adminAuth, func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		sessions, err := device.Default().History(c.Param("device_id"), limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"sessions": sessions})
+	})
+
+	// 中止设备当前播报
+	apiGroup.POST("/devices/:device_id/abort", adminAuth, func(c *gin.Context) {
 		deviceID := c.Param("device_id")
-		core.WsConnMapLock.RLock()
-		var deviceStatus map[string]interface{}
-		for sessionID, handler := range core.WsConnMap {
-			if handler.GetDeviceID() == deviceID {
-				deviceStatus = map[string]interface{}{
-					"session_id": sessionID,
-					"device_id":  handler.GetDeviceID(),
-					"client_id":  handler.GetClientID(),
-					"status":     "online",
-					"last_seen":  time.Now().Format("2006-01-02 15:04:05"),
-				}
-				break
-			}
+		if err := device.Default().Abort(deviceID); err != nil {
+			c.JSON(deviceErrorStatus(err), gin.H{"error": err.Error()})
+			return
 		}
-		core.WsConnMapLock.RUnlock()
-		if deviceStatus == nil {
-			c.JSON(404, gin.H{"error": "device not found"})
+		logger.Info("已向设备 %s 发送中止指令", deviceID)
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	// 强制断开设备连接
+	apiGroup.POST("/devices/:device_id/disconnect", adminAuth, func(c *gin.Context) {
+		deviceID := c.Param("device_id")
+		if err := device.Default().Kick(deviceID); err != nil {
+			c.JSON(deviceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info("设备 %s 已被强制断开", deviceID)
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	// 健康检查：完整报告，必需的提供者不可用时返回503
+	apiGroup.GET("/health", func(c *gin.Context) {
+		report := wsServer.GetHealthReport()
+		code := http.StatusOK
+		if !report.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, report)
+	})
+
+	// 存活检查：进程能响应即存活，不依赖外部提供者
+	apiGroup.GET("/health/live", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// 就绪检查：ASR/LLM/TTS均可用时才就绪
+	apiGroup.GET("/health/ready", func(c *gin.Context) {
+		report := wsServer.GetHealthReport()
+		if !report.Ready {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": report.Status, "ready": false})
 			return
 		}
-		c.JSON(200, deviceStatus)
+		c.JSON(http.StatusOK, gin.H{"status": report.Status, "ready": true})
 	})
 
 	// HTTP Server（支持优雅关机）
@@ -246,6 +479,10 @@ func StartHttpServer(config *configs.Config, logger *utils.Logger, g *errgroup.G
 	// 注册Swagger文档路由
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// 注册Prometheus指标路由
+	metrics.RegisterServerStats(wsServer)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	g.Go(func() error {
 		logger.Info(fmt.Sprintf("Gin 服务已启动，访问地址: http://0.0.0.0:%d", config.Web.Port))
 
@@ -277,6 +514,50 @@ func StartHttpServer(config *configs.Config, logger *utils.Logger, g *errgroup.G
 	return httpServer, nil
 }
 
+// setOnlineDeviceVerified 同步在线设备连接的认证状态
+func setOnlineDeviceVerified(deviceID string, verified bool) {
+	core.WsConnMapLock.RLock()
+	defer core.WsConnMapLock.RUnlock()
+	for _, handler := range core.WsConnMap {
+		if handler.GetDeviceID() == deviceID {
+			handler.SetDeviceVerified(verified)
+		}
+	}
+}
+
+// resolvePushDevice 解析推送目标设备，兼容按会话ID或client-id/mac推送
+func resolvePushDevice(deviceID, sessionID, id string) string {
+	if deviceID != "" {
+		return deviceID
+	}
+	if sessionID == "" && id != "" {
+		core.MacSessionMapLock.RLock()
+		sessionID = core.MacSessionMap[id]
+		core.MacSessionMapLock.RUnlock()
+		if sessionID == "" {
+			// 离线设备直接以id作为设备ID排队
+			return id
+		}
+	}
+	core.WsConnMapLock.RLock()
+	defer core.WsConnMapLock.RUnlock()
+	if handler, ok := core.WsConnMap[sessionID]; ok {
+		return handler.GetDeviceID()
+	}
+	return ""
+}
+
+// deviceErrorStatus 根据设备注册表错误返回HTTP状态码
+func deviceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, device.ErrDeviceNotFound):
+		return 404
+	case errors.Is(err, device.ErrDeviceOffline):
+		return 409
+	}
+	return 500
+}
+
 func GracefulShutdown(cancel context.CancelFunc, logger *utils.Logger, g *errgroup.Group) {
 	// 监听系统信号
 	sigChan := make(chan os.Signal, 1)
@@ -310,13 +591,29 @@ func GracefulShutdown(cancel context.CancelFunc, logger *utils.Logger, g *errgro
 }
 
 func startServices(config *configs.Config, logger *utils.Logger, g *errgroup.Group, groupCtx context.Context) error {
+	// 启动推送定时投递
+	go push.Default().Start(groupCtx)
+
 	// 启动 WebSocket 服务
-	if _, err := StartWSServer(config, logger, g, groupCtx); err != nil {
+	wsServer, err := StartWSServer(config, logger, g, groupCtx)
+	if err != nil {
 		return fmt.Errorf("启动 WebSocket 服务失败: %w", err)
 	}
 
+	// 任务持久化到数据库，恢复重启前未完成的任务
+	if database.DB != nil {
+		if err := wsServer.GetTaskManager().UseStore(task.NewGormStore(database.DB)); err != nil {
+			logger.Error(fmt.Sprintf("恢复异步任务失败: %v", err))
+		}
+	}
+
+	// 恢复数据库中未触发的提醒
+	if err := reminder.Default().Start(groupCtx, wsServer.GetTaskManager()); err != nil {
+		logger.Error(fmt.Sprintf("启动提醒服务失败: %v", err))
+	}
+
 	// 启动 Http 服务
-	if _, err := StartHttpServer(config, logger, g, groupCtx); err != nil {
+	if _, err := StartHttpServer(config, logger, wsServer, g, groupCtx); err != nil {
 		return fmt.Errorf("启动 Http 服务失败: %w", err)
 	}
 
@@ -345,6 +642,30 @@ func main() {
 		return
 	}
 
+	// 加载通过配置接口保存的运行时配置
+	if err := cfg.ApplyPersistedConfig(db, config, logger); err != nil {
+		logger.Error(fmt.Sprintf("加载运行时配置失败: %v", err))
+	}
+	configs.SetCurrent(config)
+
+	// 同步配置文件中预置的绑定设备
+	if err := auth.NewBindingStore(db).SyncStaticDevices(config.DeviceBinding.Devices); err != nil {
+		logger.Error(fmt.Sprintf("同步预置绑定设备失败: %v", err))
+	}
+
+	// 初始化设备注册表
+	if err := device.Default().Init(db, logger); err != nil {
+		logger.Error(fmt.Sprintf("初始化设备注册表失败: %v", err))
+	}
+
+	// 加载离线期间排队的推送
+	if err := push.Default().Init(db, logger); err != nil {
+		logger.Error(fmt.Sprintf("初始化推送管理器失败: %v", err))
+	}
+
+	// 设置提醒的持久化数据库
+	reminder.Default().Init(db, logger)
+
 	// 创建可取消的上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
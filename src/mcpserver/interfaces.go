@@ -0,0 +1,16 @@
+package mcpserver
+
+import (
+	"context"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MCPService 定义 MCP 服务端接口
+type MCPService interface {
+	// 将 MCP 的 HTTP 端点注册到 engine 与 apiGroup
+	Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error
+	// 通过标准输入输出提供服务，直到 ctx 取消或输入结束
+	ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error
+}
@@ -0,0 +1,99 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	serverName    = "xiaozhi-server"
+	serverVersion = "1.0.0"
+)
+
+// DefaultMCPService 将设备控制能力以 MCP 工具的形式开放给外部智能体，
+// 支持 SSE（/api/mcp/sse 与 /api/mcp/message）和标准输入输出两种传输方式
+//
+// HTTP 只提供 SSE 传输：依赖的 mcp-go v0.29.0 实现的是 2024-11-05 版协议，服务端没有 Streamable HTTP 实现，
+// 2025-03-26 版协议为兼容旧服务端，约定 Streamable HTTP 客户端在 POST 初始化失败后回退到 SSE，按该约定实现的新客户端也能接入
+type DefaultMCPService struct {
+	logger *utils.Logger
+	config *configs.Config
+	server *server.MCPServer
+}
+
+// NewDefaultMCPService 构造函数
+func NewDefaultMCPService(config *configs.Config, logger *utils.Logger) (*DefaultMCPService, error) {
+	service := &DefaultMCPService{
+		logger: logger,
+		config: config,
+		server: server.NewMCPServer(serverName, serverVersion, server.WithToolCapabilities(false)),
+	}
+	service.registerTools()
+	return service, nil
+}
+
+// Start 注册 SSE 端点，ctx 取消时关闭所有 SSE 会话
+// 未配置访问令牌时拒绝启动，避免设备控制工具在无认证的情况下对外开放
+func (s *DefaultMCPService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if s.config.MCPServer.Token == "" {
+		return fmt.Errorf("开放MCP服务端HTTP端点时必须配置 mcp_server.token")
+	}
+
+	basePath := strings.TrimSuffix(apiGroup.BasePath(), "/") + "/mcp"
+	sseServer := server.NewSSEServer(s.server,
+		server.WithStaticBasePath(basePath),
+		server.WithUseFullURLForMessageEndpoint(false),
+		server.WithAppendQueryToMessageEndpoint(),
+		server.WithKeepAlive(true),
+	)
+
+	group := apiGroup.Group("/mcp", s.authMiddleware)
+	group.GET("/sse", gin.WrapH(sseServer))
+	group.POST("/message", gin.WrapH(sseServer))
+
+	go func() {
+		<-ctx.Done()
+		if err := sseServer.Shutdown(context.Background()); err != nil {
+			s.logger.Error("关闭MCP SSE服务失败: %v", err)
+		}
+	}()
+
+	s.logger.Info("MCP服务端已启动，SSE地址: %s/sse", basePath)
+	return nil
+}
+
+// ServeStdio 通过标准输入输出提供 MCP 服务
+func (s *DefaultMCPService) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	stdioServer := server.NewStdioServer(s.server)
+	s.logger.Info("MCP服务端已通过标准输入输出启动")
+	err := stdioServer.Listen(ctx, in, out)
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// authMiddleware 校验 HTTP 端点的访问令牌，未配置令牌时拒绝所有请求
+func (s *DefaultMCPService) authMiddleware(c *gin.Context) {
+	token := s.config.MCPServer.Token
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "未配置访问令牌"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(auth.RequestToken(c.Request)), []byte(token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的访问令牌"})
+		return
+	}
+	c.Next()
+}
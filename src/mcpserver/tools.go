@@ -0,0 +1,196 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/device"
+	"xiaozhi-server-go/src/core/push"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sashabaranov/go-openai"
+)
+
+// deviceSession 在线设备会话中 MCP 工具需要的能力，由WebSocket连接处理器实现
+type deviceSession interface {
+	LastTranscript() device.Transcript
+	DeviceTools() []openai.Tool
+	CallDeviceTool(ctx context.Context, name string, args map[string]interface{}) (interface{}, error)
+}
+
+// registerTools 注册开放给外部智能体的工具
+func (s *DefaultMCPService) registerTools() {
+	s.server.AddTool(mcp.NewTool("list_devices",
+		mcp.WithDescription("列出设备及其在线状态、对话轮次和拾音状态"),
+		mcp.WithString("status", mcp.Description("按在线状态过滤，不填返回全部设备"), mcp.Enum("online", "offline")),
+	), s.handleListDevices)
+
+	s.server.AddTool(mcp.NewTool("speak_text",
+		mcp.WithDescription("让设备播报一段文本，设备离线时在下次连接后播报"),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID")),
+		mcp.WithString("text", mcp.Required(), mcp.Description("要播报的文本")),
+		mcp.WithString("priority", mcp.Description("interrupt 立即打断当前播报，idle 等待设备空闲，默认 idle"), mcp.Enum(push.PriorityInterrupt, push.PriorityIdle)),
+		mcp.WithNumber("ttl_seconds", mcp.Description("消息有效期（秒），不填使用默认有效期")),
+	), s.pushHandler(push.TypeText, "text"))
+
+	s.server.AddTool(mcp.NewTool("play_music",
+		mcp.WithDescription("让设备播放音乐库中的歌曲"),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID")),
+		mcp.WithString("song", mcp.Required(), mcp.Description("歌曲名")),
+		mcp.WithString("priority", mcp.Description("interrupt 立即打断当前播报，idle 等待设备空闲，默认 idle"), mcp.Enum(push.PriorityInterrupt, push.PriorityIdle)),
+		mcp.WithNumber("ttl_seconds", mcp.Description("消息有效期（秒），不填使用默认有效期")),
+	), s.pushHandler(push.TypeMusic, "song"))
+
+	s.server.AddTool(mcp.NewTool("stop_speaking",
+		mcp.WithDescription("中止在线设备当前的播报"),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID")),
+	), s.handleStopSpeaking)
+
+	s.server.AddTool(mcp.NewTool("get_last_transcript",
+		mcp.WithDescription("读取在线设备最近一轮对话的用户语音识别文本和助手回复"),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID")),
+	), s.handleLastTranscript)
+
+	s.server.AddTool(mcp.NewTool("list_device_tools",
+		mcp.WithDescription("列出在线设备端通过 MCP 上报的工具"),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID")),
+	), s.handleListDeviceTools)
+
+	s.server.AddTool(mcp.NewTool("call_device_tool",
+		mcp.WithDescription("调用在线设备端的 MCP 工具，可先用 list_device_tools 查询可用工具"),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID")),
+		mcp.WithString("name", mcp.Required(), mcp.Description("设备端工具名")),
+		mcp.WithObject("arguments", mcp.Description("工具参数")),
+	), s.handleCallDeviceTool)
+}
+
+func (s *DefaultMCPService) handleListDevices(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var online *bool
+	switch request.GetString("status", "") {
+	case "online":
+		online = new(bool)
+		*online = true
+	case "offline":
+		online = new(bool)
+	}
+	devices, err := device.Default().List(online)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return jsonResult(map[string]interface{}{"devices": devices})
+}
+
+// pushHandler 将播报请求加入设备推送队列，contentKey 为内容参数名
+func (s *DefaultMCPService) pushHandler(pushType, contentKey string) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		deviceID, err := request.RequireString("device_id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		content, err := request.RequireString(contentKey)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		msg, err := push.Default().Enqueue(push.Request{
+			DeviceID: deviceID,
+			Type:     pushType,
+			Content:  content,
+			Priority: request.GetString("priority", ""),
+			TTL:      time.Duration(request.GetFloat("ttl_seconds", 0) * float64(time.Second)),
+		})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		s.logger.Info("MCP客户端向设备 %s 推送%s: %s", deviceID, pushType, content)
+		return jsonResult(map[string]interface{}{"message_id": msg.MessageID, "state": msg.Status})
+	}
+}
+
+func (s *DefaultMCPService) handleStopSpeaking(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	deviceID, err := request.RequireString("device_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := device.Default().Abort(deviceID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcp.NewToolResultText("已中止播报"), nil
+}
+
+func (s *DefaultMCPService) handleLastTranscript(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	session, err := s.session(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return jsonResult(session.LastTranscript())
+}
+
+func (s *DefaultMCPService) handleListDeviceTools(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	session, err := s.session(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	tools := make([]map[string]interface{}, 0)
+	for _, tool := range session.DeviceTools() {
+		if tool.Function == nil {
+			continue
+		}
+		tools = append(tools, map[string]interface{}{
+			"name":        tool.Function.Name,
+			"description": tool.Function.Description,
+			"parameters":  tool.Function.Parameters,
+		})
+	}
+	return jsonResult(map[string]interface{}{"tools": tools})
+}
+
+func (s *DefaultMCPService) handleCallDeviceTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	session, err := s.session(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	name, err := request.RequireString("name")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	args, _ := request.GetArguments()["arguments"].(map[string]interface{})
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	result, err := session.CallDeviceTool(ctx, name, args)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("调用设备工具 %s 失败: %v", name, err)), nil
+	}
+	s.logger.Info("MCP客户端调用设备工具 %s 成功", name)
+	return jsonResult(result)
+}
+
+// session 获取请求中 device_id 对应的在线会话
+func (s *DefaultMCPService) session(request mcp.CallToolRequest) (deviceSession, error) {
+	deviceID, err := request.RequireString("device_id")
+	if err != nil {
+		return nil, err
+	}
+	session, err := device.Default().Session(deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", deviceID, err)
+	}
+	ds, ok := session.(deviceSession)
+	if !ok {
+		return nil, fmt.Errorf("设备 %s 的会话不支持该操作", deviceID)
+	}
+	return ds, nil
+}
+
+func jsonResult(v interface{}) (*mcp.CallToolResult, error) {
+	if text, ok := v.(string); ok {
+		return mcp.NewToolResultText(text), nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("序列化结果失败: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
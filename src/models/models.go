@@ -2,6 +2,8 @@ package models
 
 import (
 	//"gorm.io/gorm"
+	"time"
+
 	"gorm.io/datatypes"
 )
 
@@ -12,10 +14,12 @@ type SystemConfig struct {
 	SelectedTTS      string
 	SelectedLLM      string
 	SelectedVLLLM    string
+	SelectedVAD      string
 	Prompt           string         `gorm:"type:text"`
 	QuickReplyWords  datatypes.JSON // 存储为 JSON 数组
 	DeleteAudio      bool
 	UsePrivateConfig bool
+	Overrides        datatypes.JSON // 通过 /api/cfg 修改过的字段，仅这些字段以数据库配置为准
 }
 
 // 用户
@@ -42,10 +46,127 @@ type UserSetting struct {
 // 模块配置（可选）
 type ModuleConfig struct {
 	ID          uint   `gorm:"primaryKey"`
-	Name        string `gorm:"uniqueIndex;not null"` // 模块名
-	Type        string
+	Name        string `gorm:"uniqueIndex:idx_module_type_name;not null"` // 模块名，同一模块类型下唯一
+	Type        string `gorm:"uniqueIndex:idx_module_type_name"`
 	ConfigJSON  datatypes.JSON
 	Public      bool
 	Description string
 	Enabled     bool
 }
+
+// 设备对话记忆，每台设备保存一份历史会话摘要
+type DeviceMemory struct {
+	ID        uint   `gorm:"primaryKey"`
+	DeviceID  string `gorm:"uniqueIndex;size:64;not null"`
+	Summary   string `gorm:"type:text"`
+	UpdatedAt time.Time
+}
+
+// 设备绑定状态
+const (
+	DeviceBindingPending = "pending" // 已下发激活码，等待管理员确认
+	DeviceBindingBound   = "bound"   // 已绑定
+)
+
+// 设备绑定记录，未绑定设备通过激活码由管理员确认绑定
+type DeviceBinding struct {
+	ID             uint   `gorm:"primaryKey"`
+	DeviceID       string `gorm:"uniqueIndex;size:64;not null"`
+	ClientID       string `gorm:"size:64"`
+	DeviceName     string
+	Owner          string
+	Status         string `gorm:"size:16;index"`
+	ActivationCode string `gorm:"size:6;index"`
+	CodeExpiresAt  time.Time
+	BoundAt        *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// 设备注册信息，记录设备最近一次的连接状态和参数
+type Device struct {
+	ID                 uint   `gorm:"primaryKey"`
+	DeviceID           string `gorm:"uniqueIndex;size:64;not null"`
+	ClientID           string `gorm:"size:64"`
+	DeviceName         string
+	FirmwareVersion    string `gorm:"size:32"`
+	AudioFormat        string `gorm:"size:16"`
+	SampleRate         int
+	Channels           int
+	FrameDuration      int
+	Online             bool   `gorm:"index"`
+	LastSessionID      string `gorm:"size:64"`
+	LastConnectedAt    *time.Time
+	LastDisconnectedAt *time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// 设备连接历史，每次WebSocket会话一条记录
+type DeviceSession struct {
+	ID               uint   `gorm:"primaryKey"`
+	DeviceID         string `gorm:"index;size:64;not null"`
+	SessionID        string `gorm:"index;size:64"`
+	ClientID         string `gorm:"size:64"`
+	FirmwareVersion  string `gorm:"size:32"`
+	ConnectedAt      time.Time
+	DisconnectedAt   *time.Time
+	TalkRounds       int
+	DisconnectReason string
+}
+
+// 主动推送消息，设备离线时持久化，下次连接后投递
+type PushMessage struct {
+	ID          uint   `gorm:"primaryKey"`
+	MessageID   string `gorm:"uniqueIndex;size:36;not null"`
+	DeviceID    string `gorm:"index;size:64;not null"`
+	Type        string `gorm:"size:16"` // text/audio/music
+	Content     string `gorm:"type:text"`
+	Priority    string `gorm:"size:16"` // interrupt/idle
+	Status      string `gorm:"size:16;index"`
+	Error       string
+	ExpiresAt   *time.Time
+	DeliveredAt *time.Time
+	FinishedAt  *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// 定时提醒和周期闹钟，按设备保存，到点后通过推送队列播报
+type Reminder struct {
+	ID          uint   `gorm:"primaryKey"`
+	DeviceID    string `gorm:"index;size:64;not null"`
+	Content     string `gorm:"type:text"`
+	Music       string // 提醒时播放的歌曲名，可为空
+	Cron        string `gorm:"size:64"` // 周期闹钟的cron表达式，为空表示一次性提醒
+	FireAt      time.Time
+	Status      string `gorm:"size:16;index"` // active/done/cancelled
+	TaskID      string `gorm:"size:36"`       // 当前等待触发的定时任务ID
+	LastFiredAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// 异步任务记录，未结束的任务在服务重启后恢复执行
+type TaskRecord struct {
+	ID            uint   `gorm:"primaryKey"`
+	TaskID        string `gorm:"uniqueIndex;size:36;not null"`
+	ClientID      string `gorm:"index;size:64"`
+	Type          string `gorm:"size:64"`
+	Status        string `gorm:"size:16;index"` // pending/running/retrying/complete/failed/cancelled
+	Params        string `gorm:"type:text"`     // JSON编码的任务参数
+	Attempts      int
+	Error         string
+	ScheduledTime *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// 客户端每日任务配额的使用情况
+type TaskQuota struct {
+	ID            uint   `gorm:"primaryKey"`
+	ClientID      string `gorm:"uniqueIndex;size:64;not null"`
+	UsedQuota     int
+	LastResetDate time.Time
+	UpdatedAt     time.Time
+}
@@ -8,6 +8,11 @@ import (
 	"strings"
 	"time"
 
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/core/device"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,8 +27,16 @@ type OtaFirmwareResponse struct {
 		URL     string `json:"url" example:"/ota_bin/1.0.3.bin"`
 	} `json:"firmware"`
 	Websocket struct {
-		URL string `json:"url" example:"wss://example.com/ota"`
+		URL   string `json:"url" example:"wss://example.com/ota"`
+		Token string `json:"token,omitempty" example:"eyJhbGciOiJIUzI1NiIs..."`
 	} `json:"websocket"`
+	Activation *OtaActivation `json:"activation,omitempty"`
+}
+
+// OtaActivation 未绑定设备的激活信息，管理员使用激活码完成绑定后设备才能领取token
+type OtaActivation struct {
+	Code    string `json:"code" example:"123456"`
+	Message string `json:"message" example:"请在管理后台输入验证码 123456 完成绑定"`
 }
 
 // ErrorResponse 定义错误返回结构
@@ -62,6 +75,7 @@ type OtaRequest struct {
 
 // @Summary 上传设备信息获取最新固件
 // @Description 设备上传信息后，返回最新固件版本和下载地址
+// @Description 启用认证时，只为允许列表中或已绑定的设备下发WebSocket连接token，未绑定设备返回激活码
 // @Tags OTA
 // @Accept json
 // @Produce json
@@ -69,8 +83,10 @@ type OtaRequest struct {
 // @Param body body OtaRequest true "请求体"
 // @Success 200 {object} OtaFirmwareResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
 // @Router /ota/ [post]
-func handleOtaPost(c *gin.Context, updateURL string) {
+func handleOtaPost(c *gin.Context, updateURL string, verifier *auth.RequestVerifier, binding configs.DeviceBinding) {
 	deviceID := c.GetHeader("device-id")
 	if deviceID == "" {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "缺少 device-id"})
@@ -83,6 +99,7 @@ func handleOtaPost(c *gin.Context, updateURL string) {
 	}
 
 	version := body.Application.Version
+	device.Default().SetFirmwareVersion(deviceID, version)
 	if version == "" {
 		version = "1.0.0"
 	}
@@ -106,10 +123,70 @@ func handleOtaPost(c *gin.Context, updateURL string) {
 	resp.Firmware.Version = version
 	resp.Firmware.URL = firmwareURL
 	resp.Websocket.URL = updateURL
+	if verifier != nil {
+		registered, activationCode, err := checkDeviceRegistered(verifier, binding, deviceID, c.GetHeader("client-id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: "查询设备绑定失败: " + err.Error()})
+			return
+		}
+		if !registered {
+			if activationCode == "" {
+				c.JSON(http.StatusForbidden, ErrorResponse{Success: false, Message: auth.ErrDeviceNotAllowed.Error()})
+				return
+			}
+			resp.Activation = &OtaActivation{
+				Code:    activationCode,
+				Message: "请在管理后台输入验证码 " + activationCode + " 完成绑定",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		token, err := verifier.IssueToken(deviceID)
+		if err != nil {
+			c.JSON(http.StatusForbidden, ErrorResponse{Success: false, Message: "签发token失败: " + err.Error()})
+			return
+		}
+		resp.Websocket.Token = token
+	}
 
 	c.JSON(http.StatusOK, resp)
 }
 
+// checkDeviceRegistered 判断设备是否可以领取连接token
+// 设备需在认证允许列表中或已绑定；启用自动绑定时直接绑定新设备，启用设备绑定时为未绑定设备返回激活码
+func checkDeviceRegistered(verifier *auth.RequestVerifier, binding configs.DeviceBinding, deviceID, clientID string) (bool, string, error) {
+	if verifier.IsDeviceListed(deviceID) {
+		return true, "", nil
+	}
+	// 无数据库时无法确认绑定关系，拒绝签发
+	if database.DB == nil {
+		return false, "", nil
+	}
+
+	store := auth.NewBindingStore(database.DB)
+	bound, err := store.IsBound(deviceID)
+	if err != nil {
+		return false, "", err
+	}
+	if bound {
+		return true, "", nil
+	}
+	if !binding.Enabled {
+		return false, "", nil
+	}
+	if binding.AutoBind {
+		if _, err := store.Bind(deviceID, clientID, "", ""); err != nil {
+			return false, "", err
+		}
+		return true, "", nil
+	}
+	code, err := store.RequestActivationCode(deviceID, clientID)
+	if err != nil {
+		return false, "", err
+	}
+	return false, code, nil
+}
+
 // @Summary 下载 OTA 固件文件
 // @Description 根据文件名下载 OTA 固件
 // @Tags OTA
@@ -3,23 +3,32 @@ package ota
 import (
 	"context"
 
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/auth"
+
 	"github.com/gin-gonic/gin"
 )
 
 type DefaultOTAService struct {
 	UpdateURL string
+	verifier  *auth.RequestVerifier // 启用认证时用于向设备签发连接token
+	binding   configs.DeviceBinding // 设备绑定配置，决定未绑定设备的处理方式
 }
 
 // NewDefaultOTAService 构造函数
-func NewDefaultOTAService(updateURL string) *DefaultOTAService {
-	return &DefaultOTAService{UpdateURL: updateURL}
+func NewDefaultOTAService(config *configs.Config) *DefaultOTAService {
+	service := &DefaultOTAService{UpdateURL: config.Web.Websocket, binding: config.DeviceBinding}
+	if config.Server.Auth.Enabled {
+		service.verifier = auth.NewRequestVerifier(config)
+	}
+	return service
 }
 
 // Start 注册 OTA 相关路由
 func (s *DefaultOTAService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
 	apiGroup.OPTIONS("/ota/", handleOtaOptions)
 	apiGroup.GET("/ota/", func(c *gin.Context) { handleOtaGet(c, s.UpdateURL) })
-	apiGroup.POST("/ota/", func(c *gin.Context) { handleOtaPost(c, s.UpdateURL) })
+	apiGroup.POST("/ota/", func(c *gin.Context) { handleOtaPost(c, s.UpdateURL, s.verifier, s.binding) })
 
 	engine.GET("/ota_bin/:filename", handleOtaBinDownload)
 
@@ -158,3 +158,26 @@ func (rq *ResourceQuota) ResetQuota(taskType TaskType) {
 	rq.TotalUsedQuota = 0
 
 }
+
+// Usage returns the used daily quota and the date it was last reset
+func (rq *ResourceQuota) Usage() (used int, lastResetDate time.Time) {
+	rq.mu.RLock()
+	defer rq.mu.RUnlock()
+	return rq.TotalUsedQuota, rq.LastResetDate
+}
+
+// restoreUsage 恢复重启前保存的每日配额使用情况
+func (rq *ResourceQuota) restoreUsage(used int, lastResetDate time.Time) {
+	rq.mu.Lock()
+	rq.TotalUsedQuota = used
+	rq.LastResetDate = lastResetDate
+	rq.mu.Unlock()
+	rq.CheckAndResetDailyQuota()
+}
+
+// restoreRunning 恢复的未完成任务计入并发数，不占用新的每日配额
+func (rq *ResourceQuota) restoreRunning() {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+	rq.TotalRunningTasks++
+}
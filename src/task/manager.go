@@ -1,27 +1,47 @@
 package task
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
+
+	"xiaozhi-server-go/src/models"
+)
+
+const (
+	taskRetention = 7 * 24 * time.Hour // 已结束任务记录的保留时长
+	pruneInterval = time.Hour          // 清理已结束任务记录的间隔
+	taskListLimit = 100                // 查询客户端任务的最大条数
 )
 
+// ErrTaskNotCancellable 任务正在执行或已结束，无法取消
+var ErrTaskNotCancellable = errors.New("任务正在执行或已结束，无法取消")
+
 // TaskManager manages async tasks and their execution
 type TaskManager struct {
 	workerPool     *WorkerPool
 	scheduledTasks *ScheduledTasks
 	clientManager  *ClientManager
+	store          TaskStore
+	storeMu        sync.RWMutex
+	stopChan       chan struct{}
 }
 
-// NewTaskManager creates a new TaskManager instance
+// NewTaskManager creates a new TaskManager instance with an in-memory task store
 func NewTaskManager(config ResourceConfig) *TaskManager {
 	tm := &TaskManager{
 		clientManager: NewClientManager(),
+		store:         NewMemoryStore(),
+		stopChan:      make(chan struct{}),
 	}
 
 	tm.workerPool = NewWorkerPool(config, nil, tm.clientManager)
 	tm.scheduledTasks = NewScheduledTasks(tm.workerPool)
 	tm.workerPool.scheduler = tm.scheduledTasks
+	tm.workerPool.onFinished = tm.onTaskFinished
 
 	return tm
 }
@@ -30,12 +50,84 @@ func NewTaskManager(config ResourceConfig) *TaskManager {
 func (tm *TaskManager) Start() {
 	tm.workerPool.Start()
 	tm.scheduledTasks.Start()
+	go tm.pruneLoop()
 }
 
 // Stop stops the task manager and its components
 func (tm *TaskManager) Stop() {
 	tm.workerPool.Stop()
 	tm.scheduledTasks.Stop()
+	close(tm.stopChan)
+}
+
+// UseStore switches to the given task store and restores quota usage and unfinished tasks from it.
+//
+// Restored tasks are not reattached to a connection: the connection that submitted them is gone
+// after a restart and a reconnecting device gets a new connection context, so they run with a
+// background context and without callbacks. They are therefore not cancelled when the client
+// disconnects, and their results are only recorded in the store. Executors of task types that
+// survive restarts must deliver results on their own, as reminders do through the push queue.
+func (tm *TaskManager) UseStore(store TaskStore) error {
+	tm.storeMu.Lock()
+	tm.store = store
+	tm.storeMu.Unlock()
+
+	if err := store.PruneTasks(time.Now().Add(-taskRetention)); err != nil {
+		fmt.Printf("清理历史任务失败: %v\n", err)
+	}
+
+	quotas, err := store.LoadQuotas()
+	if err != nil {
+		return err
+	}
+	for _, q := range quotas {
+		ctx, err := tm.clientManager.GetClientContext(q.ClientID)
+		if err != nil {
+			continue
+		}
+		ctx.ResourceQuota.restoreUsage(q.UsedQuota, q.LastResetDate)
+	}
+
+	records, err := store.LoadUnfinishedTasks()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, rec := range records {
+		task := &Task{
+			ID:        rec.TaskID,
+			Type:      TaskType(rec.Type),
+			Status:    TaskStatusPending,
+			ClinetID:  rec.ClientID,
+			Attempts:  rec.Attempts,
+			CreatedAt: rec.CreatedAt,
+			UpdatedAt: rec.UpdatedAt,
+			Context:   context.Background(),
+		}
+		if rec.Status == string(TaskStatusRetrying) {
+			task.Status = TaskStatusRetrying
+		}
+		if rec.Params != "" {
+			if err := json.Unmarshal([]byte(rec.Params), &task.Params); err != nil {
+				fmt.Printf("恢复任务 %s 参数失败: %v\n", rec.TaskID, err)
+			}
+		}
+		// 立即执行的任务和已到期的定时任务统一在下一次调度时执行
+		scheduled := now
+		if rec.ScheduledTime != nil && rec.ScheduledTime.After(now) {
+			scheduled = *rec.ScheduledTime
+		}
+		task.ScheduledTime = &scheduled
+
+		if ctx, err := tm.clientManager.GetClientContext(rec.ClientID); err == nil {
+			ctx.ResourceQuota.restoreRunning()
+		}
+		tm.scheduledTasks.AddTask(task)
+	}
+	if len(records) > 0 {
+		fmt.Printf("已恢复 %d 个未完成的任务\n", len(records))
+	}
+	return nil
 }
 
 // SubmitTask submits a task for execution
@@ -51,6 +143,44 @@ func (tm *TaskManager) SubmitTask(clientID string, task *Task) error {
 	return tm.submitImmediateTask(clientID, task)
 }
 
+// ListTasks returns the most recent tasks of a client
+func (tm *TaskManager) ListTasks(clientID string) ([]models.TaskRecord, error) {
+	return tm.getStore().ListTasks(clientID, taskListLimit)
+}
+
+// CancelTask cancels a task of the client that is still waiting to run
+func (tm *TaskManager) CancelTask(clientID, taskID string) error {
+	rec, err := tm.getStore().GetTask(taskID)
+	if err != nil {
+		return err
+	}
+	if rec.ClientID != clientID {
+		return ErrTaskNotFound
+	}
+
+	task := tm.scheduledTasks.RemoveTask(taskID)
+	if task == nil {
+		return ErrTaskNotCancellable
+	}
+	if ctx, err := tm.clientManager.GetClientContext(task.ClinetID); err == nil {
+		ctx.ResourceQuota.DecrementQuota(task.Type)
+		ctx.ResourceQuota.CompleteTask(task.Type)
+	}
+	task.Status = TaskStatusCancelled
+	task.Error = fmt.Errorf("任务已取消")
+	if task.Callback != nil {
+		task.Callback.OnError(task.Error)
+	}
+	tm.persistTask(task)
+	tm.saveQuota(task.ClinetID)
+	return nil
+}
+
+// IsScheduled reports whether the task is waiting in the scheduler
+func (tm *TaskManager) IsScheduled(taskID string) bool {
+	return tm.scheduledTasks.HasTask(taskID)
+}
+
 // submitImmediateTask submits a task for immediate execution
 func (tm *TaskManager) submitImmediateTask(clientID string, task *Task) error {
 	// Get or create client context
@@ -65,17 +195,28 @@ func (tm *TaskManager) submitImmediateTask(clientID string, task *Task) error {
 	}
 
 	task.ClinetID = clientID
+	// 先持久化再提交，避免任务结束的状态被覆盖
+	tm.persistTask(task)
 
 	// 提交到工作池，失败时回滚
 	if err := tm.workerPool.Submit(task); err != nil {
 		ctx.ResourceQuota.DecrementQuota(task.Type) // 减少总配额
 		ctx.ResourceQuota.CompleteTask(task.Type)   // 减少并发计数
+		task.Status = TaskStatusFailed
+		task.Error = err
+		tm.persistTask(task)
 		return err
 	}
 
+	tm.saveQuota(clientID)
 	return nil
 }
 
+// QueueDepth returns the number of queued immediate tasks and pending scheduled tasks
+func (tm *TaskManager) QueueDepth() (queued, scheduled int) {
+	return tm.workerPool.QueueLength(), tm.scheduledTasks.Count()
+}
+
 // scheduleTask schedules a task for future execution
 func (tm *TaskManager) scheduleTask(clientID string, task *Task) error {
 	if task.ScheduledTime == nil {
@@ -92,10 +233,120 @@ func (tm *TaskManager) scheduleTask(clientID string, task *Task) error {
 		return err
 	}
 
+	// 记录客户端，执行结束或取消时释放并发配额
+	task.ClinetID = clientID
+	tm.persistTask(task)
+	tm.scheduledTasks.AddTask(task)
+	tm.saveQuota(clientID)
+	return nil
+}
+
+// onTaskFinished 任务执行结束后按重试策略重新安排，或保存最终状态
+func (tm *TaskManager) onTaskFinished(task *Task) {
+	if task.Status == TaskStatusRetrying {
+		err := tm.scheduleRetry(task)
+		if err == nil {
+			return
+		}
+		task.Status = TaskStatusFailed
+		task.Error = fmt.Errorf("%v（重试失败: %v）", task.Error, err)
+		if task.Callback != nil {
+			task.Callback.OnError(task.Error)
+		}
+	}
+	tm.persistTask(task)
+	tm.saveQuota(task.ClinetID)
+}
+
+// scheduleRetry 按指数退避安排下一次执行，每次重试占用一次配额
+func (tm *TaskManager) scheduleRetry(task *Task) error {
+	policy, _ := GetRetryPolicy(task.Type)
+	ctx, err := tm.clientManager.GetClientContext(task.ClinetID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.ResourceQuota.TryIncrementQuota(); err != nil {
+		return err
+	}
+
+	next := time.Now().Add(policy.Backoff(task.Attempts))
+	task.ScheduledTime = &next
+	fmt.Printf("任务 %s 第 %d 次执行失败: %v，将于 %s 重试\n",
+		task.ID, task.Attempts, task.Error, next.Format("15:04:05"))
+	tm.persistTask(task)
 	tm.scheduledTasks.AddTask(task)
+	tm.saveQuota(task.ClinetID)
 	return nil
 }
 
+// persistTask 保存任务当前状态，参数无法编码时不保存参数
+func (tm *TaskManager) persistTask(task *Task) {
+	rec := models.TaskRecord{
+		TaskID:        task.ID,
+		ClientID:      task.ClinetID,
+		Type:          string(task.Type),
+		Status:        string(task.Status),
+		Attempts:      task.Attempts,
+		ScheduledTime: task.ScheduledTime,
+		CreatedAt:     task.CreatedAt,
+	}
+	if task.Error != nil {
+		rec.Error = task.Error.Error()
+	}
+	if task.Params != nil {
+		if data, err := json.Marshal(task.Params); err == nil {
+			rec.Params = string(data)
+		} else {
+			fmt.Printf("任务 %s 参数无法持久化: %v\n", task.ID, err)
+		}
+	}
+	if err := tm.getStore().SaveTask(rec); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// saveQuota 保存客户端的每日配额使用情况
+func (tm *TaskManager) saveQuota(clientID string) {
+	if clientID == "" {
+		return
+	}
+	ctx, err := tm.clientManager.GetClientContext(clientID)
+	if err != nil {
+		return
+	}
+	used, lastResetDate := ctx.ResourceQuota.Usage()
+	err = tm.getStore().SaveQuota(models.TaskQuota{
+		ClientID:      clientID,
+		UsedQuota:     used,
+		LastResetDate: lastResetDate,
+	})
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+func (tm *TaskManager) getStore() TaskStore {
+	tm.storeMu.RLock()
+	defer tm.storeMu.RUnlock()
+	return tm.store
+}
+
+// pruneLoop 定期清理过期的已结束任务记录
+func (tm *TaskManager) pruneLoop() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tm.stopChan:
+			return
+		case <-ticker.C:
+			if err := tm.getStore().PruneTasks(time.Now().Add(-taskRetention)); err != nil {
+				fmt.Printf("%v\n", err)
+			}
+		}
+	}
+}
+
 // ScheduledTasks manages scheduled tasks
 type ScheduledTasks struct {
 	tasks      map[string]*Task
@@ -133,6 +384,33 @@ func (st *ScheduledTasks) AddTask(task *Task) {
 	st.tasks[task.ID] = task
 }
 
+// RemoveTask removes a pending scheduled task, returns nil if it is not pending
+func (st *ScheduledTasks) RemoveTask(taskID string) *Task {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	task, ok := st.tasks[taskID]
+	if !ok {
+		return nil
+	}
+	delete(st.tasks, taskID)
+	return task
+}
+
+// HasTask reports whether the task is still pending
+func (st *ScheduledTasks) HasTask(taskID string) bool {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	_, ok := st.tasks[taskID]
+	return ok
+}
+
+// Count returns the number of pending scheduled tasks
+func (st *ScheduledTasks) Count() int {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return len(st.tasks)
+}
+
 // run processes scheduled tasks
 func (st *ScheduledTasks) run() {
 	for {
@@ -168,6 +446,7 @@ func (st *ScheduledTasks) processScheduledTasks() {
 						}
 					}()
 					t.Execute()
+					st.workerPool.finishTask(t)
 				}(task)
 			}
 			delete(st.tasks, id)
@@ -0,0 +1,245 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTaskNotFound 任务不存在
+var ErrTaskNotFound = errors.New("任务不存在")
+
+// unfinishedStatuses 服务重启后需要恢复的任务状态
+var unfinishedStatuses = []string{
+	string(TaskStatusPending),
+	string(TaskStatusRunning),
+	string(TaskStatusRetrying),
+}
+
+// TaskStore persists tasks and client quota usage across restarts
+type TaskStore interface {
+	// SaveTask 新增或更新任务记录
+	SaveTask(rec models.TaskRecord) error
+	// GetTask 查询任务记录，不存在时返回 ErrTaskNotFound
+	GetTask(taskID string) (*models.TaskRecord, error)
+	// ListTasks 按创建时间倒序查询客户端最近的任务
+	ListTasks(clientID string, limit int) ([]models.TaskRecord, error)
+	// LoadUnfinishedTasks 加载待执行、执行中和等待重试的任务
+	LoadUnfinishedTasks() ([]models.TaskRecord, error)
+	// PruneTasks 删除指定时间之前已结束的任务
+	PruneTasks(before time.Time) error
+	// SaveQuota 保存客户端配额使用情况
+	SaveQuota(rec models.TaskQuota) error
+	// LoadQuotas 加载全部客户端的配额使用情况
+	LoadQuotas() ([]models.TaskQuota, error)
+}
+
+// MemoryStore keeps tasks in memory, nothing survives a restart
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tasks  map[string]models.TaskRecord
+	quotas map[string]models.TaskQuota
+}
+
+// NewMemoryStore creates an in-memory task store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tasks:  make(map[string]models.TaskRecord),
+		quotas: make(map[string]models.TaskQuota),
+	}
+}
+
+// SaveTask 新增或更新任务记录
+func (s *MemoryStore) SaveTask(rec models.TaskRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if old, ok := s.tasks[rec.TaskID]; ok {
+		rec.CreatedAt = old.CreatedAt
+	} else if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	rec.UpdatedAt = now
+	s.tasks[rec.TaskID] = rec
+	return nil
+}
+
+// GetTask 查询任务记录
+func (s *MemoryStore) GetTask(taskID string) (*models.TaskRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.tasks[taskID]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	return &rec, nil
+}
+
+// ListTasks 按创建时间倒序查询客户端最近的任务
+func (s *MemoryStore) ListTasks(clientID string, limit int) ([]models.TaskRecord, error) {
+	s.mu.RLock()
+	var records []models.TaskRecord
+	for _, rec := range s.tasks {
+		if rec.ClientID == clientID {
+			records = append(records, rec)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// LoadUnfinishedTasks 加载未结束的任务
+func (s *MemoryStore) LoadUnfinishedTasks() ([]models.TaskRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var records []models.TaskRecord
+	for _, rec := range s.tasks {
+		if isUnfinished(rec.Status) {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// PruneTasks 删除指定时间之前已结束的任务
+func (s *MemoryStore) PruneTasks(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, rec := range s.tasks {
+		if !isUnfinished(rec.Status) && rec.UpdatedAt.Before(before) {
+			delete(s.tasks, id)
+		}
+	}
+	return nil
+}
+
+// SaveQuota 保存客户端配额使用情况
+func (s *MemoryStore) SaveQuota(rec models.TaskQuota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.UpdatedAt = time.Now()
+	s.quotas[rec.ClientID] = rec
+	return nil
+}
+
+// LoadQuotas 加载全部客户端的配额使用情况
+func (s *MemoryStore) LoadQuotas() ([]models.TaskQuota, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	quotas := make([]models.TaskQuota, 0, len(s.quotas))
+	for _, rec := range s.quotas {
+		quotas = append(quotas, rec)
+	}
+	return quotas, nil
+}
+
+// GormStore persists tasks through the shared GORM database (SQLite/MySQL/PostgreSQL)
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore creates a database backed task store
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// SaveTask 按任务ID新增或更新任务记录
+func (s *GormStore) SaveTask(rec models.TaskRecord) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "params", "attempts", "error", "scheduled_time", "updated_at"}),
+	}).Create(&rec).Error
+	if err != nil {
+		return fmt.Errorf("保存任务 %s 失败: %v", rec.TaskID, err)
+	}
+	return nil
+}
+
+// GetTask 查询任务记录
+func (s *GormStore) GetTask(taskID string) (*models.TaskRecord, error) {
+	var rec models.TaskRecord
+	err := s.db.Where("task_id = ?", taskID).First(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询任务失败: %v", err)
+	}
+	return &rec, nil
+}
+
+// ListTasks 按创建时间倒序查询客户端最近的任务
+func (s *GormStore) ListTasks(clientID string, limit int) ([]models.TaskRecord, error) {
+	var records []models.TaskRecord
+	query := s.db.Where("client_id = ?", clientID).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询任务失败: %v", err)
+	}
+	return records, nil
+}
+
+// LoadUnfinishedTasks 加载未结束的任务
+func (s *GormStore) LoadUnfinishedTasks() ([]models.TaskRecord, error) {
+	var records []models.TaskRecord
+	if err := s.db.Where("status IN ?", unfinishedStatuses).Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("加载未完成任务失败: %v", err)
+	}
+	return records, nil
+}
+
+// PruneTasks 删除指定时间之前已结束的任务
+func (s *GormStore) PruneTasks(before time.Time) error {
+	err := s.db.Where("status NOT IN ? AND updated_at < ?", unfinishedStatuses, before).
+		Delete(&models.TaskRecord{}).Error
+	if err != nil {
+		return fmt.Errorf("清理历史任务失败: %v", err)
+	}
+	return nil
+}
+
+// SaveQuota 按客户端ID新增或更新配额使用情况
+func (s *GormStore) SaveQuota(rec models.TaskQuota) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"used_quota", "last_reset_date", "updated_at"}),
+	}).Create(&rec).Error
+	if err != nil {
+		return fmt.Errorf("保存客户端 %s 配额失败: %v", rec.ClientID, err)
+	}
+	return nil
+}
+
+// LoadQuotas 加载全部客户端的配额使用情况
+func (s *GormStore) LoadQuotas() ([]models.TaskQuota, error) {
+	var quotas []models.TaskQuota
+	if err := s.db.Find(&quotas).Error; err != nil {
+		return nil, fmt.Errorf("加载任务配额失败: %v", err)
+	}
+	return quotas, nil
+}
+
+func isUnfinished(status string) bool {
+	for _, s := range unfinishedStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package task
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestGormStore(t *testing.T) TaskStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.TaskRecord{}, &models.TaskQuota{}); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return NewGormStore(db)
+}
+
+// storeCases 内存存储和数据库存储需要满足相同的行为
+var storeCases = []struct {
+	name     string
+	newStore func(t *testing.T) TaskStore
+}{
+	{name: "内存存储", newStore: func(t *testing.T) TaskStore { return NewMemoryStore() }},
+	{name: "数据库存储", newStore: newTestGormStore},
+}
+
+func TestStoreTasks(t *testing.T) {
+	for _, sc := range storeCases {
+		t.Run(sc.name, func(t *testing.T) {
+			store := sc.newStore(t)
+			created := time.Now().Add(-time.Hour)
+			records := []models.TaskRecord{
+				{TaskID: "t1", ClientID: "c1", Type: "reminder", Status: string(TaskStatusComplete), CreatedAt: created},
+				{TaskID: "t2", ClientID: "c1", Type: "reminder", Status: string(TaskStatusPending), CreatedAt: created.Add(time.Minute)},
+				{TaskID: "t3", ClientID: "c1", Type: "reminder", Status: string(TaskStatusRetrying), Attempts: 1, CreatedAt: created.Add(2 * time.Minute)},
+				{TaskID: "t4", ClientID: "c2", Type: "reminder", Status: string(TaskStatusRunning), CreatedAt: created.Add(3 * time.Minute)},
+			}
+			for _, rec := range records {
+				if err := store.SaveTask(rec); err != nil {
+					t.Fatalf("SaveTask(%s) 失败: %v", rec.TaskID, err)
+				}
+			}
+
+			// 更新已有任务，创建时间保持不变
+			update := records[1]
+			update.Status = string(TaskStatusFailed)
+			update.Attempts = 3
+			update.Error = "执行失败"
+			update.CreatedAt = time.Time{}
+			if err := store.SaveTask(update); err != nil {
+				t.Fatalf("更新任务失败: %v", err)
+			}
+			got, err := store.GetTask("t2")
+			if err != nil {
+				t.Fatalf("GetTask() 失败: %v", err)
+			}
+			if got.Status != string(TaskStatusFailed) || got.Attempts != 3 || got.Error != "执行失败" {
+				t.Errorf("更新后任务 = %+v，期望 failed/3次/执行失败", got)
+			}
+			if !got.CreatedAt.Equal(records[1].CreatedAt) {
+				t.Errorf("更新后创建时间 = %v，期望保持 %v", got.CreatedAt, records[1].CreatedAt)
+			}
+
+			if _, err := store.GetTask("missing"); !errors.Is(err, ErrTaskNotFound) {
+				t.Errorf("GetTask(不存在) 错误 = %v，期望 ErrTaskNotFound", err)
+			}
+
+			list, err := store.ListTasks("c1", 2)
+			if err != nil {
+				t.Fatalf("ListTasks() 失败: %v", err)
+			}
+			if len(list) != 2 || list[0].TaskID != "t3" || list[1].TaskID != "t2" {
+				t.Errorf("ListTasks(c1, 2) = %v，期望按创建时间倒序的 [t3 t2]", taskIDs(list))
+			}
+
+			unfinished, err := store.LoadUnfinishedTasks()
+			if err != nil {
+				t.Fatalf("LoadUnfinishedTasks() 失败: %v", err)
+			}
+			if ids := taskIDSet(unfinished); len(ids) != 2 || !ids["t3"] || !ids["t4"] {
+				t.Errorf("LoadUnfinishedTasks() = %v，期望 [t3 t4]", taskIDs(unfinished))
+			}
+
+			// 只清理已结束的任务
+			if err := store.PruneTasks(time.Now().Add(time.Minute)); err != nil {
+				t.Fatalf("PruneTasks() 失败: %v", err)
+			}
+			for _, id := range []string{"t1", "t2"} {
+				if _, err := store.GetTask(id); !errors.Is(err, ErrTaskNotFound) {
+					t.Errorf("已结束任务 %s 未被清理", id)
+				}
+			}
+			for _, id := range []string{"t3", "t4"} {
+				if _, err := store.GetTask(id); err != nil {
+					t.Errorf("未结束任务 %s 不应被清理: %v", id, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreQuotas(t *testing.T) {
+	for _, sc := range storeCases {
+		t.Run(sc.name, func(t *testing.T) {
+			store := sc.newStore(t)
+			today := time.Now().Truncate(24 * time.Hour)
+			for _, rec := range []models.TaskQuota{
+				{ClientID: "c1", UsedQuota: 1, LastResetDate: today},
+				{ClientID: "c2", UsedQuota: 5, LastResetDate: today},
+				{ClientID: "c1", UsedQuota: 3, LastResetDate: today},
+			} {
+				if err := store.SaveQuota(rec); err != nil {
+					t.Fatalf("SaveQuota(%s) 失败: %v", rec.ClientID, err)
+				}
+			}
+
+			quotas, err := store.LoadQuotas()
+			if err != nil {
+				t.Fatalf("LoadQuotas() 失败: %v", err)
+			}
+			used := make(map[string]int)
+			for _, q := range quotas {
+				used[q.ClientID] = q.UsedQuota
+			}
+			if len(quotas) != 2 || used["c1"] != 3 || used["c2"] != 5 {
+				t.Errorf("LoadQuotas() = %v，期望 c1=3, c2=5", used)
+			}
+		})
+	}
+}
+
+func taskIDs(records []models.TaskRecord) []string {
+	ids := make([]string, len(records))
+	for i, rec := range records {
+		ids[i] = rec.TaskID
+	}
+	return ids
+}
+
+func taskIDSet(records []models.TaskRecord) map[string]bool {
+	ids := make(map[string]bool, len(records))
+	for _, rec := range records {
+		ids[rec.TaskID] = true
+	}
+	return ids
+}
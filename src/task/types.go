@@ -23,17 +23,45 @@ const (
 	TaskStatusRunning  TaskStatus = "running"
 	TaskStatusComplete TaskStatus = "complete"
 	TaskStatusFailed   TaskStatus = "failed"
+	// TaskStatusRetrying 执行失败，等待按重试策略再次执行
+	TaskStatusRetrying TaskStatus = "retrying"
+	// TaskStatusCancelled 任务被取消或所属连接已断开
+	TaskStatusCancelled TaskStatus = "cancelled"
 )
 
+// RetryPolicy 任务执行失败后的重试策略，等待时间按指数退避增长
+type RetryPolicy struct {
+	MaxAttempts    int           // 最多执行次数（含首次），小于等于1表示不重试
+	InitialBackoff time.Duration // 第一次重试前的等待时间
+	MaxBackoff     time.Duration // 等待时间上限，为0时不设上限
+}
+
+// Backoff returns the delay before the next attempt after the given number of attempts
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
 // TaskRegistry manages task type to executor mappings
 type TaskRegistry struct {
 	executors map[TaskType]TaskExecutor
+	policies  map[TaskType]RetryPolicy
 	mu        sync.RWMutex
 }
 
 // Global task registry instance
 var taskRegistry = &TaskRegistry{
 	executors: make(map[TaskType]TaskExecutor),
+	policies:  make(map[TaskType]RetryPolicy),
 }
 
 // RegisterTaskExecutor registers a task executor for a specific task type
@@ -52,6 +80,21 @@ func GetTaskExecutor(taskType TaskType) (TaskExecutor, bool) {
 	return executor, exists
 }
 
+// RegisterRetryPolicy registers the retry policy for a specific task type
+func RegisterRetryPolicy(taskType TaskType, policy RetryPolicy) {
+	taskRegistry.mu.Lock()
+	defer taskRegistry.mu.Unlock()
+	taskRegistry.policies[taskType] = policy
+}
+
+// GetRetryPolicy retrieves the retry policy for a specific task type
+func GetRetryPolicy(taskType TaskType) (RetryPolicy, bool) {
+	taskRegistry.mu.RLock()
+	defer taskRegistry.mu.RUnlock()
+	policy, exists := taskRegistry.policies[taskType]
+	return policy, exists
+}
+
 // GetRegisteredTaskTypes returns all registered task types
 func GetRegisteredTaskTypes() []TaskType {
 	taskRegistry.mu.RLock()
@@ -77,6 +120,7 @@ type Task struct {
 	UpdatedAt     time.Time
 	ClinetID      string
 	Context       context.Context
+	Attempts      int // 已执行次数
 }
 
 func NewTask(ctx context.Context, taskType TaskType, params interface{}) (task *Task, id string) {
@@ -106,11 +150,14 @@ func (t *Task) Execute() {
 	select {
 	case <-t.Context.Done():
 		fmt.Printf("任务 %s 因连接断开而取消\n", t.ID)
+		t.Status = TaskStatusCancelled
+		t.Error = t.Context.Err()
 		return
 	default:
 	}
 
 	t.Status = TaskStatusRunning
+	t.Attempts++
 	t.UpdatedAt = time.Now()
 
 	executor, exists := GetTaskExecutor(t.Type)
@@ -124,6 +171,11 @@ func (t *Task) Execute() {
 
 	// Call appropriate callback
 	if t.Error != nil {
+		// 还有重试次数时由任务管理器重新安排，不回调失败
+		if policy, ok := GetRetryPolicy(t.Type); ok && t.Attempts < policy.MaxAttempts {
+			t.Status = TaskStatusRetrying
+			return
+		}
 		t.Status = TaskStatusFailed
 		if t.Callback != nil {
 			t.Callback.OnError(t.Error)
@@ -0,0 +1,32 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempts int
+		want     time.Duration
+	}{
+		{name: "第一次重试", policy: RetryPolicy{InitialBackoff: time.Second}, attempts: 1, want: time.Second},
+		{name: "指数增长", policy: RetryPolicy{InitialBackoff: time.Second}, attempts: 4, want: 8 * time.Second},
+		{name: "未设置上限", policy: RetryPolicy{InitialBackoff: time.Second}, attempts: 11, want: 1024 * time.Second},
+		{name: "达到上限", policy: RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, attempts: 4, want: 5 * time.Second},
+		{name: "刚好等于上限", policy: RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 4 * time.Second}, attempts: 3, want: 4 * time.Second},
+		{name: "初始等待超过上限", policy: RetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: 5 * time.Second}, attempts: 1, want: 5 * time.Second},
+		{name: "多次重试后不溢出", policy: RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, attempts: 100, want: time.Minute},
+		{name: "执行次数为0", policy: RetryPolicy{InitialBackoff: time.Second}, attempts: 0, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.attempts); got != tt.want {
+				t.Errorf("Backoff(%d) = %v，期望 %v", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
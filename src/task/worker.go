@@ -16,6 +16,7 @@ type WorkerPool struct {
 	stopChan      chan struct{}
 	idleWorkers   chan *Worker
 	clientManager *ClientManager
+	onFinished    func(task *Task) // 任务执行结束（含分配失败）后的回调，用于持久化和重试
 	mu            sync.RWMutex
 }
 
@@ -90,6 +91,11 @@ func (wp *WorkerPool) Submit(task *Task) error {
 	}
 }
 
+// QueueLength returns the number of tasks waiting in the queue
+func (wp *WorkerPool) QueueLength() int {
+	return len(wp.taskQueue)
+}
+
 // distributeItems distributes tasks to appropriate workers
 func (wp *WorkerPool) distributeItems() {
 	for {
@@ -110,10 +116,19 @@ func (wp *WorkerPool) requeueTask(task *Task) {
 	default:
 		// 队列已满，处理这种情况
 		// 可以记录日志，或尝试其他策略
+		task.Error = fmt.Errorf("task queue is full, cannot process task")
+		task.Status = TaskStatusFailed
 		if task.Callback != nil {
-			task.Error = fmt.Errorf("task queue is full, cannot process task")
 			task.Callback.OnError(task.Error)
 		}
+		wp.finishTask(task)
+	}
+}
+
+// finishTask 通知任务管理器任务已结束
+func (wp *WorkerPool) finishTask(task *Task) {
+	if wp.onFinished != nil {
+		wp.onFinished(task)
 	}
 }
 
@@ -126,6 +141,7 @@ func (wp *WorkerPool) assignTask(task *Task) {
 		if task.Callback != nil {
 			task.Callback.OnError(task.Error)
 		}
+		wp.finishTask(task)
 		return
 	}
 
@@ -145,6 +161,7 @@ func (wp *WorkerPool) assignTask(task *Task) {
 		if task.Callback != nil {
 			task.Callback.OnError(task.Error)
 		}
+		wp.finishTask(task)
 	}
 }
 
@@ -185,6 +202,8 @@ func (w *Worker) start() {
 // executeTask executes a task
 func (w *Worker) executeTask(task *Task) {
 	w.status = WorkerStatusBusy
+	parent := task.Context
+	finished := false
 
 	defer func() {
 		w.status = WorkerStatusIdle
@@ -195,9 +214,14 @@ func (w *Worker) executeTask(task *Task) {
 				ctx.ResourceQuota.CompleteTask(task.Type)
 			}
 		}
+		// 恢复原始context，重试时不能使用本次执行的超时context
+		if finished {
+			task.Context = parent
+		}
+		w.pool.finishTask(task)
 	}()
 	// 创建带取消的context
-	ctx, cancel := context.WithTimeout(task.Context, 5*time.Minute)
+	ctx, cancel := context.WithTimeout(parent, 5*time.Minute)
 	defer cancel()
 
 	// 在新的context中执行任务
@@ -228,6 +252,7 @@ func (w *Worker) executeTask(task *Task) {
 	select {
 	case <-done:
 		// 任务正常完成
+		finished = true
 	case <-ctx.Done():
 		// 超时或取消
 		task.Status = TaskStatusFailed